@@ -27,6 +27,8 @@ import (
 	"userstyles.world/modules/email"
 	"userstyles.world/modules/images"
 	"userstyles.world/modules/log"
+	"userstyles.world/modules/oauthlogin"
+	"userstyles.world/modules/ratelimit"
 	"userstyles.world/modules/templates"
 	"userstyles.world/modules/util"
 	"userstyles.world/modules/validator"
@@ -38,6 +40,8 @@ func main() {
 	cache.Initialize()
 	images.CheckVips()
 	util.InitCrypto()
+	oauthlogin.Initialize()
+	ratelimit.Initialize()
 	validator.Init()
 	database.Initialize()
 	cron.Initialize()
@@ -54,6 +58,8 @@ func main() {
 	})
 
 	email.SetRenderer(app)
+	email.InitDKIM()
+	email.Run()
 
 	if !config.Production {
 		app.Use(logger.New())
@@ -65,6 +71,7 @@ func main() {
 	app.Use(core.CSPMiddleware)
 	app.Use(core.FlagsMiddleware)
 	app.Use(jwtware.New("user", jwtware.NormalJWTSigning))
+	app.Use(jwtware.CheckSession)
 
 	if config.PerformanceMonitor {
 		perf := app.Group("/debug")
@@ -117,6 +124,7 @@ func main() {
 	cache.InstallStats.Close()
 	cache.ViewStats.Close()
 	cache.SaveStore()
+	email.Close()
 	_ = database.Close()
 	log.Info.Printf("Done in %s.\n", time.Since(t))
 }
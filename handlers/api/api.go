@@ -5,14 +5,17 @@ import (
 	"github.com/gofiber/adaptor/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"userstyles.world/handlers/middleware"
 )
 
 // FastRoutes provides auth-less routes for Fiber's router.
 func FastRoutes(app *fiber.App) {
 	r := app.Group("/api")
 	r.Get("/health", GetHealth)
-	r.Get("/style/:id.user.:ext", GetStyleCode)
-	r.Head("/style/:id.user.:ext", GetStyleCode)
+	r.Get("/style/:id.user.:ext", middleware.RateLimit("code", middleware.ByIP), GetStyleCode)
+	r.Head("/style/:id.user.:ext", middleware.RateLimit("code", middleware.ByIP), GetStyleCode)
+	r.Get("/style/stats/:id/chart/:kind.svg", GetStyleChart)
 	r.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
 }
 
@@ -20,13 +23,18 @@ func FastRoutes(app *fiber.App) {
 func Routes(app *fiber.App) {
 	r := app.Group("/api", ParseAPIJWT)
 	r.Get("/style/:id", GetStyleDetails)
+	r.Get("/style/stats/:id/history", GetStyleHistory)
+	r.Get("/style/stats/:id/sources", GetStyleSources)
+	r.Get("/stats/sources", GetSiteSources)
 	r.Get("/style/stats/:id/:type?", GetStyleStats)
 	r.Get("/index/:format?", GetStyleIndex)
+	r.Get("/explore", GetExplore)
 	r.Get("/search/:query", GetSearchResult)
-	r.Get("/callback/:rcode", CallbackGet)
+	r.Get("/callback/:provider", CallbackGet)
 	r.Get("/user", ProtectedAPI, UserGet)
 	r.Get("/user/:identifier", SpecificUserGet)
 	r.Get("/styles", ProtectedAPI, StylesGet)
+	r.Get("/styles/history", ProtectedAPI, CompareStyleHistory)
 	r.Post("/style/new", ProtectedAPI, NewStyle)
 	r.Post("/style/:id", ProtectedAPI, StylePost)
 	r.Delete("/style/:id", ProtectedAPI, DeleteStyle)
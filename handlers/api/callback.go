@@ -1,6 +1,7 @@
 package api
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"math/rand"
 	"time"
@@ -8,8 +9,9 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
+	jwtware "userstyles.world/handlers/jwt"
 	"userstyles.world/models"
-	"userstyles.world/modules/config"
+	"userstyles.world/modules/cache"
 	"userstyles.world/modules/database"
 	"userstyles.world/modules/errors"
 	"userstyles.world/modules/log"
@@ -20,37 +22,32 @@ import (
 var allowedErrors = []error{
 	errors.ErrPrimaryEmailNotVerified,
 	errors.ErrNoServiceDetected,
+	errors.ErrInvalidOAuthState,
+	errors.ErrOAuthEmailExists,
 }
 
 func CallbackGet(c *fiber.Ctx) error {
 	// Get the necessary information.
-	redirectCode, tempCode, state := c.Params("rcode"), c.Query("code"), c.Query("state")
-	if redirectCode == "" || tempCode == "" {
-		log.Info.Println("No redirectCode or tempCode was detected.")
+	tempCode, state := c.Query("code"), c.Query("state")
+	service := oauthlogin.CallbackService(c.Params("provider"), state)
+	if service == "" || tempCode == "" {
+		log.Info.Println("No service or tempCode was detected.")
 		// Give them the bad endpoint error.
 		return c.Next()
 	}
-	var service string
-	var rState string
-	if redirectCode != "codeberg" && redirectCode != "gitlab" {
-		service = "github"
-		// Decode the string so we get our actual information back.
-		code, err := util.DecryptText(redirectCode, util.AEADOAuth, config.ScrambleConfig)
-		if err != nil {
-			log.Warn.Println("Failed to decode prepared text.")
-			return c.Next()
-		}
-		rState = code
 
-		if rState != state {
-			log.Warn.Println("Failed to match states.")
-			return c.Next()
-		}
-	} else {
-		service = redirectCode
+	// State has to match the one set for this browser, which prevents
+	// attackers from signing users in to their accounts.
+	cookie := c.Cookies(oauthlogin.StateCookie)
+	c.ClearCookie(oauthlogin.StateCookie)
+	if state == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		log.Warn.Println("Failed to match states.")
+		return c.Render("err", fiber.Map{
+			"Title": errors.ErrInvalidOAuthState.Error(),
+		})
 	}
 
-	response, err := oauthlogin.CallbackOAuth(tempCode, rState, service)
+	response, err := oauthlogin.CallbackOAuth(tempCode, state, service)
 	if err != nil {
 		log.Warn.Println("Ouch, the response failed:", err.Error())
 		// We only allow a certain amount of errors to be displayed to the
@@ -64,21 +61,36 @@ func CallbackGet(c *fiber.Ctx) error {
 		return c.Next()
 	}
 
+	if response.LinkUserID != 0 {
+		return linkAccount(c, response)
+	}
+
 	user, err := flow(response)
 	if err != nil {
 		log.Warn.Printf("User %q failed to sign in: %s\n", response.Username, err)
+		if util.ContainsError(allowedErrors, err) {
+			return c.Render("err", fiber.Map{"Title": err.Error()})
+		}
 		msg := "Please contact us and provide this timestamp: " + time.Now().Format(time.RFC3339)
 		return c.Render("err", fiber.Map{"Title": msg})
 	}
 
 	expiration := time.Now().Add(time.Hour * 24 * 14)
-	t, err := util.NewJWT().
-		SetClaim("id", user.ID).
-		SetClaim("name", user.Username).
-		SetClaim("role", user.Role).
-		SetExpiration(expiration).
-		GetSignedString(nil)
-	if err != nil {
+
+	// Second factor has to be verified before creating a session.
+	if user.HasTOTP() {
+		if err := jwtware.StartTwoFactor(c, user, expiration); err != nil {
+			log.Warn.Println("Failed to start 2FA:", err.Error())
+			return c.Status(fiber.StatusInternalServerError).
+				JSON(fiber.Map{
+					"data": "Internal Error.",
+				})
+		}
+
+		return c.Redirect("/login/2fa", fiber.StatusSeeOther)
+	}
+
+	if err := jwtware.NewLogin(c, user, expiration, false, string(response.Provider)); err != nil {
 		log.Warn.Println("Failed to create JWT Token:", err.Error())
 		return c.Status(fiber.StatusInternalServerError).
 			JSON(fiber.Map{
@@ -86,105 +98,136 @@ func CallbackGet(c *fiber.Ctx) error {
 			})
 	}
 
-	if err := user.UpdateLastLogin(); err != nil {
-		log.Database.Printf("Failed to update last_login for %d\n", user.ID)
+	return c.Redirect("/account", fiber.StatusSeeOther)
+}
+
+// externalUser returns an account that users signed in with.
+func externalUser(o oauthlogin.OAuthResponse) *models.ExternalUser {
+	return &models.ExternalUser{
+		ExternalID:  o.ExternalID,
+		Provider:    string(o.Provider),
+		Email:       o.Email,
+		Username:    o.Username,
+		ExternalURL: o.ProfileURL(),
+		AccessToken: o.AccessToken,
 	}
+}
 
-	c.Cookie(&fiber.Cookie{
-		Name:     fiber.HeaderAuthorization,
-		Value:    t,
-		Path:     "/",
-		Expires:  expiration,
-		Secure:   config.Production,
-		HTTPOnly: true,
-		SameSite: fiber.CookieSameSiteLaxMode,
-	})
+// linkAccount links an external account to the signed-in user who started
+// linking it.
+func linkAccount(c *fiber.Ctx, o oauthlogin.OAuthResponse) error {
+	u, ok := jwtware.User(c)
+	if !ok || u.ID != o.LinkUserID {
+		log.Warn.Printf("User %d failed to link %s: session doesn't match\n", o.LinkUserID, o.Provider)
+		return c.Status(fiber.StatusForbidden).Render("err", fiber.Map{
+			"Title": "Sign in to the account that you want to link to",
+		})
+	}
 
-	return c.Redirect("/account", fiber.StatusSeeOther)
+	eu, err := models.FindExternalUser(string(o.Provider), o.ExternalID)
+	switch {
+	case err == nil && eu.UserID == u.ID:
+		a := models.NewSuccessAlert("Account is already linked.")
+		cache.Store.Add("alert "+u.Username, a, time.Minute)
+		return c.Redirect("/account#linked", fiber.StatusSeeOther)
+
+	case err == nil:
+		return c.Status(fiber.StatusConflict).Render("err", fiber.Map{
+			"Title": "This account is linked to another user",
+			"User":  u,
+		})
+
+	case err != gorm.ErrRecordNotFound:
+		log.Database.Printf("Failed to find linked account for %d: %s\n", u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to link account",
+			"User":  u,
+		})
+	}
+
+	eu = externalUser(o)
+	eu.UserID = u.ID
+	if err = models.LinkExternalUser(eu); err != nil {
+		log.Database.Printf("Failed to link %s for %d: %s\n", o.Provider, u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to link account",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=link id=%d username=%s provider=%s\n", u.ID, u.Username, o.Provider)
+
+	a := models.NewSuccessAlert("Account has been linked.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account#linked", fiber.StatusSeeOther)
 }
 
 func flow(o oauthlogin.OAuthResponse) (*models.User, error) {
-	var eu models.ExternalUser
-
 	// Check if external user exists.
-	err := database.Conn.Debug().
-		Model(eu).Preload("User").
-		First(&eu, "provider = ? AND external_id = ?", o.Provider, o.ExternalID).Error
-
+	eu, err := models.FindExternalUser(string(o.Provider), o.ExternalID)
 	switch {
-	case err == gorm.ErrRecordNotFound:
-		// Check if user exists.
-		err := database.Conn.Debug().
-			First(&eu.User, "username = ? AND o_auth_provider = ?", o.Username, o.Provider).Error
-		if err != nil {
-			eu = models.ExternalUser{
-				ExternalID:  o.ExternalID,
-				Provider:    string(o.Provider),
-				Email:       o.Email,
-				Username:    o.Username,
-				ExternalURL: o.ProfileURL(),
-				AccessToken: o.AccessToken,
-				User: models.User{
-					Email:         o.Email,
-					Username:      o.Username,
-					OAuthProvider: string(o.Provider),
-				},
-			}
-
-			var u models.User
-			err := database.Conn.Debug().Where("email = ?", eu.Email).Find(&u).Error
-			if err != nil {
-				return nil, err
-			}
-
-			// Fail on duplicate emails.
-			if u.ID > 0 {
-				return nil, fmt.Errorf("email %s already exists", eu.Email)
-			}
-
-			err = database.Conn.Debug().Where("username = ?", eu.Username).Find(&u).Error
-			if err != nil {
-				return nil, err
-			}
-
-			// Workaround for duplicate usernames.
-			if u.ID > 0 {
-				eu.User.Username = fmt.Sprintf("%s-%d", eu.User.Username, randInt(9999))
-			}
-
-			if err := database.Conn.Debug().Create(&eu).Error; err != nil {
-				return nil, err
-			}
-
-			log.Info.Printf("kind=signup id=%d username=%s", eu.User.ID, eu.User.Username)
-
-			return &eu.User, nil
-		} else {
-			// NOTE: This overrides existing values.
-			eu = models.ExternalUser{
-				User:        eu.User,
-				UserID:      eu.User.ID,
-				ExternalID:  o.ExternalID,
-				Provider:    string(o.Provider),
-				Email:       o.Email,
-				Username:    o.Username,
-				ExternalURL: o.ProfileURL(),
-				AccessToken: o.AccessToken,
-			}
-			if err := database.Conn.Debug().Create(&eu).Error; err != nil {
-				return nil, err
-			}
-
-			log.Info.Printf("kind=migration id=%d username=%s", eu.User.ID, eu.User.Username)
-
-			return &eu.User, nil
+	case err == nil:
+		log.Info.Printf("kind=signin id=%d username=%s", eu.User.ID, eu.User.Username)
+		return &eu.User, nil
+	case err != gorm.ErrRecordNotFound:
+		return nil, err
+	}
+
+	eu = externalUser(o)
+
+	// Users who signed up before accounts were linked are matched by their
+	// username and provider.
+	var u models.User
+	err = database.Conn.
+		Where("username = ? AND o_auth_provider = ?", o.Username, o.Provider).
+		Find(&u).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	if u.ID > 0 {
+		eu.UserID = u.ID
+		if err := models.LinkExternalUser(eu); err != nil {
+			return nil, err
 		}
 
-	case err != nil:
+		log.Info.Printf("kind=migration id=%d username=%s", u.ID, u.Username)
+
+		return &u, nil
+	}
+
+	// Existing accounts have to be linked by their owners, otherwise signing
+	// in would bypass their password and second factor.
+	err = database.Conn.Where("email = ?", eu.Email).Find(&u).Error
+	if err != nil {
+		return nil, err
+	}
+	if u.ID > 0 {
+		return nil, errors.ErrOAuthEmailExists
+	}
+
+	eu.User = models.User{
+		Email:         o.Email,
+		Username:      o.Username,
+		OAuthProvider: string(o.Provider),
+	}
+	eu.User.Socials.Set(eu.Provider, eu.Username)
+
+	err = database.Conn.Where("username = ?", eu.Username).Find(&u).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Workaround for duplicate usernames.
+	if u.ID > 0 {
+		eu.User.Username = fmt.Sprintf("%s-%d", eu.User.Username, randInt(9999))
+	}
+
+	if err := database.Conn.Create(eu).Error; err != nil {
 		return nil, err
 	}
 
-	log.Info.Printf("kind=signin id=%d username=%s", eu.User.ID, eu.User.Username)
+	log.Info.Printf("kind=signup id=%d username=%s", eu.User.ID, eu.User.Username)
 
 	return &eu.User, nil
 }
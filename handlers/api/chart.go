@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/modules/charts"
+)
+
+// GetStyleChart serves a chart of style's history, which is rendered after
+// the nightly snapshot.
+func GetStyleChart(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"data": "Error: Couldn't parse param \"id\"",
+		})
+	}
+
+	kind, theme := c.Params("kind"), c.Query("theme", "dark")
+	if !charts.ValidKind(kind) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"data": "Error: Chart must be daily or total.",
+		})
+	}
+	if !charts.ValidTheme(theme) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"data": "Error: Theme must be dark or light.",
+		})
+	}
+
+	b, err := charts.ReadStyleChart(uint(id), kind)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"data": "Error: Style doesn't have a chart yet.",
+		})
+	}
+
+	c.Set(fiber.HeaderETag, fmt.Sprintf(`"%d-%d-%s"`, len(b), crc32.ChecksumIEEE(b), theme))
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	if c.Fresh() {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	c.Type("svg")
+	return c.Send(charts.Theme(b, theme))
+}
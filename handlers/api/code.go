@@ -6,9 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
+	"userstyles.world/models"
+	"userstyles.world/modules/botfilter"
 	"userstyles.world/modules/cache"
 	"userstyles.world/modules/config"
 )
@@ -48,7 +51,18 @@ func GetStyleCode(c *fiber.Ctx) error {
 	cs := crc32.ChecksumIEEE(code)
 	c.Set("ETag", fmt.Sprintf("%s-%d", cl, cs))
 
-	cache.InstallStats.Add(c.IP() + " " + id)
+	// Installs are classified first, so that curl and API clients aren't
+	// dropped as crawlers.
+	origin := c.Get(fiber.HeaderOrigin)
+	if origin == "" {
+		origin = c.Get(fiber.HeaderReferer)
+	}
+	r := botfilter.NewRequest(c, botfilter.Install)
+	source := models.ClassifyInstall(r.UserAgent, origin, hasValidToken(c))
+	r.Known = source == models.SourceCurl || source == models.SourceAPI
+	if botfilter.Filter(r, time.Now()) == "" {
+		cache.InstallStats.AddFrom(c.IP(), id, source)
+	}
 
 	return c.Send(code)
 }
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/storage"
+)
+
+// GetExplore returns a page of style cards, sorted like on Explore page.
+func GetExplore(c *fiber.Ctx) error {
+	page, err := models.IsValidPage(c.Query("page"))
+	if err != nil || page < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"data": "Error: Couldn't parse query \"page\"",
+		})
+	}
+
+	count, err := models.GetStyleCount()
+	if err != nil {
+		log.Database.Println("Failed to count styles:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"data": "Error: Couldn't count styles.",
+		})
+	}
+
+	p := models.NewPagination(page, count, c.Query("sort"), c.Path())
+	if page > p.Max {
+		return c.JSON(fiber.Map{"data": []storage.StyleCard{}, "page": page, "pages": p.Max})
+	}
+
+	styles, err := storage.FindStyleCardsPaginated(p.Now, config.AppPageMaxItems, p.SortStyles())
+	if err != nil {
+		log.Database.Println("Failed to get styles:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"data": "Error: Couldn't find styles.",
+		})
+	}
+
+	return c.JSON(fiber.Map{"data": styles, "page": p.Now, "pages": p.Max})
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+const (
+	// historyDays is how many days of history are returned by default.
+	historyDays = 90
+
+	// maxCompared limits how many styles can be compared at once.
+	maxCompared = 10
+)
+
+type styleHistory struct {
+	ID     uint                  `json:"id"`
+	Name   string                `json:"name"`
+	Series []models.HistoryPoint `json:"series"`
+}
+
+type historyReport struct {
+	Granularity models.Granularity `json:"granularity"`
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Styles      []styleHistory     `json:"styles"`
+
+	from, to time.Time
+	csv      bool
+}
+
+// parseHistoryQuery reads the period, granularity and format of a report.
+// Periods include both dates, and default to the last 90 days.
+func parseHistoryQuery(c *fiber.Ctx) (*historyReport, string) {
+	r := &historyReport{
+		Granularity: models.Granularity(c.Query("granularity", string(models.GranularityDay))),
+	}
+	if !r.Granularity.Valid() {
+		return nil, "Error: Granularity must be day, week or month."
+	}
+
+	switch c.Query("format", "json") {
+	case "json":
+	case "csv":
+		r.csv = true
+	default:
+		return nil, "Error: Format must be json or csv."
+	}
+
+	var err error
+	r.to = time.Now().UTC().Truncate(24 * time.Hour)
+	if s := c.Query("to"); s != "" {
+		if r.to, err = time.Parse("2006-01-02", s); err != nil {
+			return nil, "Error: Couldn't parse \"to\" as YYYY-MM-DD."
+		}
+	}
+
+	r.from = r.to.AddDate(0, 0, 1-historyDays)
+	if s := c.Query("from"); s != "" {
+		if r.from, err = time.Parse("2006-01-02", s); err != nil {
+			return nil, "Error: Couldn't parse \"from\" as YYYY-MM-DD."
+		}
+	}
+	if r.from.After(r.to) {
+		return nil, "Error: \"from\" can't be after \"to\"."
+	}
+
+	r.From = r.from.Format("2006-01-02")
+	r.To = r.to.Format("2006-01-02")
+
+	return r, ""
+}
+
+// load fills in series of styles.
+func (r *historyReport) load(styles []models.Style) error {
+	ids := make([]uint, 0, len(styles))
+	for _, s := range styles {
+		ids = append(ids, s.ID)
+	}
+
+	h, err := models.GetHistoryRange(database.Conn, ids, r.from, r.to.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	r.Styles = make([]styleHistory, 0, len(styles))
+	for _, s := range styles {
+		i := 0
+		for i < len(h) && h[i].StyleID == s.ID {
+			i++
+		}
+
+		series := models.GroupHistory(h[:i], r.Granularity)
+		if series == nil {
+			series = []models.HistoryPoint{}
+		}
+		r.Styles = append(r.Styles, styleHistory{ID: s.ID, Name: s.Name, Series: series})
+		h = h[i:]
+	}
+
+	return nil
+}
+
+func (r *historyReport) send(c *fiber.Ctx, name string) error {
+	if !r.csv {
+		return c.JSON(fiber.Map{"data": r})
+	}
+
+	c.Type("csv", "utf-8")
+	c.Attachment(name + ".csv")
+
+	w := csv.NewWriter(c)
+	_ = w.Write([]string{
+		"style_id", "date", "views", "installs", "updates",
+		"weekly_views", "weekly_installs", "weekly_updates",
+		"total_views", "total_installs", "total_updates",
+	})
+
+	for _, s := range r.Styles {
+		id := strconv.FormatUint(uint64(s.ID), 10)
+		for _, p := range s.Series {
+			row := []string{id, p.Date}
+			for _, n := range []int64{
+				p.Views, p.Installs, p.Updates,
+				p.WeeklyViews, p.WeeklyInstalls, p.WeeklyUpdates,
+				p.TotalViews, p.TotalInstalls, p.TotalUpdates,
+			} {
+				row = append(row, strconv.FormatInt(n, 10))
+			}
+			_ = w.Write(row)
+		}
+	}
+	w.Flush()
+
+	return w.Error()
+}
+
+// GetStyleHistory returns stats of a style over time.
+func GetStyleHistory(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"data": "Error: Couldn't parse param \"id\"",
+		})
+	}
+
+	r, msg := parseHistoryQuery(c)
+	if r == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"data": msg})
+	}
+
+	styles, err := models.FindStylesByIDs(database.Conn, []uint{uint(id)})
+	if err != nil || len(styles) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"data": "Error: Couldn't find style with ID.",
+		})
+	}
+
+	if err = r.load(styles); err != nil {
+		log.Database.Printf("Failed to get history of style %d: %s\n", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"data": "Error: Couldn't get style history.",
+		})
+	}
+
+	return r.send(c, "style-"+strconv.Itoa(id)+"-history")
+}
+
+// CompareStyleHistory returns stats of multiple styles that belong to the
+// user, so they can be compared.
+func CompareStyleHistory(c *fiber.Ctx) error {
+	u, _ := User(c)
+
+	// Tokens bound to a style don't have scopes.
+	if u.StyleID == 0 && !util.ContainsString(u.Scopes, "style") {
+		return c.Status(403).
+			JSON(fiber.Map{
+				"data": "You need the \"style\" scope to do this.",
+			})
+	}
+
+	var ids []uint
+	for _, s := range strings.Split(c.Query("ids"), ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 0)
+		if err != nil || id == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"data": "Error: \"ids\" must be a comma-separated list of style IDs.",
+			})
+		}
+		ids = append(ids, uint(id))
+	}
+	if len(ids) > maxCompared {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"data": "Error: You can compare up to " + strconv.Itoa(maxCompared) + " styles.",
+		})
+	}
+
+	r, msg := parseHistoryQuery(c)
+	if r == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"data": msg})
+	}
+
+	styles, err := models.FindStylesByIDs(database.Conn, ids)
+	if err != nil {
+		log.Database.Printf("Failed to find styles %v: %s\n", ids, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"data": "Error: Couldn't find styles.",
+		})
+	}
+	if len(styles) != len(unique(ids)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"data": "Error: Couldn't find styles with IDs.",
+		})
+	}
+	for _, s := range styles {
+		if s.UserID != u.ID || (u.StyleID != 0 && s.ID != u.StyleID) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"data": "Error: This style doesn't belong to you! ╰༼⇀︿⇀༽つ-]═──",
+			})
+		}
+	}
+
+	if err = r.load(styles); err != nil {
+		log.Database.Printf("Failed to get history of styles %v: %s\n", ids, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"data": "Error: Couldn't get style history.",
+		})
+	}
+
+	return r.send(c, "styles-history")
+}
+
+func unique(ids []uint) map[uint]bool {
+	m := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
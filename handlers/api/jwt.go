@@ -3,6 +3,7 @@ package api
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt"
@@ -10,15 +11,73 @@ import (
 	jwtware "userstyles.world/handlers/jwt"
 	"userstyles.world/models"
 	"userstyles.world/modules/errors"
+	"userstyles.world/modules/log"
 	"userstyles.world/modules/util"
 )
 
-var ParseAPIJWT = jwtware.New("apiUser", func(t *jwt.Token) (any, error) {
+func oauthKey(t *jwt.Token) (any, error) {
 	if t.Method.Alg() != jwtware.SigningMethod {
 		return nil, errors.UnexpectedSigningMethod(t.Method.Alg())
 	}
 	return util.OAuthPSigningKey, nil
-})
+}
+
+var parseOAuthJWT = jwtware.New("apiUser", oauthKey)
+
+// ParseAPIJWT authenticates API requests with OAuth access tokens or personal
+// access tokens.
+func ParseAPIJWT(c *fiber.Ctx) error {
+	auth := c.Get(fiber.HeaderAuthorization)
+	l := len("Bearer ")
+	if len(auth) <= l || !strings.EqualFold(auth[:l], "Bearer ") ||
+		!strings.HasPrefix(auth[l:], models.AccessTokenPrefix) {
+		return parseOAuthJWT(c)
+	}
+
+	t, err := models.FindAccessToken(util.HashToken(auth[l:]))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).
+			JSON(fiber.Map{
+				"data": "Your personal access token is invalid or has expired.",
+			})
+	}
+
+	if time.Since(t.LastUsedAt) > time.Minute {
+		if err := t.UpdateLastUsed(); err != nil {
+			log.Database.Printf("Failed to update access token %d: %s\n", t.ID, err)
+		}
+	}
+
+	c.Locals("apiToken", t)
+	return c.Next()
+}
+
+// hasValidToken checks if a request has a valid OAuth access token or personal
+// access token, for auth-less routes that don't go through ParseAPIJWT.
+func hasValidToken(c *fiber.Ctx) bool {
+	auth := c.Get(fiber.HeaderAuthorization)
+	l := len("Bearer ")
+	if len(auth) <= l || !strings.EqualFold(auth[:l], "Bearer ") {
+		return false
+	}
+
+	if strings.HasPrefix(auth[l:], models.AccessTokenPrefix) {
+		t, err := models.FindAccessToken(util.HashToken(auth[l:]))
+		if err != nil {
+			return false
+		}
+		c.Locals("apiToken", t)
+	} else {
+		t, err := jwt.Parse(auth[l:], oauthKey)
+		if err != nil || !t.Valid {
+			return false
+		}
+		c.Locals("apiUser", t)
+	}
+
+	_, ok := User(c)
+	return ok
+}
 
 func ProtectedAPI(c *fiber.Ctx) error {
 	if _, ok := User(c); !ok {
@@ -48,7 +107,35 @@ type JWTAPIUser struct {
 	StyleID uint
 }
 
+// legacyTokensUntil is when access tokens from before grants stop working.
+// Those tokens don't expire and can't be revoked, so applications have until
+// then to ask users for permission again.
+var legacyTokensUntil = time.Date(2027, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+// apiUser is the result of authenticating a request, which is kept in locals
+// so that users are looked up once per request.
+type apiUser struct {
+	u  *JWTAPIUser
+	ok bool
+}
+
+// User returns the user that an API request was made for.
 func User(c *fiber.Ctx) (*JWTAPIUser, bool) {
+	if r, ok := c.Locals("apiUserResult").(apiUser); ok {
+		return r.u, r.ok
+	}
+
+	u, ok := findUser(c)
+	c.Locals("apiUserResult", apiUser{u, ok})
+
+	return u, ok
+}
+
+func findUser(c *fiber.Ctx) (*JWTAPIUser, bool) {
+	if t, ok := c.Locals("apiToken").(*models.AccessToken); ok {
+		return tokenUser(t)
+	}
+
 	s := MapClaim(c)
 	u := &JWTAPIUser{}
 
@@ -63,6 +150,22 @@ func User(c *fiber.Ctx) (*JWTAPIUser, bool) {
 	}
 	userID := strconv.Itoa(int(fUserID))
 
+	// Access tokens stop working once their grant is revoked.  Tokens from
+	// before grants have neither a grant nor an expiration date.
+	if grantID, ok := s["grant"].(float64); ok {
+		g, err := models.FindOAuthGrant(uint(grantID))
+		if err != nil || g.UserID != uint(fUserID) {
+			return u, false
+		}
+		if time.Since(g.LastUsedAt) > time.Minute {
+			if err := g.UpdateLastUsed(); err != nil {
+				log.Database.Printf("Failed to update grant %d: %s\n", g.ID, err)
+			}
+		}
+	} else if _, ok := s["exp"]; ok || time.Now().After(legacyTokensUntil) {
+		return u, false
+	}
+
 	user, err := models.FindUserByID(userID)
 	if err != nil || user.ID == 0 {
 		return u, false
@@ -82,3 +185,21 @@ func User(c *fiber.Ctx) (*JWTAPIUser, bool) {
 	}
 	return u, true
 }
+
+// tokenUser returns the owner of a personal access token.
+func tokenUser(t *models.AccessToken) (*JWTAPIUser, bool) {
+	u := &JWTAPIUser{}
+
+	user, err := models.FindUserByID(strconv.Itoa(int(t.UserID)))
+	if err != nil || user.ID == 0 {
+		return u, false
+	}
+
+	u.Username = user.Username
+	u.Email = user.Email
+	u.ID = user.ID
+	u.Role = user.Role
+	u.Scopes = t.Scopes
+
+	return u, true
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+const (
+	// sourceDays is how many days of installs are counted by default.
+	sourceDays = 30
+
+	// maxSourceDays limits how far back installs are counted, as older raw
+	// stats can be removed by stats retention.
+	maxSourceDays = 90
+)
+
+type sourceReport struct {
+	Days    int                  `json:"days"`
+	Sources []models.SourceCount `json:"sources"`
+}
+
+// sendInstallSources responds with where installs of a style, or all styles
+// if id is zero, came from in the last few days.
+func sendInstallSources(c *fiber.Ctx, id int) error {
+	days := c.QueryInt("days", sourceDays)
+	if days < 1 || days > maxSourceDays {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"data": "Error: Days must be between 1 and 90.",
+		})
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	sources, err := models.GetInstallSources(database.Conn, id, since)
+	if err != nil {
+		log.Database.Printf("Failed to get install sources of style %d: %s\n", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"data": "Error: Couldn't get install sources.",
+		})
+	}
+	if sources == nil {
+		sources = []models.SourceCount{}
+	}
+
+	return c.JSON(sourceReport{Days: days, Sources: sources})
+}
+
+// GetStyleSources returns where installs of a style came from.
+func GetStyleSources(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"data": "Error: Couldn't parse param \"id\"",
+		})
+	}
+
+	return sendInstallSources(c, id)
+}
+
+// GetSiteSources returns where installs of all styles came from.
+func GetSiteSources(c *fiber.Ctx) error {
+	return sendInstallSources(c, 0)
+}
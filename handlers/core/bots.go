@@ -0,0 +1,21 @@
+package core
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/modules/botfilter"
+)
+
+// agentsShown limits how many filtered user agents are listed.
+const agentsShown = 100
+
+// BotsGet shows user agents that were kept out of stats the most.
+func BotsGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	c.Locals("User", u)
+	c.Locals("Title", "Filtered bots")
+	c.Locals("Agents", botfilter.TopAgents(agentsShown))
+
+	return c.Render("core/bots", fiber.Map{})
+}
@@ -20,5 +20,8 @@ func Routes(app *fiber.App) {
 	r.Get("/security-policy", Redirect("/docs/security"))
 	r.Get("/sitemap.xml", GetSiteMap)
 	r.Get("/monitor/*", jwtware.Protected, Monitor)
-	r.Get("/dashboard", jwtware.Protected, Dashboard)
+	r.Get("/dashboard", jwtware.Protected, jwtware.TwoFactor, Dashboard)
+	r.Get("/dashboard/bots", jwtware.Protected, jwtware.Admin, jwtware.TwoFactor, BotsGet)
+	r.Get("/dashboard/emails", jwtware.Protected, jwtware.Admin, jwtware.TwoFactor, middleware.Alert, EmailsGet)
+	r.Post("/dashboard/emails/:id/retry", jwtware.Protected, jwtware.Admin, jwtware.TwoFactor, EmailRetryPost)
 }
@@ -13,6 +13,7 @@ import (
 	"userstyles.world/models"
 	"userstyles.world/modules/charts"
 	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
 	"userstyles.world/modules/log"
 	"userstyles.world/modules/storage"
 )
@@ -232,9 +233,27 @@ func Dashboard(c *fiber.Ctx) error {
 		}
 	}
 
+	// Get install sources of the last month, for all styles and a chosen one.
+	month := time.Now().AddDate(0, 0, -30)
+	sources, err := models.GetInstallSources(database.Conn, 0, month)
+	if err != nil {
+		log.Database.Println("Failed to get install sources:", err.Error())
+	}
+	var styleSources []models.SourceCount
+	sourceStyle := c.QueryInt("sources")
+	if sourceStyle > 0 {
+		styleSources, err = models.GetInstallSources(database.Conn, sourceStyle, month)
+		if err != nil {
+			log.Database.Println("Failed to get install sources:", err.Error())
+		}
+	}
+
 	return c.Render("core/dashboard", fiber.Map{
 		"Title":        "Dashboard",
 		"User":         u,
+		"Sources":      sources,
+		"SourceStyle":  sourceStyle,
+		"StyleSources": styleSources,
 		"TotalStyles":  totalStyles,
 		"LatestStyle":  latestStyle,
 		"TotalUsers":   totalUsers,
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+// emailsShown limits how many emails are listed per status.
+const emailsShown = 50
+
+// EmailsGet shows pending and failed emails in the outbox.
+func EmailsGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	c.Locals("User", u)
+	c.Locals("Title", "Email outbox")
+
+	for status, key := range map[models.EmailStatus]string{
+		models.EmailPending: "Pending",
+		models.EmailFailed:  "Failed",
+	} {
+		emails, err := models.GetOutgoingEmails(database.Conn, status, emailsShown)
+		if err != nil {
+			log.Database.Printf("Failed to get %s emails: %s\n", status, err)
+			c.Locals("Title", "Failed to find emails")
+			return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{})
+		}
+		c.Locals(key, emails)
+
+		n, err := models.CountOutgoingEmails(database.Conn, status)
+		if err != nil {
+			log.Database.Printf("Failed to count %s emails: %s\n", status, err)
+		}
+		c.Locals(key+"Count", n)
+	}
+
+	return c.Render("core/emails", fiber.Map{})
+}
+
+// EmailRetryPost moves a failed email back to the outbox.
+func EmailRetryPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	c.Locals("User", u)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		c.Locals("Title", "Invalid email ID")
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{})
+	}
+
+	if err = models.RetryOutgoingEmail(database.Conn, uint(id)); err != nil {
+		log.Database.Printf("Failed to retry email %d: %s\n", id, err)
+		c.Locals("Title", "Email not found")
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{})
+	}
+	log.Info.Printf("kind=email-retry-manual id=%d username=%s\n", id, u.Username)
+
+	a := models.NewSuccessAlert("Email has been moved back to the outbox.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/dashboard/emails", fiber.StatusSeeOther)
+}
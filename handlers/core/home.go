@@ -11,6 +11,9 @@ import (
 	"userstyles.world/modules/storage"
 )
 
+// trendingShown is how many trending styles are shown on the home page.
+const trendingShown = 8
+
 func Home(c *fiber.Ctx) error {
 	u, _ := jwt.User(c)
 
@@ -49,10 +52,23 @@ Styles:
 		goto Styles
 	}
 
+	// Trending styles are only updated nightly.
+	trending, found := cache.Store.Get("trendingStyles")
+	if !found {
+		styles, err := storage.FindStyleCardsTrending(trendingShown)
+		if err != nil {
+			log.Warn.Println("Couldn't get trending styles, due", err)
+		} else {
+			cache.Store.Set("trendingStyles", styles, 5*time.Minute)
+			trending = styles
+		}
+	}
+
 	return c.Render("core/home", fiber.Map{
-		"Title":  "Website themes and skins",
-		"User":   u,
-		"Styles": featured,
+		"Title":    "Website themes and skins",
+		"User":     u,
+		"Styles":   featured,
+		"Trending": trending,
 		// "Stats":  stats,
 	})
 }
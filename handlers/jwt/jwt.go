@@ -45,6 +45,21 @@ var Admin = func(c *fiber.Ctx) error {
 	return c.Next()
 }
 
+// TwoFactor requires moderators and admins to sign in with two-factor
+// authentication before they can perform moderation actions.
+var TwoFactor = func(c *fiber.Ctx) error {
+	u, ok := User(c)
+	if ok && u.IsModOrAdmin() && !u.TwoFactor {
+		return c.Status(fiber.StatusForbidden).Render("err", fiber.Map{
+			"Title":    "Two-factor authentication is required",
+			"ErrTitle": "Enable two-factor authentication and sign in again to continue",
+			"User":     u,
+		})
+	}
+
+	return c.Next()
+}
+
 func MapClaim(c *fiber.Ctx) lib.MapClaims {
 	user, ok := c.Locals("user").(*lib.Token)
 	if !ok {
@@ -78,6 +93,9 @@ func User(c *fiber.Ctx) (*models.APIUser, bool) {
 	if role, ok := s["role"].(float64); ok {
 		u.Role = models.Role(role)
 	}
+	if mfa, ok := s["mfa"].(bool); ok {
+		u.TwoFactor = mfa
+	}
 
 	return u, true
 }
@@ -0,0 +1,158 @@
+package jwt
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	lib "github.com/golang-jwt/jwt"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/email"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+const (
+	// deviceCookie identifies a browser across sessions.
+	deviceCookie = "device"
+
+	// deviceIDSize is how many random bytes identify a device.
+	deviceIDSize = 32
+
+	// revokeKind marks tokens in links that revoke a session.
+	revokeKind = "revoke"
+)
+
+// NewLogin creates a session for a user who passed all required factors.  It
+// keeps a record of the sign-in, and notifies users about sign-ins from new
+// devices.
+func NewLogin(c *fiber.Ctx, u *models.User, expiration time.Time, mfa bool, method string) error {
+	s, err := newSession(c, u, expiration, mfa)
+	if err != nil {
+		return err
+	}
+
+	if err := u.ResetFailedLogins(); err != nil {
+		log.Database.Printf("Failed to reset failed logins for %d: %s\n", u.ID, err)
+	}
+	if err := u.UpdateLastLogin(); err != nil {
+		log.Database.Printf("Failed to update last login for %d: %s\n", u.ID, err)
+	}
+
+	device := deviceID(c)
+	known, history, err := models.KnownDevice(u.ID, device)
+	if err != nil {
+		log.Database.Printf("Failed to check devices of %d: %s\n", u.ID, err)
+		known = true
+	}
+
+	ip, err := util.HashIP(c.IP())
+	if err != nil {
+		log.Warn.Printf("Failed to hash IP for %d: %s\n", u.ID, err)
+	}
+
+	l := &models.Login{
+		UserID:    u.ID,
+		SID:       s.SID,
+		IPHash:    ip,
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		Method:    method,
+		Device:    device,
+	}
+	if err := models.CreateLogin(l); err != nil {
+		log.Database.Printf("Failed to record login for %d: %s\n", u.ID, err)
+	}
+	log.Info.Printf("kind=login id=%d username=%s method=%s\n", u.ID, u.Username, method)
+
+	// Every device is new to users without sign-in history.
+	if !known && history {
+		notifyLogin(u, s, method)
+	}
+
+	return nil
+}
+
+// deviceID returns a hash of the device cookie, which is set if it's missing.
+func deviceID(c *fiber.Ctx) string {
+	id := c.Cookies(deviceCookie)
+	if len(id) != 2*deviceIDSize {
+		id = util.RandomString(deviceIDSize)
+		c.Cookie(&fiber.Cookie{
+			Name:     deviceCookie,
+			Value:    id,
+			Path:     "/",
+			Expires:  time.Now().Add(365 * 24 * time.Hour),
+			Secure:   config.Production,
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+	}
+
+	return util.HashToken(id)
+}
+
+// notifyLogin emails users about a sign-in from a new device.
+func notifyLogin(u *models.User, s *models.Session, method string) {
+	link, err := revokeLink(s)
+	if err != nil {
+		log.Warn.Printf("Failed to create revoke link for %d: %s\n", u.ID, err)
+		return
+	}
+
+	args := map[string]any{
+		"User":   u,
+		"Device": s.Device,
+		"IP":     s.IP,
+		"Method": method,
+		"Time":   s.CreatedAt.UTC().Format("2006-01-02 15:04 MST"),
+		"Link":   link,
+	}
+	if err := email.Send("user/login", u.Email, "New sign-in to your account", args); err != nil {
+		log.Warn.Printf("Failed to send login email to %d: %s\n", u.ID, err)
+	}
+}
+
+// revokeLink returns a link that revokes a session without signing in.
+func revokeLink(s *models.Session) (string, error) {
+	t, err := util.NewJWT().
+		SetClaim("kind", revokeKind).
+		SetClaim("id", s.UserID).
+		SetClaim("sid", s.SID).
+		SetExpiration(s.ExpiresAt).
+		GetSignedString(util.VerifySigningKey)
+	if err != nil {
+		return "", err
+	}
+
+	return config.BaseURL + "/revoke/" + util.EncryptText(t, util.AEADCrypto, config.ScrambleConfig), nil
+}
+
+// ParseRevokeKey returns user ID and SID from a link that revokes a session.
+func ParseRevokeKey(key string) (uint, string, bool) {
+	text, err := util.DecryptText(key, util.AEADCrypto, config.ScrambleConfig)
+	if err != nil {
+		return 0, "", false
+	}
+
+	token, err := lib.Parse(text, util.VerifyJwtKeyFunction)
+	if err != nil || !token.Valid {
+		return 0, "", false
+	}
+
+	claims, ok := token.Claims.(lib.MapClaims)
+	if !ok || claims["kind"] != revokeKind {
+		return 0, "", false
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return 0, "", false
+	}
+	sid, ok := claims["sid"].(string)
+	if !ok || sid == "" {
+		return 0, "", false
+	}
+
+	return uint(id), sid, true
+}
@@ -0,0 +1,282 @@
+package jwt
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	lib "github.com/golang-jwt/jwt"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+const (
+	twoFactorCookie = "2fa"
+
+	// sessionLifetime limits sessions that end when browser closes.
+	sessionLifetime = 14 * 24 * time.Hour
+
+	// sessionTouch limits how often last-seen data is updated.
+	sessionTouch = 5 * time.Minute
+)
+
+// legacySessionsUntil is when tokens from before sessions, which end when
+// browser closes, stop being upgraded.  Other tokens expire on their own.
+var legacySessionsUntil = time.Date(2026, time.November, 16, 0, 0, 0, 0, time.UTC)
+
+// NewSession signs a token for a user and stores it in a cookie.  Zero value
+// for expiration creates a cookie that will be removed when browser closes.
+// Setting mfa marks sessions that passed two-factor authentication.
+func NewSession(c *fiber.Ctx, u *models.User, expiration time.Time, mfa bool) error {
+	_, err := newSession(c, u, expiration, mfa)
+	return err
+}
+
+func newSession(c *fiber.Ctx, u *models.User, expiration time.Time, mfa bool) (*models.Session, error) {
+	// Replace current session, e.g. after enabling two-factor authentication.
+	if sid := SessionID(c); sid != "" {
+		if err := RevokeSession(sid); err != nil {
+			log.Database.Printf("Failed to revoke session for %d: %s\n", u.ID, err)
+		}
+	}
+
+	s := &models.Session{
+		SID:        util.RandomString(32),
+		UserID:     u.ID,
+		ExpiresAt:  expiration,
+		LastSeenAt: time.Now(),
+		IP:         c.IP(),
+		Device:     util.DescribeUserAgent(c.Get(fiber.HeaderUserAgent)),
+	}
+	if s.ExpiresAt.IsZero() {
+		s.ExpiresAt = time.Now().Add(sessionLifetime)
+	}
+	if err := models.CreateSession(s); err != nil {
+		return nil, err
+	}
+
+	t, err := util.NewJWT().
+		SetClaim("id", u.ID).
+		SetClaim("name", u.Username).
+		SetClaim("email", u.Email).
+		SetClaim("role", u.Role).
+		SetClaim("mfa", mfa).
+		SetClaim("sid", s.SID).
+		SetExpiration(expiration).
+		GetSignedString(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     fiber.HeaderAuthorization,
+		Value:    t,
+		Path:     "/",
+		Expires:  expiration,
+		Secure:   config.Production,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return s, nil
+}
+
+// SessionID returns SID of current session.
+func SessionID(c *fiber.Ctx) string {
+	sid, _ := MapClaim(c)["sid"].(string)
+	return sid
+}
+
+// findSession returns a valid session, which is cached to avoid querying the
+// database on every request.
+func findSession(sid string) (models.Session, bool) {
+	k := "session " + sid
+	if v, ok := cache.Store.Get(k); ok {
+		s := v.(models.Session)
+		return s, s.Valid()
+	}
+
+	s, err := models.FindSession(sid)
+	if err != nil || !s.Valid() {
+		return models.Session{}, false
+	}
+	cache.Store.Set(k, *s, sessionTouch)
+
+	return *s, true
+}
+
+// CheckSession makes sure that session tokens haven't been revoked, and keeps
+// track of when and where sessions were last used.
+var CheckSession = func(c *fiber.Ctx) error {
+	claims := MapClaim(c)
+	if claims == nil {
+		return c.Next()
+	}
+
+	sid, ok := claims["sid"].(string)
+	if !ok {
+		upgradeSession(c, claims)
+		return c.Next()
+	}
+
+	id, _ := claims["id"].(float64)
+	s, ok := findSession(sid)
+	if !ok || s.UserID != uint(id) {
+		endSession(c)
+		return c.Next()
+	}
+
+	if ip := c.IP(); ip != s.IP || time.Since(s.LastSeenAt) > sessionTouch {
+		if err := s.Touch(ip); err != nil {
+			log.Database.Printf("Failed to update session for %d: %s\n", s.UserID, err)
+		}
+		cache.Store.Set("session "+sid, s, sessionTouch)
+	}
+
+	return c.Next()
+}
+
+// upgradeSession replaces cookies from before sessions with a session that
+// keeps their expiration, so that users aren't signed out.
+func upgradeSession(c *fiber.Ctx, claims lib.MapClaims) {
+	exp, hasExp := claims["exp"].(float64)
+	if c.Cookies(fiber.HeaderAuthorization) == "" ||
+		!hasExp && time.Now().After(legacySessionsUntil) {
+		endSession(c)
+		return
+	}
+
+	id, _ := claims["id"].(float64)
+	u, err := models.FindUserByID(strconv.FormatUint(uint64(id), 10))
+	if err != nil {
+		endSession(c)
+		return
+	}
+
+	var expiration time.Time
+	if hasExp {
+		expiration = time.Unix(int64(exp), 0)
+	}
+	mfa, _ := claims["mfa"].(bool)
+
+	if _, err = newSession(c, u, expiration, mfa); err != nil {
+		log.Database.Printf("Failed to upgrade session for %d: %s\n", u.ID, err)
+	}
+}
+
+// endSession signs out users with invalid session tokens.
+func endSession(c *fiber.Ctx) {
+	c.Locals("user", nil)
+	if c.Cookies(fiber.HeaderAuthorization) != "" {
+		c.ClearCookie(fiber.HeaderAuthorization)
+	}
+}
+
+// RevokeSession removes a session.
+func RevokeSession(sid string) error {
+	cache.Store.Delete("session " + sid)
+	return models.DeleteSession(sid)
+}
+
+// RevokeUserSession removes one of user's sessions.
+func RevokeUserSession(id, uid uint) error {
+	sid, err := models.RevokeSession(id, uid)
+	if err != nil {
+		return err
+	}
+	cache.Store.Delete("session " + sid)
+
+	return nil
+}
+
+// RevokeSessions removes all of user's sessions, except for the kept one.
+func RevokeSessions(uid uint, keep string) error {
+	sids, err := models.RevokeSessions(database.Conn, uid, keep)
+	if err != nil {
+		return err
+	}
+	for _, sid := range sids {
+		cache.Store.Delete("session " + sid)
+	}
+
+	return nil
+}
+
+// StartTwoFactor stores a short-lived proof that user passed the first factor,
+// which is exchanged for a session after the second factor is verified.
+func StartTwoFactor(c *fiber.Ctx, u *models.User, expiration time.Time) error {
+	var session int64
+	if !expiration.IsZero() {
+		session = expiration.Unix()
+	}
+
+	t, err := util.NewJWT().
+		SetClaim("id", u.ID).
+		SetClaim("kind", twoFactorCookie).
+		SetClaim("session", session).
+		SetExpiration(time.Now().Add(5 * time.Minute)).
+		GetSignedString(util.VerifySigningKey)
+	if err != nil {
+		return err
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     twoFactorCookie,
+		Value:    util.EncryptText(t, util.AEADCrypto, config.ScrambleConfig),
+		Path:     "/login",
+		Expires:  time.Now().Add(5 * time.Minute),
+		Secure:   config.Production,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+
+	return nil
+}
+
+// PendingTwoFactor returns user ID and requested session expiration for users
+// who passed the first factor.
+func PendingTwoFactor(c *fiber.Ctx) (uint, time.Time, bool) {
+	text, err := util.DecryptText(c.Cookies(twoFactorCookie), util.AEADCrypto, config.ScrambleConfig)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+
+	token, err := lib.Parse(text, util.VerifyJwtKeyFunction)
+	if err != nil || !token.Valid {
+		return 0, time.Time{}, false
+	}
+
+	claims, ok := token.Claims.(lib.MapClaims)
+	if !ok || claims["kind"] != twoFactorCookie {
+		return 0, time.Time{}, false
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+
+	var expiration time.Time
+	if session, ok := claims["session"].(float64); ok && session > 0 {
+		expiration = time.Unix(int64(session), 0)
+	}
+
+	return uint(id), expiration, true
+}
+
+// ClearTwoFactor removes the proof of the first factor.
+func ClearTwoFactor(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     twoFactorCookie,
+		Path:     "/login",
+		Expires:  time.Now().Add(-time.Hour),
+		Secure:   config.Production,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+}
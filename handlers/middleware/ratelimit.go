@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/modules/ratelimit"
+)
+
+// KeyFunc identifies who made a request.
+type KeyFunc func(c *fiber.Ctx) string
+
+// ByIP identifies clients by their IP address.
+func ByIP(c *fiber.Ctx) string {
+	return "ip " + c.IP()
+}
+
+// ByUser identifies signed-in users by their ID, and others by IP address.
+func ByUser(c *fiber.Ctx) string {
+	if u, ok := jwt.User(c); ok {
+		return "user " + strconv.FormatUint(uint64(u.ID), 10)
+	}
+	return ByIP(c)
+}
+
+// RateLimit middleware limits requests with a policy, sharing a bucket between
+// requests with the same key.
+func RateLimit(policy string, key KeyFunc) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		l := ratelimit.Get(policy)
+		if l == nil {
+			return c.Next()
+		}
+
+		r := l.Allow(key(c), time.Now())
+		c.Set("RateLimit-Policy", l.Policy.String())
+		c.Set("RateLimit-Limit", strconv.Itoa(r.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(int(r.Reset.Seconds())))
+		if r.Allowed {
+			return c.Next()
+		}
+
+		retry := int(r.RetryAfter.Seconds())
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retry))
+
+		msg := fmt.Sprintf("Too many requests, try again in %d seconds.", retry)
+		if strings.HasPrefix(c.Path(), "/api/") {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"data": msg,
+			})
+		}
+
+		u, _ := jwt.User(c)
+		return c.Status(fiber.StatusTooManyRequests).Render("err", fiber.Map{
+			"Title": msg,
+			"User":  u,
+		})
+	}
+}
@@ -1,109 +1,348 @@
 package oauthprovider
 
 import (
-	"fmt"
+	"encoding/base64"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt"
 
+	"userstyles.world/handlers/middleware"
 	"userstyles.world/models"
+	"userstyles.world/modules/cache"
 	"userstyles.world/modules/config"
 	"userstyles.world/modules/log"
 	"userstyles.world/modules/util"
 )
 
-func TokenPost(c *fiber.Ctx) error {
-	clientID, clientSecret, stateQuery, tCode :=
-		c.FormValue("client_id"), c.FormValue("client_secret"), c.FormValue("state"), c.FormValue("code")
+const (
+	// codeLifetime limits how long authorization codes can be exchanged.
+	codeLifetime = 10 * time.Minute
+
+	// accessTokenLifetime limits how long access tokens can be used.
+	accessTokenLifetime = time.Hour
+
+	// refreshTokenLifetime limits how long unused grants are kept alive.
+	refreshTokenLifetime = 90 * 24 * time.Hour
+)
+
+// tokenError returns an error response as described in RFC 6749.
+// https://www.rfc-editor.org/rfc/rfc6749#section-5.2
+func tokenError(c *fiber.Ctx, status int, code, description string) error {
+	if status == fiber.StatusUnauthorized && c.Get(fiber.HeaderAuthorization) != "" {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="userstyles.world"`)
+	}
 
-	if clientID == "" {
-		return errorMessage(c, 400, "No client_id specified")
+	return c.Status(status).
+		JSON(fiber.Map{
+			"error":             code,
+			"error_description": description,
+		})
+}
+
+// unsealToken decrypts and verifies tokens that are sent to clients.
+func unsealToken(s string) (jwt.MapClaims, bool) {
+	text, err := util.DecryptText(s, util.AEADOAuthp, config.ScrambleConfig)
+	if err != nil {
+		return nil, false
 	}
-	if clientSecret == "" {
-		return errorMessage(c, 400, "No client_secret specified")
+
+	token, err := jwt.Parse(text, util.OAuthPJwtKeyFunction)
+	if err != nil || !token.Valid {
+		return nil, false
 	}
-	if tCode == "" {
-		return errorMessage(c, 400, "No code specified")
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
+
+// basicAuth returns client credentials from the Authorization header.
+// https://www.rfc-editor.org/rfc/rfc6749#section-2.3.1
+func basicAuth(c *fiber.Ctx) (string, string, bool) {
+	auth := c.Get(fiber.HeaderAuthorization)
+	l := len("Basic ")
+	if len(auth) <= l || !strings.EqualFold(auth[:l], "Basic ") {
+		return "", "", false
 	}
 
-	oauth, err := models.GetOAuthByClientID(clientID)
-	if err != nil || oauth.ID == 0 {
-		return errorMessage(c, 400, "Incorrect client_id specified")
+	b, err := base64.StdEncoding.DecodeString(auth[l:])
+	if err != nil {
+		return "", "", false
 	}
-	if oauth.ClientSecret != clientSecret {
-		return errorMessage(c, 400, "Incorrect client_secret specified")
+
+	id, secret, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return "", "", false
 	}
 
-	unsealedText, err := util.DecryptText(tCode, util.AEADOAuthp, config.ScrambleConfig)
+	id, err = url.QueryUnescape(id)
+	if err != nil {
+		return "", "", false
+	}
+	secret, err = url.QueryUnescape(secret)
 	if err != nil {
-		log.Warn.Println("Failed to unseal JWT text:", err.Error())
-		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
+		return "", "", false
 	}
 
-	token, err := jwt.Parse(unsealedText, util.OAuthPJwtKeyFunction)
-	if err != nil || !token.Valid {
-		log.Warn.Println("Failed to unseal JWT token:", err.Error())
-		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
+	return id, secret, true
+}
+
+// byClient identifies requests by client ID and IP address, and others by IP
+// address.  It's used to limit attempts to guess client secrets.  Client IDs
+// of public clients aren't secret, so they can't be used alone, or anyone
+// could use up the limit of all of the application's users.
+func byClient(c *fiber.Ctx) string {
+	id := c.FormValue("client_id")
+	if bid, _, ok := basicAuth(c); ok {
+		id = bid
 	}
+	if id == "" {
+		return middleware.ByIP(c)
+	}
+	return "client " + id + " " + middleware.ByIP(c)
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		log.Warn.Println("Failed to parse JWT Token:", err.Error())
-		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
+// clientAuth authenticates an OAuth application.  Public clients only have to
+// identify themselves, as they can't keep a secret.
+func clientAuth(c *fiber.Ctx) (*models.APIOAuth, bool) {
+	id, secret := c.FormValue("client_id"), c.FormValue("client_secret")
+	if bid, bsecret, ok := basicAuth(c); ok {
+		id, secret = bid, bsecret
+	}
+	if id == "" {
+		return nil, false
 	}
 
-	state, ok := claims["state"].(string)
-	if !ok {
-		log.Warn.Println("Invalid JWT state.")
-		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
+	oauth, err := models.GetOAuthByClientID(id)
+	if err != nil {
+		return nil, false
+	}
+	if oauth.Public {
+		return oauth, true
 	}
 
-	floatUserID, ok := claims["userID"].(float64)
+	return oauth, oauth.CheckSecret(secret)
+}
+
+// TokenPost exchanges authorization codes and refresh tokens for access tokens.
+// https://www.rfc-editor.org/rfc/rfc6749#section-3.2
+func TokenPost(c *fiber.Ctx) error {
+	// Responses contain credentials, which must not be stored anywhere.
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	oauth, ok := clientAuth(c)
 	if !ok {
-		log.Warn.Println("Failed to get userID from parsed token.")
-		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
+		return tokenError(c, fiber.StatusUnauthorized, "invalid_client",
+			"Client authentication failed.")
 	}
-	userID := uint(floatUserID)
 
-	fStyleID, ok := claims["styleID"].(float64)
-	if !ok {
-		fStyleID = 0
+	// Older clients don't specify grant type.
+	switch c.FormValue("grant_type", "authorization_code") {
+	case "authorization_code":
+		return exchangeCode(c, oauth)
+	case "refresh_token":
+		return exchangeRefreshToken(c, oauth)
+	default:
+		return tokenError(c, fiber.StatusBadRequest, "unsupported_grant_type",
+			"Grant type must be authorization_code or refresh_token.")
+	}
+}
+
+func exchangeCode(c *fiber.Ctx, oauth *models.APIOAuth) error {
+	code := c.FormValue("code")
+	if code == "" {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_request", "No code specified.")
+	}
+
+	claims, ok := unsealToken(code)
+	if !ok || claims["kind"] != "code" {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Code is invalid or has expired.")
+	}
+
+	oauthID, _ := claims["oauthID"].(float64)
+	if uint(oauthID) != oauth.ID {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Code was issued to another client.")
+	}
+
+	r := newAuthRequest(claims)
+	if s := c.FormValue("state"); s != "" && s != r.State {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "State doesn't match.")
+	}
+	if r.RedirectURI != "" && c.FormValue("redirect_uri") != r.RedirectURI {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Redirect URI doesn't match.")
+	}
+
+	switch {
+	case r.Challenge != "":
+		if !verifyChallenge(r.Challenge, r.Method, c.FormValue("code_verifier")) {
+			return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Code verifier doesn't match.")
+		}
+	case oauth.Public:
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Public clients must use PKCE.")
 	}
 
-	if stateQuery != state {
-		return errorMessage(c, 500, "State doesn't match.")
+	// Codes can be exchanged only once.
+	err := cache.Store.Add("oauth-code "+util.HashToken(code), true, codeLifetime)
+	if err != nil {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Code has already been used.")
 	}
 
-	user, err := models.FindUserByID(fmt.Sprintf("%d", userID))
+	userID, _ := claims["userID"].(float64)
+	user, err := models.FindUserByID(strconv.Itoa(int(userID)))
 	if err != nil || user.ID == 0 {
-		return errorMessage(c, 500, "Couldn't find the user that was specified, Error: Please notify the UserStyles.world admins.")
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Couldn't find the user.")
 	}
 
-	var jwtToken string
+	g := &models.OAuthGrant{
+		LastUsedAt: time.Now(),
+		OAuthID:    oauth.ID,
+		UserID:     user.ID,
+	}
 
-	if styleID := uint(fStyleID); styleID != 0 {
-		jwtToken, err = util.NewJWT().
-			SetClaim("styleID", styleID).
-			SetClaim("userID", user.ID).
-			GetSignedString(util.OAuthPSigningKey)
+	// Style tokens only give access to a single style.
+	if styleID, ok := claims["styleID"].(float64); ok && styleID != 0 {
+		g.StyleID = uint(styleID)
 	} else {
-		jwtToken, err = util.NewJWT().
-			SetClaim("scopes", strings.Join(oauth.Scopes, ",")).
-			SetClaim("userID", user.ID).
-			GetSignedString(util.OAuthPSigningKey)
+		g.Scopes = grantedScopes(oauth, r.Scope)
+	}
+
+	refreshToken := util.RandomString(64)
+	g.RefreshHash = util.HashToken(refreshToken)
+	g.ExpiresAt = time.Now().Add(refreshTokenLifetime)
+	if err = models.CreateOAuthGrant(g); err != nil {
+		log.Database.Printf("Failed to create grant for %d: %s\n", user.ID, err)
+		return tokenError(c, fiber.StatusInternalServerError, "server_error", "Failed to create grant.")
+	}
+
+	return issueTokens(c, oauth, g, refreshToken, r.Nonce)
+}
+
+func exchangeRefreshToken(c *fiber.Ctx, oauth *models.APIOAuth) error {
+	refreshToken := c.FormValue("refresh_token")
+	if refreshToken == "" {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_request", "No refresh_token specified.")
 	}
 
+	g, err := models.FindOAuthGrantByRefresh(util.HashToken(refreshToken))
+	if err != nil || g.OAuthID != oauth.ID {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Refresh token is invalid or has expired.")
+	}
+
+	refreshToken = util.RandomString(64)
+	err = g.Rotate(util.HashToken(refreshToken), time.Now().Add(refreshTokenLifetime))
 	if err != nil {
-		return errorMessage(c, 500, "Couldn't create access_token Error: Please notify the UserStyles.world admins.")
+		return tokenError(c, fiber.StatusBadRequest, "invalid_grant", "Refresh token is invalid or has expired.")
 	}
 
-	if c.Accepts("application/json", "text/plain ") == "application/json" {
-		return c.JSON(fiber.Map{
-			"access_token": jwtToken,
-			"token_type":   "Bearer",
-		})
+	return issueTokens(c, oauth, g, refreshToken, "")
+}
+
+// grantedScopes returns requested scopes that are allowed for an OAuth
+// application, or all of its scopes if none were requested.
+func grantedScopes(oauth *models.APIOAuth, scope string) []string {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return oauth.Scopes
+	}
+
+	var s []string
+	for _, v := range requested {
+		if v == scopeOpenID || util.ContainsString(oauth.Scopes, v) {
+			s = append(s, v)
+		}
+	}
+
+	return s
+}
+
+// issueTokens returns a short-lived access token along with a refresh token,
+// and an ID token if the openid scope was granted.
+// https://www.rfc-editor.org/rfc/rfc6749#section-5.1
+func issueTokens(c *fiber.Ctx, oauth *models.APIOAuth, g *models.OAuthGrant, refreshToken, nonce string) error {
+	t := util.NewJWT().
+		SetClaim("userID", g.UserID).
+		SetClaim("grant", g.ID).
+		SetExpiration(time.Now().Add(accessTokenLifetime))
+	if g.StyleID != 0 {
+		t.SetClaim("styleID", g.StyleID)
+	} else {
+		t.SetClaim("scopes", strings.Join(g.Scopes, ","))
+	}
+
+	accessToken, err := t.GetSignedString(util.OAuthPSigningKey)
+	if err != nil {
+		log.Warn.Println("Failed to create access token:", err)
+		return tokenError(c, fiber.StatusInternalServerError, "server_error", "Failed to create access token.")
+	}
+
+	res := fiber.Map{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(accessTokenLifetime.Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         strings.Join(g.Scopes, " "),
+	}
+
+	if util.ContainsString(g.Scopes, scopeOpenID) {
+		idToken, err := newIDToken(oauth, g, nonce)
+		if err != nil {
+			log.Warn.Println("Failed to create ID token:", err)
+			return tokenError(c, fiber.StatusInternalServerError, "server_error", "Failed to create ID token.")
+		}
+		res["id_token"] = idToken
+	}
+
+	return c.JSON(res)
+}
+
+// accessTokenGrant returns ID of the grant that an access token belongs to.
+func accessTokenGrant(s string) (uint, bool) {
+	token, err := jwt.Parse(s, util.OAuthPJwtKeyFunction)
+	if err != nil || !token.Valid {
+		return 0, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, false
+	}
+
+	id, ok := claims["grant"].(float64)
+	return uint(id), ok
+}
+
+// RevokePost revokes grants of access and refresh tokens.  Responses don't
+// reveal whether a token was valid.
+// https://www.rfc-editor.org/rfc/rfc7009#section-2
+func RevokePost(c *fiber.Ctx) error {
+	oauth, ok := clientAuth(c)
+	if !ok {
+		return tokenError(c, fiber.StatusUnauthorized, "invalid_client",
+			"Client authentication failed.")
+	}
+
+	token := c.FormValue("token")
+	if token == "" {
+		return tokenError(c, fiber.StatusBadRequest, "invalid_request", "No token specified.")
+	}
+
+	var id uint
+	if g, err := models.FindOAuthGrantByRefresh(util.HashToken(token)); err == nil {
+		id = g.ID
+	} else if gid, ok := accessTokenGrant(token); ok {
+		id = gid
+	}
+
+	if id != 0 {
+		if err := models.DeleteOAuthGrant(id, oauth.ID); err != nil {
+			log.Database.Printf("Failed to revoke grant %d: %s\n", id, err)
+			return tokenError(c, fiber.StatusServiceUnavailable, "server_error", "Failed to revoke token.")
+		}
 	}
 
-	return c.SendString(jwtToken + "&token_type=Bearer")
+	return c.SendStatus(fiber.StatusOK)
 }
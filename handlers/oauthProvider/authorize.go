@@ -1,7 +1,7 @@
 package oauthprovider
 
 import (
-	"strconv"
+	"net/url"
 	"strings"
 	"time"
 
@@ -11,6 +11,7 @@ import (
 	jwtware "userstyles.world/handlers/jwt"
 	"userstyles.world/models"
 	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
 	"userstyles.world/modules/log"
 	"userstyles.world/modules/util"
 )
@@ -22,13 +23,14 @@ func errorMessage(c *fiber.Ctx, status int, errorMessage string) error {
 		})
 }
 
-func redirectFunction(c *fiber.Ctx, state, redirectURI string) error {
+func redirectFunction(c *fiber.Ctx, r authRequest, oauth *models.APIOAuth) error {
 	u, _ := jwtware.User(c)
 
-	jwtToken, err := util.NewJWT().
-		SetClaim("state", state).
+	jwtToken, err := r.sign(util.NewJWT()).
+		SetClaim("kind", "code").
+		SetClaim("oauthID", oauth.ID).
 		SetClaim("userID", u.ID).
-		SetExpiration(time.Now().Add(time.Minute * 10)).
+		SetExpiration(time.Now().Add(codeLifetime)).
 		GetSignedString(util.OAuthPSigningKey)
 	if err != nil {
 		log.Warn.Println("Failed to create a JWT Token:", err.Error())
@@ -36,11 +38,52 @@ func redirectFunction(c *fiber.Ctx, state, redirectURI string) error {
 	}
 
 	returnCode := "?code=" + util.EncryptText(jwtToken, util.AEADOAuthp, config.ScrambleConfig)
-	if state != "" {
-		returnCode += "&state=" + state
+	if r.State != "" {
+		returnCode += "&state=" + url.QueryEscape(r.State)
 	}
 
-	return c.Redirect(redirectURI + "/" + returnCode)
+	return c.Redirect(oauth.RedirectURI + "/" + returnCode)
+}
+
+// parseAuthRequest validates parameters of an authorization request.
+// https://www.rfc-editor.org/rfc/rfc7636#section-4.3
+func parseAuthRequest(c *fiber.Ctx, oauth *models.APIOAuth) (authRequest, string) {
+	r := authRequest{
+		State:     c.Query("state"),
+		Challenge: c.Query("code_challenge"),
+		Method:    c.Query("code_challenge_method"),
+		Nonce:     c.Query("nonce"),
+	}
+
+	r.RedirectURI = c.Query("redirect_uri")
+	if r.RedirectURI != "" && strings.TrimSuffix(r.RedirectURI, "/") != oauth.RedirectURI {
+		return r, "Redirect URI doesn't match the OAuth's settings."
+	}
+
+	if r.Challenge == "" {
+		if oauth.Public {
+			return r, "Public clients must use PKCE."
+		}
+		return r, ""
+	}
+
+	switch r.Method {
+	case "":
+		r.Method = "plain"
+		fallthrough
+	case "plain":
+		if !validPKCE(r.Challenge) {
+			return r, "Invalid code_challenge specified."
+		}
+	case "S256":
+		if len(r.Challenge) != 43 {
+			return r, "Invalid code_challenge specified."
+		}
+	default:
+		return r, "Unsupported code_challenge_method specified."
+	}
+
+	return r, ""
 }
 
 func AuthorizeGet(c *fiber.Ctx) error {
@@ -51,7 +94,7 @@ func AuthorizeGet(c *fiber.Ctx) error {
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/X-Frame-Options
 	c.Response().Header.Set("X-Frame-Options", "DENY")
 
-	clientID, state, scope := c.Query("client_id"), c.Query("state"), c.Query("scope")
+	clientID, scope := c.Query("client_id"), c.Query("scope")
 	if clientID == "" {
 		return errorMessage(c, 400, "No client_id specified")
 	}
@@ -60,33 +103,44 @@ func AuthorizeGet(c *fiber.Ctx) error {
 		return errorMessage(c, 400, "Incorrect client_id specified")
 	}
 
-	user, err := models.FindUserByName(u.Username)
-	if err != nil {
-		return errorMessage(c, 500, "Notify the admins.")
+	r, msg := parseAuthRequest(c, oauth)
+	if msg != "" {
+		return errorMessage(c, 400, msg)
 	}
 
-	// Check if the user has already authorized this OAuth application.
-	if util.ContainsString(user.AuthorizedOAuth, strconv.Itoa(int(oauth.ID))) {
-		return redirectFunction(c, state, oauth.RedirectURI)
-	}
-
-	// Convert it to actual []string
-	scopes := strings.Split(scope, " ")
+	// Scopes are space-delimited, but older clients use commas.
+	scopes := strings.Fields(strings.ReplaceAll(scope, ",", " "))
+	r.Scope = strings.Join(scopes, " ")
 
 	// Just check if the application has actually set if they will request these scopes.
 	if !util.EveryString(scopes, func(name string) bool {
-		return util.ContainsString(oauth.Scopes, name)
+		return name == scopeOpenID || util.ContainsString(oauth.Scopes, name)
 	}) {
 		return errorMessage(c, 400, "An scope was provided which isn't selected in the OAuth's settings selection.")
 	}
 
+	user, err := models.FindUserByName(u.Username)
+	if err != nil {
+		return errorMessage(c, 500, "Notify the admins.")
+	}
+
+	// Skip the consent screen if the user already gave the requested scopes to
+	// this OAuth application, and ask again if it requests more.
+	consent, ok, err := models.FindOAuthConsent(database.Conn, user, oauth)
+	if err != nil {
+		log.Database.Printf("Failed to find consent of %d for %d: %s\n", user.ID, oauth.ID, err)
+		return errorMessage(c, 500, "Notify the admins.")
+	}
+	if ok && consent.Covers(grantedScopes(oauth, r.Scope)) {
+		return redirectFunction(c, r, oauth)
+	}
+
 	// User has to authorize within 2 hours.
 	// To migate any weird attack we include the ID of the user that wishes to authorize.
 	// Such that this key cannot be replaced by some other user.
 	// And to follow our weird state-less design we include the state.
 	// Thus not storing the state.
-	jwtToken, err := util.NewJWT().
-		SetClaim("state", state).
+	jwtToken, err := r.sign(util.NewJWT()).
 		SetClaim("userID", u.ID).
 		SetExpiration(time.Now().Add(time.Hour * 2)).
 		GetSignedString(util.OAuthPSigningKey)
@@ -103,6 +157,9 @@ func AuthorizeGet(c *fiber.Ctx) error {
 	for _, v := range oauth.Scopes {
 		arguments["Scope_"+v] = true
 	}
+	if util.ContainsString(scopes, scopeOpenID) {
+		arguments["Scope_"+scopeOpenID] = true
+	}
 
 	return c.Render("oauth/authorize", arguments)
 }
@@ -146,11 +203,26 @@ func AuthPost(c *fiber.Ctx) error {
 		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
 	}
 
-	user.AuthorizedOAuth = append(user.AuthorizedOAuth, oauthID)
-	if err = models.UpdateUser(user); err != nil {
-		log.Warn.Printf("Failed to update user %d: %v\n", user.ID, err)
+	if !util.ContainsString(user.AuthorizedOAuth, oauthID) {
+		user.AuthorizedOAuth = append(user.AuthorizedOAuth, oauthID)
+		if err = models.UpdateUser(user); err != nil {
+			log.Warn.Printf("Failed to update user %d: %v\n", user.ID, err)
+			return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
+		}
+	}
+
+	// The consent screen shows all of the application's scopes, and openid
+	// if it was requested.
+	r := newAuthRequest(claims)
+	consent := &models.OAuthConsent{UserID: user.ID, OAuthID: oauth.ID}
+	consent.Scopes = append(consent.Scopes, oauth.Scopes...)
+	if util.ContainsString(strings.Fields(r.Scope), scopeOpenID) {
+		consent.Scopes = append(consent.Scopes, scopeOpenID)
+	}
+	if err = models.SaveOAuthConsent(database.Conn, consent); err != nil {
+		log.Database.Printf("Failed to save consent of %d for %d: %s\n", user.ID, oauth.ID, err)
 		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
 	}
 
-	return redirectFunction(c, claims["state"].(string), oauth.RedirectURI)
+	return redirectFunction(c, r, oauth)
 }
@@ -2,6 +2,7 @@ package oauthprovider
 
 import (
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -23,7 +24,7 @@ func OAuthStyleGet(c *fiber.Ctx) error {
 	// https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/X-Frame-Options
 	c.Response().Header.Set("X-Frame-Options", "DENY")
 
-	clientID, state, vendorData := c.Query("client_id"), c.Query("state"), c.Query("vendor_data")
+	clientID, vendorData := c.Query("client_id"), c.Query("vendor_data")
 	if clientID == "" {
 		return errorMessage(c, 400, "No client_id specified")
 	}
@@ -32,13 +33,17 @@ func OAuthStyleGet(c *fiber.Ctx) error {
 		return errorMessage(c, 400, "Incorrect client_id specified")
 	}
 
+	r, msg := parseAuthRequest(c, oauth)
+	if msg != "" {
+		return errorMessage(c, 400, msg)
+	}
+
 	// User has to authorize within 2 hours.
 	// To migate any weird attack we include the ID of the user that wishes to authorize.
 	// Such that this key cannot be replaced by some other user.
 	// And to follow our weird state-less design we include the state.
 	// Thus not storing the state.
-	jwtToken, err := util.NewJWT().
-		SetClaim("state", state).
+	jwtToken, err := r.sign(util.NewJWT()).
 		SetClaim("userID", u.ID).
 		SetExpiration(time.Now().Add(time.Hour * 2)).
 		GetSignedString(util.OAuthPSigningKey)
@@ -107,11 +112,11 @@ func OAuthStylePost(c *fiber.Ctx) error {
 		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
 	}
 
-	state, ok := claims["state"].(string)
-	if !ok {
+	if _, ok = claims["state"].(string); !ok {
 		log.Warn.Println("Invalid JWT state.")
 		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
 	}
+	r := newAuthRequest(claims)
 
 	style, err := models.GetStyleByID(styleID)
 	if err != nil {
@@ -124,11 +129,12 @@ func OAuthStylePost(c *fiber.Ctx) error {
 		return errorMessage(c, 500, "Error: Please notify the UserStyles.world admins.")
 	}
 
-	jwtToken, err := util.NewJWT().
-		SetClaim("state", state).
+	jwtToken, err := r.sign(util.NewJWT()).
+		SetClaim("kind", "code").
+		SetClaim("oauthID", oauth.ID).
 		SetClaim("userID", u.ID).
 		SetClaim("styleID", style.ID).
-		SetExpiration(time.Now().Add(time.Minute * 10)).
+		SetExpiration(time.Now().Add(codeLifetime)).
 		GetSignedString(util.OAuthPSigningKey)
 	if err != nil {
 		log.Warn.Println("Failed to create JWT Token:", err.Error())
@@ -137,8 +143,8 @@ func OAuthStylePost(c *fiber.Ctx) error {
 
 	returnCode := "?code=" + util.EncryptText(jwtToken, util.AEADOAuthp, config.ScrambleConfig)
 	returnCode += "&style_id=" + styleID
-	if state != "" {
-		returnCode += "&state=" + state
+	if r.State != "" {
+		returnCode += "&state=" + url.QueryEscape(r.State)
 	}
 
 	return c.Redirect(oauth.RedirectURI + "/" + returnCode)
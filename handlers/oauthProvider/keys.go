@@ -0,0 +1,169 @@
+package oauthprovider
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+const (
+	// keyRotation limits how long a key is used to sign ID tokens.
+	keyRotation = 30 * 24 * time.Hour
+
+	// keyLifetime limits how long a key is published after it was created,
+	// so that clients can verify tokens signed before a rotation.
+	keyLifetime = 2 * keyRotation
+)
+
+var errorInvalidSigningKey = errors.New("invalid signing key")
+
+type signingKey struct {
+	kid     string
+	key     *rsa.PrivateKey
+	created time.Time
+}
+
+// keys holds published signing keys, newest first.
+var keys struct {
+	sync.Mutex
+	list []signingKey
+}
+
+// decodeSigningKey decrypts a stored signing key.
+func decodeSigningKey(k models.SigningKey) (signingKey, error) {
+	text, err := util.DecryptText(k.PrivateKey, util.AEADCrypto, config.ScrambleConfig)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		return signingKey{}, errorInvalidSigningKey
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	return signingKey{kid: k.KID, key: key, created: k.CreatedAt}, nil
+}
+
+// newSigningKey generates and stores a new signing key.
+func newSigningKey() (signingKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	b := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	k := models.SigningKey{
+		KID:        util.RandomString(8),
+		PrivateKey: util.EncryptText(string(b), util.AEADCrypto, config.ScrambleConfig),
+	}
+	if err = models.CreateSigningKey(&k); err != nil {
+		return signingKey{}, err
+	}
+
+	return signingKey{kid: k.KID, key: key, created: k.CreatedAt}, nil
+}
+
+// signingKeys returns published keys, and rotates them when the newest one
+// is due to be replaced.
+func signingKeys() ([]signingKey, error) {
+	keys.Lock()
+	defer keys.Unlock()
+
+	if len(keys.list) > 0 && time.Since(keys.list[0].created) < keyRotation {
+		return keys.list, nil
+	}
+
+	stored, err := models.GetSigningKeys(time.Now().Add(-keyLifetime))
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]signingKey, 0, len(stored)+1)
+	for _, v := range stored {
+		k, err := decodeSigningKey(v)
+		if err != nil {
+			log.Warn.Printf("Failed to decode signing key %s: %s\n", v.KID, err)
+			continue
+		}
+		list = append(list, k)
+	}
+
+	if len(list) == 0 || time.Since(list[0].created) >= keyRotation {
+		k, err := newSigningKey()
+		if err != nil {
+			return nil, err
+		}
+		list = append([]signingKey{k}, list...)
+		log.Info.Printf("Rotated ID token signing key to %s.\n", k.kid)
+
+		if err = models.DeleteSigningKeys(time.Now().Add(-keyLifetime)); err != nil {
+			log.Database.Printf("Failed to delete old signing keys: %s\n", err)
+		}
+	}
+
+	keys.list = list
+
+	return keys.list, nil
+}
+
+// signIDToken signs claims of an ID token with a key.
+func signIDToken(k signingKey, claims jwt.MapClaims) (string, error) {
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = k.kid
+	return t.SignedString(k.key)
+}
+
+// jwk returns the public part of a key as described in RFC 7517.
+func jwk(k signingKey) fiber.Map {
+	e := big.NewInt(int64(k.key.E)).Bytes()
+
+	return fiber.Map{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": k.kid,
+		"n":   base64.RawURLEncoding.EncodeToString(k.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(e),
+	}
+}
+
+// JWKSGet publishes keys that are used to verify ID tokens.
+func JWKSGet(c *fiber.Ctx) error {
+	list, err := signingKeys()
+	if err != nil {
+		log.Warn.Println("Failed to get signing keys:", err)
+		return errorMessage(c, 500, "Failed to get signing keys.")
+	}
+
+	set := make([]fiber.Map, 0, len(list))
+	for _, k := range list {
+		set = append(set, jwk(k))
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+
+	return c.JSON(fiber.Map{"keys": set})
+}
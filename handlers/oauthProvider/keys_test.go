@@ -0,0 +1,61 @@
+package oauthprovider
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestSignIDToken(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := signingKey{kid: "test", key: key}
+
+	s, err := signIDToken(k, jwt.MapClaims{"sub": "1", "aud": "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the token with the published key, like clients would.
+	pub := jwk(k)
+	n, err := base64.RawURLEncoding.DecodeString(pub["n"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(pub["e"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pub["e"]; got != "AQAB" {
+		t.Errorf("got: %q, expected: %q", got, "AQAB")
+	}
+
+	token, err := jwt.Parse(s, func(t *jwt.Token) (any, error) {
+		if t.Header["kid"] != pub["kid"] {
+			return nil, jwt.ErrInvalidKey
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	})
+	if err != nil || !token.Valid {
+		t.Fatalf("failed to verify ID token: %v", err)
+	}
+	if alg := token.Method.Alg(); alg != "RS256" {
+		t.Errorf("got: %q, expected: %q", alg, "RS256")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["sub"] != "1" || claims["aud"] != "client" {
+		t.Errorf("unexpected claims: %v", claims)
+	}
+}
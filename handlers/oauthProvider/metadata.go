@@ -0,0 +1,34 @@
+package oauthprovider
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+)
+
+// serverMetadata describes the authorization server.
+func serverMetadata() fiber.Map {
+	authMethods := []string{"client_secret_basic", "client_secret_post", "none"}
+
+	return fiber.Map{
+		"issuer":                                     config.BaseURL,
+		"authorization_endpoint":                     config.BaseURL + "/api/oauth/auth",
+		"token_endpoint":                             config.BaseURL + "/api/oauth/token",
+		"revocation_endpoint":                        config.BaseURL + "/api/oauth/revoke",
+		"jwks_uri":                                   config.BaseURL + "/api/oauth/jwks",
+		"service_documentation":                      config.BaseURL + "/docs/oauth",
+		"scopes_supported":                           append([]string{scopeOpenID}, models.APIScopes...),
+		"response_types_supported":                   []string{"code"},
+		"grant_types_supported":                      []string{"authorization_code", "refresh_token"},
+		"code_challenge_methods_supported":           []string{"S256", "plain"},
+		"token_endpoint_auth_methods_supported":      authMethods,
+		"revocation_endpoint_auth_methods_supported": authMethods,
+	}
+}
+
+// MetadataGet describes the authorization server, so that clients can be
+// configured automatically.  https://www.rfc-editor.org/rfc/rfc8414
+func MetadataGet(c *fiber.Ctx) error {
+	return c.JSON(serverMetadata())
+}
@@ -2,14 +2,17 @@ package oauthprovider
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	val "github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 
 	"userstyles.world/handlers/jwt"
 	"userstyles.world/models"
+	"userstyles.world/modules/cache"
 	"userstyles.world/modules/log"
 	"userstyles.world/modules/util"
 	"userstyles.world/modules/validator"
@@ -22,77 +25,63 @@ const (
 	methodEdit
 )
 
+// secretKey is used to show a new client secret once after a redirect.
+func secretKey(uid, id uint) string {
+	return fmt.Sprintf("oauth-secret %d %d", uid, id)
+}
+
 func OAuthSettingsGet(c *fiber.Ctx) error {
 	u, _ := jwt.User(c)
 
-	var method OAuthSettingMethod
-	var oauth *models.APIOAuth
-	var err error
-	if id := c.Params("id"); id != "" {
-		method = methodEdit
-		oauth, err = models.GetOAuthByID(id)
-	} else {
-		method = methodAdd
+	arguments := fiber.Map{
+		"Title":  "OAuth Settings",
+		"User":   u,
+		"Method": methodAdd,
 	}
 
-	if err != nil {
-		log.Warn.Printf("Failed to OAuth %d: %s\n", oauth.ID, err.Error())
-		return c.Render("err", fiber.Map{
-			"Title": "Internal server error",
-			"User":  u,
-		})
-	}
+	if id := c.Params("id"); id != "" {
+		oauth, err := models.GetOAuthByID(id)
+		if err != nil {
+			log.Warn.Printf("Failed to find OAuth %s: %s\n", id, err)
+			return c.Render("err", fiber.Map{
+				"Title": "OAuth application not found",
+				"User":  u,
+			})
+		}
 
-	if method == methodEdit {
 		if u.ID != oauth.UserID {
 			return c.Render("err", fiber.Map{
 				"Title": "Users don't match",
 				"User":  u,
 			})
 		}
-	}
-	oauths, err := models.ListOAuthsOfUser(u.Username)
-	if err != nil {
-		if method == methodEdit {
-			arguments := fiber.Map{
-				"Title":  "OAuth Settings",
-				"User":   u,
-				"OAuth":  oauth,
-				"OAuths": nil,
-				"Method": method,
-			}
-			for _, v := range oauth.Scopes {
-				arguments["Scope_"+v] = true
-			}
-			return c.Render("oauth/settings", arguments)
-		}
-		return c.Render("oauth/settings", fiber.Map{
-			"Title":  "OAuth Settings",
-			"User":   u,
-			"OAuths": nil,
-			"Method": method,
-		})
-	}
 
-	if method == methodEdit {
-		arguments := fiber.Map{
-			"Title":  "OAuth Settings",
-			"User":   u,
-			"OAuth":  oauth,
-			"OAuths": oauths,
-			"Method": method,
-		}
+		arguments["Method"] = methodEdit
+		arguments["OAuth"] = oauth
 		for _, v := range oauth.Scopes {
 			arguments["Scope_"+v] = true
 		}
-		return c.Render("oauth/settings", arguments)
+
+		stats, err := models.GetOAuthStats(oauth.ID)
+		if err != nil {
+			log.Database.Printf("Failed to get stats for OAuth %d: %s\n", oauth.ID, err)
+		}
+		arguments["Stats"] = stats
+
+		k := secretKey(u.ID, oauth.ID)
+		if secret, ok := cache.Store.Get(k); ok {
+			cache.Store.Delete(k)
+			arguments["Secret"] = secret
+		}
 	}
-	return c.Render("oauth/settings", fiber.Map{
-		"Title":  "OAuth Settings",
-		"User":   u,
-		"OAuths": oauths,
-		"Method": method,
-	})
+
+	oauths, err := models.ListOAuthsOfUser(u.Username)
+	if err != nil {
+		oauths = &[]models.APIOAuth{}
+	}
+	arguments["OAuths"] = oauths
+
+	return c.Render("oauth/settings", arguments)
 }
 
 func OAuthSettingsPost(c *fiber.Ctx) error {
@@ -103,9 +92,10 @@ func OAuthSettingsPost(c *fiber.Ctx) error {
 		Name:        c.FormValue("name"),
 		Description: c.FormValue("description"),
 		RedirectURI: strings.TrimSuffix(c.FormValue("redirect_uri"), "/"),
-		Scopes: util.Filter([]string{"style", "user"}, func(name any) bool {
+		Scopes: util.Filter(models.APIScopes, func(name any) bool {
 			return c.FormValue(name.(string)) == "on"
 		}).([]string),
+		Public: c.FormValue("public") == "on",
 		UserID: u.ID,
 	}
 
@@ -129,24 +119,74 @@ func OAuthSettingsPost(c *fiber.Ctx) error {
 			Render("oauth/settings", arguments)
 	}
 
-	var err error
-	var dbOAuth *models.OAuth
 	if id != "" {
-		err = models.UpdateOAuth(&q, id)
-	} else {
-		q.ClientID = util.RandomString(32)
-		q.ClientSecret = util.RandomString(128)
-		dbOAuth, err = models.CreateOAuth(&q)
+		oauth, err := models.GetOAuthByID(id)
+		if err != nil || oauth.UserID != u.ID {
+			return c.Render("err", fiber.Map{
+				"Title": "Users don't match",
+				"User":  u,
+			})
+		}
+
+		if err = models.UpdateOAuth(&q, id); err != nil {
+			log.Warn.Printf("Updating OAuth settings for %v failed: %s\n", id, err.Error())
+			return c.Render("err", fiber.Map{
+				"Title": "Internal server error.",
+				"User":  u,
+			})
+		}
+
+		return c.Redirect("/api/oauth/settings/"+id, fiber.StatusSeeOther)
+	}
+
+	// Public clients don't get a secret, as they can't keep it.
+	var secret string
+	q.ClientID = util.RandomString(32)
+	if !q.Public {
+		secret = util.RandomString(128)
+		q.ClientSecret = util.HashToken(secret)
 	}
 
+	dbOAuth, err := models.CreateOAuth(&q)
 	if err != nil {
-		log.Warn.Printf("Updating OAuth settings for %v failed: %s\n", id, err.Error())
+		log.Warn.Printf("Creating OAuth for %d failed: %s\n", u.ID, err.Error())
 		return c.Render("err", fiber.Map{
 			"Title": "Internal server error.",
 			"User":  u,
 		})
 	}
 
+	if secret != "" {
+		cache.Store.Set(secretKey(u.ID, dbOAuth.ID), secret, time.Minute)
+	}
+
 	oauthID := strconv.FormatUint(uint64(dbOAuth.ID), 10)
-	return c.Redirect("/oauth/settings/"+oauthID, fiber.StatusSeeOther)
+	return c.Redirect("/api/oauth/settings/"+oauthID, fiber.StatusSeeOther)
+}
+
+// OAuthSecretPost replaces the client secret of an OAuth application, which
+// is the only way to recover from a lost or leaked secret.
+func OAuthSecretPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	id := c.Params("id")
+
+	oauth, err := models.GetOAuthByID(id)
+	if err != nil || oauth.UserID != u.ID {
+		return c.Render("err", fiber.Map{
+			"Title": "Users don't match",
+			"User":  u,
+		})
+	}
+
+	secret := util.RandomString(128)
+	if err = models.UpdateClientSecret(oauth.ID, secret); err != nil {
+		log.Database.Printf("Failed to update client secret for %d: %s\n", oauth.ID, err)
+		return c.Render("err", fiber.Map{
+			"Title": "Failed to generate a new client secret",
+			"User":  u,
+		})
+	}
+	cache.Store.Set(secretKey(u.ID, oauth.ID), secret, time.Minute)
+
+	return c.Redirect("/api/oauth/settings/"+id, fiber.StatusSeeOther)
 }
@@ -0,0 +1,100 @@
+package oauthprovider
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt"
+
+	"userstyles.world/handlers/api"
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/util"
+)
+
+// scopeOpenID lets applications sign users in with OpenID Connect.  It can be
+// requested by any application, as it only identifies the user.
+const scopeOpenID = "openid"
+
+// newIDToken returns a signed ID token for a grant.
+// https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+func newIDToken(oauth *models.APIOAuth, g *models.OAuthGrant, nonce string) (string, error) {
+	list, err := signingKeys()
+	if err != nil {
+		return "", err
+	}
+
+	user, err := models.FindUserByID(strconv.Itoa(int(g.UserID)))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                config.BaseURL,
+		"sub":                strconv.Itoa(int(user.ID)),
+		"aud":                oauth.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(accessTokenLifetime).Unix(),
+		"auth_time":          g.CreatedAt.Unix(),
+		"preferred_username": user.Username,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	return signIDToken(list[0], claims)
+}
+
+// UserinfoGet returns claims about the user that authorized an application.
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+func UserinfoGet(c *fiber.Ctx) error {
+	u, ok := api.User(c)
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return c.SendStatus(fiber.StatusUnauthorized)
+	}
+	if !util.ContainsString(u.Scopes, scopeOpenID) {
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="insufficient_scope", scope="openid"`)
+		return c.SendStatus(fiber.StatusForbidden)
+	}
+
+	user, err := models.FindUserByName(u.Username)
+	if err != nil {
+		return errorMessage(c, 500, "Error: Couldn't find user.")
+	}
+
+	claims := fiber.Map{
+		"sub":                strconv.Itoa(int(user.ID)),
+		"preferred_username": user.Username,
+		"name":               user.Name(),
+		"profile":            config.BaseURL + "/user/" + user.Username,
+	}
+
+	// Other fields need the same scope as in the API.
+	if util.ContainsString(u.Scopes, "user") {
+		claims["username"] = user.Username
+		claims["email"] = user.Email
+		claims["biography"] = user.Biography
+		claims["role"] = user.RoleString()
+		claims["socials"] = user.Socials
+	}
+
+	return c.JSON(claims)
+}
+
+// OpenIDConfigurationGet describes the OpenID provider.
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+func OpenIDConfigurationGet(c *fiber.Ctx) error {
+	m := serverMetadata()
+	m["userinfo_endpoint"] = config.BaseURL + "/api/oauth/userinfo"
+	m["subject_types_supported"] = []string{"public"}
+	m["id_token_signing_alg_values_supported"] = []string{"RS256"}
+	m["claims_supported"] = []string{
+		"iss", "sub", "aud", "iat", "exp", "auth_time", "nonce",
+		"preferred_username", "name", "profile", "email",
+	}
+
+	return c.JSON(m)
+}
@@ -0,0 +1,87 @@
+package oauthprovider
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+
+	"userstyles.world/modules/util"
+)
+
+// authRequest holds parameters of an authorization request, which are carried
+// in consent tokens and authorization codes until the code is exchanged.
+type authRequest struct {
+	State     string
+	Scope     string
+	Challenge string
+	Method    string
+	Nonce     string
+
+	// RedirectURI is kept if it was sent, as it has to be sent again when the
+	// code is exchanged.  https://www.rfc-editor.org/rfc/rfc6749#section-4.1.3
+	RedirectURI string
+}
+
+// newAuthRequest reads an authorization request from claims of a token.
+func newAuthRequest(claims jwt.MapClaims) authRequest {
+	var r authRequest
+	r.State, _ = claims["state"].(string)
+	r.Scope, _ = claims["scope"].(string)
+	r.Challenge, _ = claims["challenge"].(string)
+	r.Method, _ = claims["method"].(string)
+	r.Nonce, _ = claims["nonce"].(string)
+	r.RedirectURI, _ = claims["redirect_uri"].(string)
+	return r
+}
+
+// sign adds parameters of an authorization request to a token.
+func (r authRequest) sign(t *util.JWTTokenBuilder) *util.JWTTokenBuilder {
+	return t.
+		SetClaim("state", r.State).
+		SetClaim("scope", r.Scope).
+		SetClaim("challenge", r.Challenge).
+		SetClaim("method", r.Method).
+		SetClaim("nonce", r.Nonce).
+		SetClaim("redirect_uri", r.RedirectURI)
+}
+
+// validPKCE checks if a code verifier, or a plain code challenge, uses allowed
+// characters and length.  https://www.rfc-editor.org/rfc/rfc7636#section-4.1
+func validPKCE(s string) bool {
+	if len(s) < 43 || len(s) > 128 {
+		return false
+	}
+
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-._~", r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// verifyChallenge checks a code verifier against a code challenge.
+// https://www.rfc-editor.org/rfc/rfc7636#section-4.6
+func verifyChallenge(challenge, method, verifier string) bool {
+	if !validPKCE(verifier) {
+		return false
+	}
+
+	switch method {
+	case "S256":
+		sum := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(sum[:])
+	case "plain":
+	default:
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
@@ -0,0 +1,44 @@
+package oauthprovider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyChallenge(t *testing.T) {
+	t.Parallel()
+
+	// Example from https://www.rfc-editor.org/rfc/rfc7636#appendix-B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	cases := []struct {
+		name      string
+		challenge string
+		method    string
+		verifier  string
+		expected  bool
+	}{
+		{"s256", challenge, "S256", verifier, true},
+		{"s256 wrong verifier", challenge, "S256", strings.Repeat("a", 43), false},
+		{"s256 plain challenge", verifier, "S256", verifier, false},
+		{"plain", verifier, "plain", verifier, true},
+		{"plain wrong verifier", verifier, "plain", verifier[1:] + "a", false},
+		{"unknown method", challenge, "S512", verifier, false},
+		{"empty method", verifier, "", verifier, false},
+		{"short verifier", "abc", "plain", "abc", false},
+		{"long verifier", strings.Repeat("a", 129), "plain", strings.Repeat("a", 129), false},
+		{"invalid characters", strings.Repeat("a", 42) + "+", "plain", strings.Repeat("a", 42) + "+", false},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			got := verifyChallenge(c.challenge, c.method, c.verifier)
+			if got != c.expected {
+				t.Errorf("got: %t, expected: %t", got, c.expected)
+			}
+		})
+	}
+}
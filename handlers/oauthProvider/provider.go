@@ -5,6 +5,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 
 	jwtware "userstyles.world/handlers/jwt"
+	"userstyles.world/handlers/middleware"
 )
 
 // Routes provides routes for Fiber's router.
@@ -18,5 +19,13 @@ func Routes(app *fiber.App) {
 	r.Get("/style/new", jwtware.Protected, OAuthStyleNewPost)
 	r.Post("/style/new", jwtware.Protected, OAuthStyleNewPost)
 	r.Post("/auth/:id/:token", jwtware.Protected, AuthPost)
-	r.Post("/token", TokenPost)
+	r.Post("/token", middleware.RateLimit("token", byClient), TokenPost)
+	r.Post("/revoke", middleware.RateLimit("token", byClient), RevokePost)
+	r.Get("/jwks", JWKSGet)
+	r.Get("/userinfo", UserinfoGet)
+	r.Post("/userinfo", UserinfoGet)
+	r.Post("/settings/:id/secret", jwtware.Protected, OAuthSecretPost)
+
+	app.Get("/.well-known/oauth-authorization-server", MetadataGet)
+	app.Get("/.well-known/openid-configuration", OpenIDConfigurationGet)
 }
@@ -1,6 +1,7 @@
 package review
 
 import (
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,7 +10,9 @@ import (
 	"userstyles.world/handlers/jwt"
 	"userstyles.world/models"
 	"userstyles.world/modules/cache"
+	"userstyles.world/modules/config"
 	"userstyles.world/modules/database"
+	"userstyles.world/modules/email"
 	"userstyles.world/modules/log"
 )
 
@@ -101,8 +104,17 @@ func createForm(c *fiber.Ctx) error {
 		ReviewID: int(r.ID),
 	}
 
-	if err = models.CreateNotification(database.Conn, &n); err != nil {
-		log.Warn.Printf("Failed to add notification to review %d: %s\n", r.ID, err)
+	ch, err := models.NotificationChannel(database.Conn, s.UserID, n.Kind)
+	if err != nil {
+		log.Database.Printf("Failed to get notification settings for %d: %s\n", s.UserID, err)
+	}
+	if ch != models.ChannelNone {
+		if err = models.CreateNotification(database.Conn, &n); err != nil {
+			log.Warn.Printf("Failed to add notification to review %d: %s\n", r.ID, err)
+		}
+	}
+	if ch == models.ChannelEmail {
+		sendReviewEmail(s, r, u.Username)
 	}
 
 	a := models.NewSuccessAlert("Review has been created.")
@@ -110,3 +122,26 @@ func createForm(c *fiber.Ctx) error {
 
 	return c.Redirect(r.Permalink())
 }
+
+func sendReviewEmail(s *models.APIStyle, r *models.Review, reviewer string) {
+	author, err := models.FindUserByID(strconv.Itoa(int(s.UserID)))
+	if err != nil {
+		log.Database.Printf("Failed to find author of %d: %s\n", s.ID, err)
+		return
+	}
+
+	args := fiber.Map{
+		"User":      author,
+		"Style":     s,
+		"StyleLink": config.BaseURL + "/style/" + strconv.Itoa(int(s.ID)),
+		"Review":    r,
+		"Reviewer":  reviewer,
+		"Link":      config.BaseURL + r.Permalink(),
+	}
+
+	title := "New review of your style"
+	err = email.SendNotification(author.ID, models.KindReview, "review/new", author.Email, title, args)
+	if err != nil {
+		log.Warn.Printf("Failed to email author of %d: %s\n", s.ID, err)
+	}
+}
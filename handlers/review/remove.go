@@ -110,7 +110,7 @@ func removeForm(c *fiber.Ctx) error {
 	}
 
 	title := "Your review has been removed"
-	if err := email.Send("review/remove", r.User.Email, title, args); err != nil {
+	if err := email.SendNotification(r.User.ID, models.KindRemovedReview, "review/remove", r.User.Email, title, args); err != nil {
 		log.Warn.Printf("Failed to email author for review %d: %s\n", rid, err)
 	}
 
@@ -11,7 +11,7 @@ import (
 func Routes(app *fiber.App) {
 	r := app.Group("/styles/:s-:slug/reviews")
 	r.Get("/create", jwt.Protected, createPage)
-	r.Post("/create", jwt.Protected, createForm)
+	r.Post("/create", jwt.Protected, middleware.RateLimit("review", middleware.ByUser), createForm)
 
 	r = app.Group("/styles/:s-:slug/reviews/:r", middleware.Alert)
 	r.Get("/", viewPage)
@@ -20,6 +20,6 @@ func Routes(app *fiber.App) {
 	r.Post("/edit", editForm)
 	r.Get("/delete", deletePage)
 	r.Post("/delete", deleteForm)
-	r.Get("/remove", removePage)
-	r.Post("/remove", removeForm)
+	r.Get("/remove", jwt.TwoFactor, removePage)
+	r.Post("/remove", jwt.TwoFactor, removeForm)
 }
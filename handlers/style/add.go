@@ -2,6 +2,7 @@ package style
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -166,8 +167,13 @@ func handleAPIStyle(c *fiber.Ctx, secureToken, oauthID, styleID string, style *m
 			})
 	}
 
+	// Pass PKCE parameters along to the token endpoint.
 	jwtToken, err := util.NewJWT().
+		SetClaim("kind", "code").
+		SetClaim("oauthID", oauth.ID).
 		SetClaim("state", state).
+		SetClaim("challenge", claims["challenge"]).
+		SetClaim("method", claims["method"]).
 		SetClaim("userID", u.ID).
 		SetClaim("styleID", style.ID).
 		SetExpiration(time.Now().Add(time.Minute * 10)).
@@ -183,7 +189,7 @@ func handleAPIStyle(c *fiber.Ctx, secureToken, oauthID, styleID string, style *m
 	returnCode := "?code=" + util.EncryptText(jwtToken, util.AEADOAuthp, config.ScrambleConfig)
 	returnCode += "&style_id=" + styleID
 	if state != "" {
-		returnCode += "&state=" + state
+		returnCode += "&state=" + url.QueryEscape(state)
 	}
 
 	return c.Redirect(oauth.RedirectURI + "/" + returnCode)
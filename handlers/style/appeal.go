@@ -0,0 +1,242 @@
+package style
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/email"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/storage"
+)
+
+// findAppealableLog returns a style removal entry and its notification, which
+// also verifies that the logged-in user is the author of the removed style.
+func findAppealableLog(c *fiber.Ctx, uid uint) (*models.Log, *models.Notification, bool) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		c.Locals("Title", "Invalid modlog entry ID")
+		c.Status(fiber.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	l, err := models.GetLog(id)
+	if err != nil || l.Kind != models.LogRemoveStyle {
+		c.Locals("Title", "Modlog entry not found")
+		c.Status(fiber.StatusNotFound)
+		return nil, nil, false
+	}
+
+	n, err := models.FindNotificationForLog(models.KindBannedStyle, l.ID, uid)
+	if err != nil {
+		c.Locals("Title", "You can only appeal removals of your own styles")
+		c.Status(fiber.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return l, n, true
+}
+
+func AppealGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	c.Locals("User", u)
+
+	l, _, ok := findAppealableLog(c, u.ID)
+	if !ok {
+		return c.Render("err", fiber.Map{})
+	}
+	c.Locals("Log", l)
+	c.Locals("Title", "Appeal style removal")
+
+	if a, err := models.FindAppealForLog(l.ID, u.ID); err == nil {
+		c.Locals("Appeal", a)
+	}
+
+	return c.Render("style/appeal", fiber.Map{})
+}
+
+func AppealPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	c.Locals("User", u)
+
+	l, n, ok := findAppealableLog(c, u.ID)
+	if !ok {
+		return c.Render("err", fiber.Map{})
+	}
+	c.Locals("Log", l)
+	c.Locals("Title", "Appeal style removal")
+
+	if _, err := models.FindAppealForLog(l.ID, u.ID); err == nil {
+		c.Locals("Title", "You have already appealed this removal")
+		return c.Status(fiber.StatusConflict).Render("err", fiber.Map{})
+	}
+
+	a := &models.Appeal{
+		Message: c.FormValue("message"),
+		LogID:   l.ID,
+		UserID:  u.ID,
+		StyleID: uint(n.StyleID),
+	}
+	if err := a.Validate(); err != nil {
+		c.Locals("Error", "Your appeal must be between 10 and 5000 characters.")
+		return c.Status(fiber.StatusBadRequest).Render("style/appeal", fiber.Map{})
+	}
+
+	if err := models.CreateAppeal(a); err != nil {
+		log.Database.Printf("Failed to create appeal for %d: %s\n", l.ID, err)
+		c.Locals("Title", "Failed to submit appeal")
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{})
+	}
+
+	alert := models.NewSuccessAlert("Your appeal has been submitted.")
+	cache.Store.Add("alert "+u.Username, alert, time.Minute)
+
+	return c.Redirect("/styles/appeal/"+strconv.Itoa(int(l.ID)), fiber.StatusSeeOther)
+}
+
+func AppealsGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	c.Locals("User", u)
+
+	if !u.IsModOrAdmin() {
+		c.Locals("Title", "You are not authorized to perform this action")
+		return c.Status(fiber.StatusUnauthorized).Render("err", fiber.Map{})
+	}
+
+	appeals, err := models.GetAppeals()
+	if err != nil {
+		log.Database.Println("Failed to get appeals:", err)
+		c.Locals("Title", "Failed to find appeals")
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{})
+	}
+	c.Locals("Appeals", appeals)
+	c.Locals("Title", "Appeals")
+
+	return c.Render("style/appeals", fiber.Map{})
+}
+
+func AppealDecisionPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+	c.Locals("User", u)
+
+	if !u.IsModOrAdmin() {
+		c.Locals("Title", "You are not authorized to perform this action")
+		return c.Status(fiber.StatusUnauthorized).Render("err", fiber.Map{})
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		c.Locals("Title", "Invalid appeal ID")
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{})
+	}
+
+	a, err := models.GetAppeal(id)
+	if err != nil {
+		c.Locals("Title", "Appeal not found")
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{})
+	}
+
+	if a.State != models.AppealPending {
+		c.Locals("Title", "This appeal has already been decided")
+		return c.Status(fiber.StatusConflict).Render("err", fiber.Map{})
+	}
+
+	var state models.AppealState
+	var kind models.Kind
+	var msg string
+	switch c.FormValue("decision") {
+	case "accept":
+		state, kind = models.AppealAccepted, models.KindAcceptedAppeal
+		msg = "Appeal accepted and userstyle restored."
+	case "reject":
+		state, kind = models.AppealRejected, models.KindRejectedAppeal
+		msg = "Appeal rejected."
+	default:
+		c.Locals("Title", "Invalid decision")
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{})
+	}
+
+	response := strings.TrimSpace(c.FormValue("response"))
+	err = database.Conn.Transaction(func(tx *gorm.DB) error {
+		if err := a.Decide(tx, state, u.ID, response); err != nil {
+			return err
+		}
+
+		if state == models.AppealAccepted {
+			if err := models.RestoreStyle(tx, a.StyleID); err != nil {
+				return err
+			}
+			if err := storage.RestoreSearchData(tx, int(a.StyleID)); err != nil {
+				return err
+			}
+		}
+
+		n := &models.Notification{
+			Kind:     kind,
+			TargetID: int(a.ID),
+			UserID:   int(a.UserID),
+			StyleID:  int(a.StyleID),
+		}
+		return models.CreateNotification(tx, n)
+	})
+	if err != nil {
+		log.Database.Printf("Failed to decide appeal %d: %s\n", a.ID, err)
+		c.Locals("Title", "Failed to decide appeal")
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{})
+	}
+
+	if state == models.AppealAccepted {
+		restoreStyleCode(int(a.StyleID))
+	}
+
+	sendAppealEmail(a)
+
+	alert := models.NewSuccessAlert(msg)
+	cache.Store.Add("alert "+u.Username, alert, time.Minute)
+
+	return c.Redirect("/styles/appeals", fiber.StatusSeeOther)
+}
+
+// restoreStyleCode writes source code of a restored userstyle back to disk.
+func restoreStyleCode(id int) {
+	code, err := storage.FindStyleCode(id)
+	if err != nil {
+		log.Database.Printf("Failed to find code for %d: %s\n", id, err)
+		return
+	}
+
+	if err = models.SaveStyleCode(strconv.Itoa(id), code); err != nil {
+		log.Warn.Printf("Failed to save code for %d: %s\n", id, err)
+		return
+	}
+
+	cache.Code.Update(id, []byte(code))
+}
+
+func sendAppealEmail(a *models.Appeal) {
+	args := fiber.Map{
+		"User":      a.User,
+		"Appeal":    a,
+		"Log":       a.Log,
+		"Accepted":  a.State == models.AppealAccepted,
+		"StyleLink": config.BaseURL + "/style/" + strconv.Itoa(int(a.StyleID)),
+	}
+
+	kind := models.KindRejectedAppeal
+	if a.State == models.AppealAccepted {
+		kind = models.KindAcceptedAppeal
+	}
+
+	title := "Your appeal has been reviewed"
+	if err := email.SendNotification(a.UserID, kind, "style/appeal", a.User.Email, title, args); err != nil {
+		log.Warn.Printf("Failed to email %d: %s\n", a.UserID, err)
+	}
+}
@@ -58,13 +58,6 @@ func BanStyle(db *gorm.DB, style *models.Style, u *models.APIUser, user *storage
 		Censor:         c.FormValue("censor") == "on",
 	}
 
-	n := &models.Notification{
-		Kind:     models.KindBannedStyle,
-		TargetID: int(event.ID),
-		UserID:   int(user.ID),
-		StyleID:  int(style.ID),
-	}
-
 	i := int(style.ID)
 	if err := storage.DeleteUserstyle(db, i); err != nil {
 		return nil, err
@@ -78,6 +71,14 @@ func BanStyle(db *gorm.DB, style *models.Style, u *models.APIUser, user *storage
 	if err := models.CreateLog(db, event); err != nil {
 		return nil, err
 	}
+
+	// Notification points to the log entry, which is needed for appeals.
+	n := &models.Notification{
+		Kind:     models.KindBannedStyle,
+		TargetID: int(event.ID),
+		UserID:   int(user.ID),
+		StyleID:  i,
+	}
 	if err := models.CreateNotification(db, n); err != nil {
 		return nil, err
 	}
@@ -146,21 +147,22 @@ func BanPost(c *fiber.Ctx) error {
 		})
 	}
 
-	go sendRemovalEmail(user, style, event)
+	sendRemovalEmail(user, style, event)
 
 	return c.Redirect("/modlog", fiber.StatusSeeOther)
 }
 
 func sendRemovalEmail(user *storage.User, style *models.Style, event *models.Log) {
 	args := fiber.Map{
-		"User":  user,
-		"Style": style,
-		"Log":   event,
-		"Link":  config.BaseURL + "/modlog#id-" + strconv.Itoa(int(event.ID)),
+		"User":   user,
+		"Style":  style,
+		"Log":    event,
+		"Link":   config.BaseURL + "/modlog#id-" + strconv.Itoa(int(event.ID)),
+		"Appeal": event.AppealLink(),
 	}
 
 	title := "Your style has been removed"
-	if err := email.Send("style/ban", user.Email, title, args); err != nil {
+	if err := email.SendNotification(uint(user.ID), models.KindBannedStyle, "style/ban", user.Email, title, args); err != nil {
 		log.Warn.Printf("Failed to email %d: %s\n", user.ID, err)
 	}
 }
@@ -80,6 +80,11 @@ func BulkBanPost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{})
 	}
 
+	if len(req.IDs) == 0 {
+		c.Locals("Title", "No userstyles were selected")
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{})
+	}
+
 	var styles []*models.Style
 
 	// Process all IDs for problems not to have any errors in between of removal
@@ -101,20 +106,18 @@ func BulkBanPost(c *fiber.Ctx) error {
 		styles = append(styles, &style)
 	}
 
-	// lastEvent is used to link to the newest event in the modlog
-	// so the user will be presented with all of them on the screen.
-	var lastEvent *models.Log
+	// events are used to link to appeal forms, and the last one to the newest
+	// event in the modlog so the user will be presented with all of them.
+	events := make([]*models.Log, 0, len(styles))
 	err = database.Conn.Transaction(func(tx *gorm.DB) error {
-		for index, style := range styles {
+		for _, style := range styles {
 			event, err := BanStyle(tx, style, u, user, c)
 			if err != nil {
 				log.Database.Printf("Failed to remove %d: %s\n", style.ID, err)
 				return err
 			}
 
-			if index == len(styles)-1 {
-				lastEvent = event
-			}
+			events = append(events, event)
 		}
 
 		return nil
@@ -124,15 +127,17 @@ func BulkBanPost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{})
 	}
 
-	go sendBulkRemovalEmail(user, styles, lastEvent)
+	sendBulkRemovalEmail(user, styles, events)
 
 	return c.Redirect("/modlog", fiber.StatusSeeOther)
 }
 
-func sendBulkRemovalEmail(user *storage.User, styles []*models.Style, event *models.Log) {
+func sendBulkRemovalEmail(user *storage.User, styles []*models.Style, events []*models.Log) {
+	event := events[len(events)-1]
 	args := fiber.Map{
 		"User":   user,
 		"Styles": styles,
+		"Events": events,
 		"Log":    event,
 		"Link":   config.BaseURL + "/modlog#id-" + strconv.Itoa(int(event.ID)),
 	}
@@ -143,7 +148,7 @@ func sendBulkRemovalEmail(user *storage.User, styles []*models.Style, event *mod
 	} else {
 		title = strconv.Itoa(len(styles)) + " of your styles have been removed"
 	}
-	if err := email.Send("style/bulkban", user.Email, title, args); err != nil {
+	if err := email.SendNotification(uint(user.ID), models.KindBannedStyle, "style/bulkban", user.Email, title, args); err != nil {
 		log.Warn.Printf("Failed to email %d: %s\n", user.ID, err)
 	}
 }
@@ -23,7 +23,7 @@ func sendPromotionEmail(style *models.APIStyle, user *models.User, mod string) {
 	}
 
 	title := "Your style has been featured"
-	if err := email.Send("style/promote", user.Email, title, args); err != nil {
+	if err := email.SendNotification(user.ID, models.KindStylePromotion, "style/promote", user.Email, title, args); err != nil {
 		log.Warn.Printf("Failed to email %d: %s\n", user.ID, err)
 	}
 }
@@ -84,7 +84,7 @@ func Promote(c *fiber.Ctx) error {
 	// Ahem!!! We don't save the new value of Featured to the current style.
 	// So we have to reverse check it ;)
 	if !style.Featured {
-		go sendPromotionEmail(style, user, u.Username)
+		sendPromotionEmail(style, user, u.Username)
 
 		n := models.Notification{
 			Seen:     false,
@@ -94,8 +94,14 @@ func Promote(c *fiber.Ctx) error {
 			StyleID:  id,
 		}
 
-		if err := models.CreateNotification(database.Conn, &n); err != nil {
-			log.Warn.Printf("Failed to create a notification for %d: %s\n", id, err)
+		ch, err := models.NotificationChannel(database.Conn, style.UserID, n.Kind)
+		if err != nil {
+			log.Database.Printf("Failed to get notification settings for %d: %s\n", style.UserID, err)
+		}
+		if ch != models.ChannelNone {
+			if err := models.CreateNotification(database.Conn, &n); err != nil {
+				log.Warn.Printf("Failed to create a notification for %d: %s\n", id, err)
+			}
 		}
 	}
 
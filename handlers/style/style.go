@@ -24,11 +24,15 @@ func Routes(app *fiber.App) {
 	r.Get("/edit/:id", jwtware.Protected, EditGet)
 	r.Post("/edit/:id", jwtware.Protected, EditPost)
 	r.Get("/mirror/:id", jwtware.Protected, Mirror)
-	r.Get("/styles/promote/:id", jwtware.Protected, Promote)
-	r.Get("/styles/ban/:id", jwtware.Protected, BanGet)
-	r.Post("/styles/ban/:id", jwtware.Protected, BanPost)
-	r.Get("/styles/bulk-ban/:userid", jwtware.Protected, BulkBanGet)
-	r.Post("/styles/bulk-ban/:userid", jwtware.Protected, BulkBanPost)
+	r.Get("/styles/promote/:id", jwtware.Protected, jwtware.TwoFactor, Promote)
+	r.Get("/styles/ban/:id", jwtware.Protected, jwtware.TwoFactor, BanGet)
+	r.Post("/styles/ban/:id", jwtware.Protected, jwtware.TwoFactor, BanPost)
+	r.Get("/styles/bulk-ban/:userid", jwtware.Protected, jwtware.TwoFactor, BulkBanGet)
+	r.Post("/styles/bulk-ban/:userid", jwtware.Protected, jwtware.TwoFactor, BulkBanPost)
+	r.Get("/styles/appeal/:id", jwtware.Protected, middleware.Alert, AppealGet)
+	r.Post("/styles/appeal/:id", jwtware.Protected, AppealPost)
+	r.Get("/styles/appeals", jwtware.Protected, jwtware.TwoFactor, middleware.Alert, AppealsGet)
+	r.Post("/styles/appeals/:id", jwtware.Protected, jwtware.TwoFactor, AppealDecisionPost)
 	r.Static("/preview", config.PublicDir, fiber.Static{
 		MaxAge: 2678400, // 1 month
 	})
@@ -7,11 +7,11 @@ import (
 
 	"userstyles.world/handlers/jwt"
 	"userstyles.world/models"
+	"userstyles.world/modules/botfilter"
 	"userstyles.world/modules/cache"
-	"userstyles.world/modules/storage"
-
-	// "userstyles.world/modules/charts"
+	"userstyles.world/modules/charts"
 	"userstyles.world/modules/log"
+	"userstyles.world/modules/storage"
 	"userstyles.world/modules/util"
 )
 
@@ -57,10 +57,8 @@ func GetStylePage(c *fiber.Ctx) error {
 	}
 
 	// Upsert style views.
-	if util.IsCrawler(string(c.Context().UserAgent())) {
-		return c.Render("style/view", args)
-	} else {
-		cache.ViewStats.Add(c.IP() + " " + id)
+	if botfilter.Allow(c, botfilter.View) {
+		cache.ViewStats.Add(c.IP(), id)
 	}
 
 	if u.ID != data.UserID {
@@ -77,24 +75,9 @@ func GetStylePage(c *fiber.Ctx) error {
 			}
 		}
 	}
-	/*
-		// Get history data.
-		history, err := models.GetStyleHistory(id)
-		if err != nil {
-			log.Warn.Printf("Failed to get stats for style %s: %s", id, err.Error())
-		}
 
-		// Render graphs.
-		var dailyHistory, totalHistory string
-		if len(history) > 2 {
-			dailyHistory, totalHistory, err = charts.GetStatsHistory(history)
-			if err != nil {
-				log.Warn.Printf("Failed to render history for style %s: %s\n", id, err.Error())
-			}
-		}
-		args["DailyHistory"] = dailyHistory
-		args["TotalHistory"] = totalHistory
-	*/
+	// Charts are rendered after the nightly snapshot.
+	args["Charts"] = charts.HasStyleCharts(data.ID)
 
 	reviews, err := models.FindAllForStyle(id)
 	if err != nil {
@@ -29,11 +29,56 @@ func Account(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Render("user/account", fiber.Map{
+	args := fiber.Map{
 		"Title":  "Account",
 		"User":   u,
 		"Params": user,
-	})
+	}
+
+	if user.HasTOTP() {
+		n, err := models.CountRecoveryCodes(user.ID)
+		if err != nil {
+			log.Database.Printf("Failed to count recovery codes for %d: %s\n", user.ID, err)
+		}
+		args["RecoveryCodes"] = n
+	}
+
+	keys, err := models.GetPasskeys(user.ID)
+	if err != nil {
+		log.Database.Printf("Failed to get passkeys for %d: %s\n", user.ID, err)
+	}
+	args["Passkeys"] = keys
+
+	sessions, err := models.GetSessions(user.ID)
+	if err != nil {
+		log.Database.Printf("Failed to get sessions for %d: %s\n", user.ID, err)
+	}
+	sid := jwt.SessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].SID == sid
+	}
+	args["Sessions"] = sessions
+
+	logins, err := models.GetLogins(user.ID, 10)
+	if err != nil {
+		log.Database.Printf("Failed to get logins for %d: %s\n", user.ID, err)
+	}
+	args["Logins"] = logins
+
+	links, err := models.GetExternalUsers(user.ID)
+	if err != nil {
+		log.Database.Printf("Failed to get linked accounts for %d: %s\n", user.ID, err)
+	}
+	args["Links"] = links
+
+	tokens, err := models.GetAccessTokens(user.ID)
+	if err != nil {
+		log.Database.Printf("Failed to get access tokens for %d: %s\n", user.ID, err)
+	}
+	args["AccessTokens"] = tokens
+	args["APIScopes"] = models.APIScopes
+
+	return c.Render("user/account", args)
 }
 
 func EditAccount(c *fiber.Ctx) error {
@@ -203,5 +248,12 @@ func EditAccount(c *fiber.Ctx) error {
 		})
 	}
 
+	// Sign out other devices after a password change.
+	if form == "password" {
+		if err := jwt.RevokeSessions(user.ID, jwt.SessionID(c)); err != nil {
+			log.Database.Printf("Failed to revoke sessions for %d: %s\n", user.ID, err)
+		}
+	}
+
 	return c.Status(fiber.StatusSeeOther).Redirect("/account#" + form)
 }
@@ -0,0 +1,66 @@
+package user
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+func AppsGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	user, err := models.FindUserByName(u.Username)
+	if err != nil {
+		return c.Render("err", fiber.Map{
+			"Title": "User not found",
+			"User":  u,
+		})
+	}
+
+	apps, err := models.GetAuthorizedApps(user)
+	if err != nil {
+		log.Database.Printf("Failed to get authorized apps for %d: %s\n", u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to get authorized applications",
+			"User":  u,
+		})
+	}
+
+	return c.Render("user/apps", fiber.Map{
+		"Title": "Authorized applications",
+		"User":  u,
+		"Apps":  apps,
+	})
+}
+
+func AppRevokePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Invalid application ID",
+			"User":  u,
+		})
+	}
+
+	if err = models.RevokeAuthorizedApp(database.Conn, u.ID, uint(id)); err != nil {
+		log.Database.Printf("Failed to revoke app %d for %d: %s\n", id, u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to revoke application",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=app-revoke id=%d username=%s\n", u.ID, u.Username)
+
+	a := models.NewSuccessAlert("Application's access has been revoked.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account/apps", fiber.StatusSeeOther)
+}
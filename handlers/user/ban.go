@@ -85,6 +85,11 @@ func ConfirmBan(c *fiber.Ctx) error {
 		})
 	}
 
+	// Sign out banned user from all devices.
+	if err := jwt.RevokeSessions(targetUser.ID, ""); err != nil {
+		log.Database.Printf("Failed to revoke sessions for %d: %s\n", targetUser.ID, err)
+	}
+
 	// Delete user's styles.
 	styles := new(models.Style)
 	if err := styles.BanWhereUserID(targetUser.ID); err != nil {
@@ -58,12 +58,36 @@ func DeletePost(c *fiber.Ctx) error {
 		if err = tx.Debug().Delete(&models.Review{}, "user_id = ?", id).Error; err != nil {
 			return err
 		}
+		if err = models.UpdateReviewedStyles(tx, uint(id)); err != nil {
+			return err
+		}
 		if err = tx.Debug().Delete(&models.Notification{}, "user_id = ?", id).Error; err != nil {
 			return err
 		}
 		if err = tx.Debug().Delete(&models.ExternalUser{}, "user_id = ?", id).Error; err != nil {
 			return err
 		}
+		if err = tx.Debug().Delete(&models.Passkey{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err = tx.Debug().Delete(&models.RecoveryCode{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err = tx.Debug().Delete(&models.AccessToken{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err = tx.Debug().Delete(&models.OAuthGrant{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err = tx.Debug().Delete(&models.OAuthConsent{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err = tx.Debug().Delete(&models.Login{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err = tx.Debug().Delete(&models.NotificationSetting{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
 
 		return nil
 	})
@@ -73,5 +97,9 @@ func DeletePost(c *fiber.Ctx) error {
 		return c.Render("err", m)
 	}
 
+	if err = jwt.RevokeSessions(uint(id), ""); err != nil {
+		log.Database.Printf("Failed to revoke sessions for %d: %s\n", id, err)
+	}
+
 	return c.Redirect("/logout")
 }
@@ -0,0 +1,73 @@
+package user
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+// LinkPost starts linking an external account, whose ownership is confirmed
+// when the user signs in with it.
+func LinkPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	return redirectOAuth(c, u.ID)
+}
+
+func UnlinkPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Invalid account ID",
+			"User":  u,
+		})
+	}
+
+	user, err := models.FindUserByName(u.Username)
+	if err != nil {
+		return c.Render("err", fiber.Map{
+			"Title": "User not found",
+			"User":  u,
+		})
+	}
+
+	// Users without a password need another way to sign in.
+	if user.Password == "" {
+		links, err := models.GetExternalUsers(u.ID)
+		if err != nil {
+			log.Database.Printf("Failed to get linked accounts for %d: %s\n", u.ID, err)
+		}
+		keys, err := models.GetPasskeys(u.ID)
+		if err != nil {
+			log.Database.Printf("Failed to get passkeys for %d: %s\n", u.ID, err)
+		}
+		if len(links) < 2 && len(keys) == 0 {
+			return c.Status(fiber.StatusForbidden).Render("err", fiber.Map{
+				"Title": "Set a password before unlinking your only way to sign in",
+				"User":  u,
+			})
+		}
+	}
+
+	if err = models.UnlinkExternalUser(database.Conn, uint(id), u.ID); err != nil {
+		log.Database.Printf("Failed to unlink account %d for %d: %s\n", id, u.ID, err)
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Linked account not found",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=unlink id=%d username=%s\n", u.ID, u.Username)
+
+	a := models.NewSuccessAlert("Account has been unlinked.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account#linked", fiber.StatusSeeOther)
+}
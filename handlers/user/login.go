@@ -2,6 +2,8 @@ package user
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"net/url"
 	"time"
 
@@ -10,8 +12,6 @@ import (
 
 	"userstyles.world/handlers/jwt"
 	"userstyles.world/models"
-	"userstyles.world/modules/config"
-	"userstyles.world/modules/email"
 	"userstyles.world/modules/log"
 	"userstyles.world/modules/util"
 	"userstyles.world/modules/validator"
@@ -66,9 +66,22 @@ func LoginPost(c *fiber.Ctx) error {
 			})
 	}
 
+	if d := user.LockedFor(); d > 0 {
+		log.Warn.Printf("kind=login-locked id=%d username=%s\n", user.ID, user.Username)
+
+		return c.Status(fiber.StatusTooManyRequests).
+			Render("user/login", fiber.Map{
+				"Title": "Login failed",
+				"Error": lockedMessage(d),
+			})
+	}
+
 	match := util.VerifyPassword(user.Password, form.Password)
 	if match != nil {
 		log.Warn.Println("Failed to match hash for user:", user.Email)
+		if err := user.FailLogin(); err != nil {
+			log.Database.Printf("Failed to record failed login for %d: %s\n", user.ID, err)
+		}
 
 		return c.Status(fiber.StatusInternalServerError).
 			Render("user/login", fiber.Map{
@@ -77,37 +90,53 @@ func LoginPost(c *fiber.Ctx) error {
 			})
 	}
 
-	var expiration time.Time
+	expiration := loginExpiration(remember)
+
+	// Second factor has to be verified before creating a session.
+	if user.HasTOTP() {
+		if err := jwt.StartTwoFactor(c, user, expiration); err != nil {
+			log.Warn.Printf("Failed to start 2FA for %d: %s\n", user.ID, err)
+			return c.Status(fiber.StatusInternalServerError).
+				Render("err", fiber.Map{
+					"Title": "Internal server error",
+				})
+		}
+
+		return c.Redirect("/login/2fa"+loginRedirect(c), fiber.StatusSeeOther)
+	}
+
+	return finishLogin(c, user, expiration, false, "password")
+}
+
+// lockedMessage tells users how long to wait after repeated failed attempts.
+func lockedMessage(d time.Duration) string {
+	wait := fmt.Sprintf("%d seconds", int(math.Ceil(d.Seconds())))
+	if d > time.Minute {
+		wait = fmt.Sprintf("%d minutes", int(math.Ceil(d.Minutes())))
+	}
+
+	return "Too many failed attempts. Try again in " + wait + "."
+}
+
+// loginExpiration returns expiration of a new session.  Zero value creates a
+// session that ends when browser closes.
+func loginExpiration(remember bool) (t time.Time) {
 	if remember {
 		// 3 months
-		expiration = time.Now().Add(time.Hour * 24 * 31 * 3)
+		t = time.Now().Add(time.Hour * 24 * 31 * 3)
 	}
-	t, err := util.NewJWT().
-		SetClaim("id", user.ID).
-		SetClaim("name", user.Username).
-		SetClaim("email", user.Email).
-		SetClaim("role", user.Role).
-		SetExpiration(expiration).
-		GetSignedString(nil)
-	if err != nil {
+	return t
+}
+
+// finishLogin creates a session for a user who passed all required factors.
+func finishLogin(c *fiber.Ctx, user *models.User, expiration time.Time, mfa bool, method string) error {
+	if err := jwt.NewLogin(c, user, expiration, mfa, method); err != nil {
 		return c.Status(fiber.StatusInternalServerError).
 			Render("err", fiber.Map{
 				"Title": "Internal server error",
 			})
 	}
 
-	// Create and set cookie.
-	cookie := &fiber.Cookie{
-		Name:     fiber.HeaderAuthorization,
-		Value:    t,
-		Path:     "/",
-		Expires:  expiration,
-		Secure:   config.Production,
-		HTTPOnly: true,
-		SameSite: fiber.CookieSameSiteLaxMode,
-	}
-	c.Cookie(cookie)
-
 	if r := c.Query("r"); r != "" {
 		path, err := url.QueryUnescape(r)
 		if err != nil {
@@ -119,13 +148,5 @@ func LoginPost(c *fiber.Ctx) error {
 		return c.Redirect(path, fiber.StatusSeeOther)
 	}
 
-	// Update last login date.
-	if err = user.UpdateLastLogin(); err != nil {
-		log.Warn.Println("Failed to update last login date for id:", user.ID)
-	}
-
-	args := fiber.Map{"User": user}
-	go email.Send("user/login", user.Email, "New sign-in", args)
-
 	return c.Redirect("/account", fiber.StatusSeeOther)
 }
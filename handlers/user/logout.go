@@ -2,9 +2,17 @@ package user
 
 import (
 	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/modules/log"
 )
 
 func Logout(c *fiber.Ctx) error {
+	if sid := jwt.SessionID(c); sid != "" {
+		if err := jwt.RevokeSession(sid); err != nil {
+			log.Database.Println("Failed to revoke session:", err)
+		}
+	}
 	c.ClearCookie(fiber.HeaderAuthorization)
 
 	return c.Redirect("/login", fiber.StatusSeeOther)
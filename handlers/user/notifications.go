@@ -0,0 +1,129 @@
+package user
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/email"
+	"userstyles.world/modules/log"
+)
+
+func NotificationsGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	settings, err := models.GetNotificationSettings(database.Conn, u.ID)
+	if err != nil {
+		log.Database.Printf("Failed to get notification settings for %d: %s\n", u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to get notification settings",
+			"User":  u,
+		})
+	}
+
+	return c.Render("user/notifications", fiber.Map{
+		"Title":    "Notifications",
+		"User":     u,
+		"Kinds":    models.NotificationKinds,
+		"Settings": settings,
+	})
+}
+
+func NotificationsPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	err := database.Conn.Transaction(func(tx *gorm.DB) error {
+		for _, nk := range models.NotificationKinds {
+			v, err := strconv.ParseUint(c.FormValue(nk.Slug), 10, 8)
+			if err != nil || !nk.Allows(models.Channel(v)) {
+				continue
+			}
+
+			err = models.SetNotificationChannel(tx, u.ID, nk.Kind, models.Channel(v))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Database.Printf("Failed to update notification settings for %d: %s\n", u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to update notification settings",
+			"User":  u,
+		})
+	}
+
+	a := models.NewSuccessAlert("Notification settings have been updated.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account/notifications", fiber.StatusSeeOther)
+}
+
+// UnsubscribeGet asks users to confirm unsubscribing from emails.
+func UnsubscribeGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	_, kind, ok := email.ParseUnsubscribeKey(c.Params("key"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Unsubscribe key not found",
+			"User":  u,
+		})
+	}
+	nk, _ := models.FindNotificationKind(kind)
+
+	return c.Render("user/unsubscribe", fiber.Map{
+		"Title": "Unsubscribe",
+		"User":  u,
+		"Key":   c.Params("key"),
+		"Kind":  nk,
+	})
+}
+
+// UnsubscribePost stops emails about a kind of notification.  Mail clients
+// send requests to it when users unsubscribe with one click (RFC 8058).
+func UnsubscribePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	uid, kind, ok := email.ParseUnsubscribeKey(c.Params("key"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Unsubscribe key not found",
+			"User":  u,
+		})
+	}
+	nk, _ := models.FindNotificationKind(kind)
+
+	// Keep in-app notifications, unless there aren't any for this kind.
+	to := models.ChannelInApp
+	if nk.EmailOnly {
+		to = models.ChannelNone
+	}
+
+	ch, err := models.NotificationChannel(database.Conn, uid, kind)
+	if err == nil && ch == models.ChannelEmail {
+		err = models.SetNotificationChannel(database.Conn, uid, kind, to)
+	}
+	if err != nil {
+		log.Database.Printf("Failed to unsubscribe %d from %s: %s\n", uid, nk.Slug, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to unsubscribe",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=unsubscribe id=%d notification=%s\n", uid, nk.Slug)
+
+	return c.Render("user/unsubscribe", fiber.Map{
+		"Title":        "Unsubscribed",
+		"User":         u,
+		"Kind":         nk,
+		"Unsubscribed": true,
+	})
+}
@@ -1,21 +1,42 @@
 package user
 
 import (
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 
 	"userstyles.world/handlers/jwt"
+	"userstyles.world/modules/config"
 	"userstyles.world/modules/log"
 	"userstyles.world/modules/oauthlogin"
 )
 
+// redirectOAuth sends users to sign in with a service.
+func redirectOAuth(c *fiber.Ctx, userID uint) error {
+	redirectURI, state := oauthlogin.OauthMakeURL(c.Params("type"), userID)
+	if redirectURI == "" {
+		return c.Next()
+	}
+
+	// Callbacks are cross-site navigations, so the cookie can't be strict.
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthlogin.StateCookie,
+		Value:    state,
+		Path:     "/api/callback",
+		Expires:  time.Now().Add(oauthlogin.StateTimeout),
+		Secure:   config.Production,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	return c.Redirect(redirectURI, fiber.StatusSeeOther)
+}
+
 func AuthLoginGet(c *fiber.Ctx) error {
 	if u, ok := jwt.User(c); ok {
 		log.Info.Printf("User %d has set session, redirecting.\n", u.ID)
 		return c.Redirect("/account", fiber.StatusSeeOther)
 	}
 
-	oauthType := c.Params("type")
-	redirectURI := oauthlogin.OauthMakeURL(oauthType)
-
-	return c.Redirect(redirectURI, fiber.StatusSeeOther)
+	return redirectOAuth(c, 0)
 }
@@ -0,0 +1,367 @@
+package user
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+const (
+	passkeyCookie  = "passkey"
+	passkeyTimeout = 5 * time.Minute
+	passkeysLimit  = 20
+)
+
+var (
+	errPasskeyOwner = errors.New("passkey belongs to another user")
+
+	passkeys *webauthn.WebAuthn
+)
+
+func initPasskeys() {
+	u, err := url.Parse(config.BaseURL)
+	if err != nil {
+		log.Warn.Fatalf("Failed to parse base URL: %s\n", err)
+	}
+
+	timeout := webauthn.TimeoutConfig{
+		Enforce:    true,
+		Timeout:    passkeyTimeout,
+		TimeoutUVD: passkeyTimeout,
+	}
+
+	passkeys, err = webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: config.AppName,
+		RPOrigins:     []string{u.Scheme + "://" + u.Host},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+	if err != nil {
+		log.Warn.Fatalf("Failed to initialize WebAuthn: %s\n", err)
+	}
+}
+
+// passkeyUser implements webauthn.User for a user and their passkeys.
+type passkeyUser struct {
+	*models.User
+	keys []models.Passkey
+}
+
+// userHandle identifies a user in passkeys.  It's opaque to authenticators,
+// and is used to find the user during sign-in without asking for an email.
+func userHandle(id uint) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(id))
+	return b
+}
+
+func (u passkeyUser) WebAuthnID() []byte          { return userHandle(u.ID) }
+func (u passkeyUser) WebAuthnName() string        { return u.Username }
+func (u passkeyUser) WebAuthnDisplayName() string { return u.Name() }
+func (u passkeyUser) WebAuthnIcon() string        { return "" }
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, len(u.keys))
+	for i, k := range u.keys {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(k.TransportList()))
+		for _, t := range k.TransportList() {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+
+		creds[i] = webauthn.Credential{
+			ID:              k.CredentialID,
+			PublicKey:       k.PublicKey,
+			AttestationType: k.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				UserVerified:   k.UserVerified,
+				BackupEligible: k.BackupEligible,
+				BackupState:    k.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    k.AAGUID,
+				SignCount: k.SignCount,
+			},
+		}
+	}
+
+	return creds
+}
+
+func findPasskeyUser(id uint) (*passkeyUser, error) {
+	user, err := models.FindUserByID(strconv.Itoa(int(id)))
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := models.GetPasskeys(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &passkeyUser{User: user, keys: keys}, nil
+}
+
+// newPasskey returns a passkey that can be stored for a verified credential.
+func newPasskey(uid uint, name string, cred *webauthn.Credential) *models.Passkey {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if r := []rune(name); len(r) > 50 {
+		name = string(r[:50])
+	}
+
+	transports := make([]string, len(cred.Transport))
+	for i, t := range cred.Transport {
+		transports[i] = string(t)
+	}
+
+	return &models.Passkey{
+		Name:            name,
+		UserID:          uid,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		UserVerified:    cred.Flags.UserVerified,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+}
+
+// passkeyOptions sends WebAuthn options, which rely on standard JSON encoding
+// for binary data and enumerations.
+func passkeyOptions(c *fiber.Ctx, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.Type("json")
+	return c.Send(b)
+}
+
+func passkeyError(c *fiber.Ctx, status int, msg string) error {
+	return c.Status(status).JSON(fiber.Map{"data": msg})
+}
+
+func PasskeyRegisterBegin(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	user, err := findPasskeyUser(u.ID)
+	if err != nil {
+		return passkeyError(c, fiber.StatusNotFound, "User not found.")
+	}
+
+	if len(user.keys) >= passkeysLimit {
+		return passkeyError(c, fiber.StatusBadRequest, "You can't add more passkeys.")
+	}
+
+	exclude := make([]protocol.CredentialDescriptor, len(user.keys))
+	for i, cred := range user.WebAuthnCredentials() {
+		exclude[i] = cred.Descriptor()
+	}
+
+	opts, session, err := passkeys.BeginRegistration(user,
+		webauthn.WithExclusions(exclude),
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			UserVerification: protocol.VerificationPreferred,
+		}),
+		// Discoverable credentials allow signing in without an email.
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		log.Warn.Printf("Failed to begin passkey registration for %d: %s\n", u.ID, err)
+		return passkeyError(c, fiber.StatusInternalServerError, "Failed to add passkey.")
+	}
+
+	cache.Store.Set("passkey "+u.Username, session, passkeyTimeout)
+
+	return passkeyOptions(c, opts)
+}
+
+func PasskeyRegisterFinish(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	k := "passkey " + u.Username
+	v, ok := cache.Store.Get(k)
+	if !ok {
+		return passkeyError(c, fiber.StatusBadRequest, "Passkey registration has expired. Please try again.")
+	}
+	cache.Store.Delete(k)
+	session := v.(*webauthn.SessionData)
+
+	user, err := findPasskeyUser(u.ID)
+	if err != nil {
+		return passkeyError(c, fiber.StatusNotFound, "User not found.")
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(c.Body()))
+	if err != nil {
+		log.Info.Printf("Failed to parse passkey for %d: %s\n", u.ID, err)
+		return passkeyError(c, fiber.StatusBadRequest, "Invalid passkey.")
+	}
+
+	cred, err := passkeys.CreateCredential(user, *session, parsed)
+	if err != nil {
+		log.Info.Printf("Failed to verify passkey for %d: %s\n", u.ID, err)
+		return passkeyError(c, fiber.StatusBadRequest, "Failed to verify passkey.")
+	}
+
+	p := newPasskey(u.ID, c.Query("name"), cred)
+	if err = models.CreatePasskey(p); err != nil {
+		log.Database.Printf("Failed to add passkey for %d: %s\n", u.ID, err)
+		return passkeyError(c, fiber.StatusInternalServerError, "Failed to add passkey.")
+	}
+	log.Info.Printf("kind=passkey-add id=%d username=%s\n", u.ID, u.Username)
+
+	a := models.NewSuccessAlert("Passkey has been added.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.JSON(fiber.Map{"data": "/account#passkeys"})
+}
+
+func PasskeyDeletePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Invalid passkey ID",
+			"User":  u,
+		})
+	}
+
+	if err = models.DeletePasskey(database.Conn, uint(id), u.ID); err != nil {
+		log.Database.Printf("Failed to delete passkey %d for %d: %s\n", id, u.ID, err)
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Passkey not found",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=passkey-delete id=%d username=%s\n", u.ID, u.Username)
+
+	a := models.NewSuccessAlert("Passkey has been removed.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account#passkeys", fiber.StatusSeeOther)
+}
+
+func PasskeyLoginBegin(c *fiber.Ctx) error {
+	opts, session, err := passkeys.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationPreferred),
+	)
+	if err != nil {
+		log.Warn.Println("Failed to begin passkey login:", err)
+		return passkeyError(c, fiber.StatusInternalServerError, "Failed to sign in with passkey.")
+	}
+
+	key := util.RandomString(32)
+	cache.Store.Set("passkey-login "+key, session, passkeyTimeout)
+
+	c.Cookie(&fiber.Cookie{
+		Name:     passkeyCookie,
+		Value:    key,
+		Path:     "/login/passkey",
+		Expires:  time.Now().Add(passkeyTimeout),
+		Secure:   config.Production,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteStrictMode,
+	})
+
+	return passkeyOptions(c, opts)
+}
+
+func PasskeyLoginFinish(c *fiber.Ctx) error {
+	k := "passkey-login " + c.Cookies(passkeyCookie)
+	v, ok := cache.Store.Get(k)
+	if !ok {
+		return passkeyError(c, fiber.StatusBadRequest, "Passkey sign-in has expired. Please try again.")
+	}
+	cache.Store.Delete(k)
+	session := v.(*webauthn.SessionData)
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(c.Body()))
+	if err != nil {
+		log.Info.Println("Failed to parse passkey assertion:", err)
+		return passkeyError(c, fiber.StatusBadRequest, "Invalid passkey.")
+	}
+
+	var key *models.Passkey
+	var user *passkeyUser
+	handler := func(rawID, handle []byte) (webauthn.User, error) {
+		key, err = models.FindPasskey(rawID)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(handle, userHandle(key.UserID)) {
+			return nil, errPasskeyOwner
+		}
+
+		user, err = findPasskeyUser(key.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		return user, nil
+	}
+
+	cred, err := passkeys.ValidateDiscoverableLogin(handler, *session, parsed)
+	if err != nil {
+		log.Warn.Println("Failed to verify passkey assertion:", err)
+		return passkeyError(c, fiber.StatusUnauthorized, "Failed to sign in with passkey.")
+	}
+
+	// Counters that don't increase indicate that a passkey might be cloned.
+	if cred.Authenticator.CloneWarning {
+		log.Warn.Printf("Passkey %d for %d failed the signature counter check.\n", key.ID, key.UserID)
+		return passkeyError(c, fiber.StatusUnauthorized, "Failed to sign in with passkey.")
+	}
+
+	if err = key.UpdateUsage(cred.Authenticator.SignCount, cred.Flags.BackupState); err != nil {
+		log.Database.Printf("Failed to update passkey %d: %s\n", key.ID, err)
+	}
+
+	// Passkeys are a factor of possession, and user verification (biometrics
+	// or PIN) makes them satisfy two-factor authentication on their own.
+	// Otherwise, users who have TOTP enabled still have to enter a code.
+	expiration := loginExpiration(c.Query("remember") == "on")
+	if !cred.Flags.UserVerified && user.HasTOTP() {
+		if err = jwt.StartTwoFactor(c, user.User, expiration); err != nil {
+			log.Warn.Printf("Failed to start 2FA for %d: %s\n", user.ID, err)
+			return passkeyError(c, fiber.StatusInternalServerError, "Failed to sign in with passkey.")
+		}
+
+		return c.JSON(fiber.Map{"data": "/login/2fa"})
+	}
+
+	if err = jwt.NewLogin(c, user.User, expiration, cred.Flags.UserVerified, "passkey"); err != nil {
+		log.Warn.Printf("Failed to create session for %d: %s\n", user.ID, err)
+		return passkeyError(c, fiber.StatusInternalServerError, "Failed to sign in with passkey.")
+	}
+
+	return c.JSON(fiber.Map{"data": "/account"})
+}
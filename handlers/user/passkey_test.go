@@ -0,0 +1,229 @@
+package user
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"gorm.io/gorm"
+
+	"userstyles.world/models"
+)
+
+// softAuthenticator is a minimal software authenticator that creates ES256
+// passkeys with "none" attestation.
+type softAuthenticator struct {
+	origin  string
+	rpID    string
+	key     *ecdsa.PrivateKey
+	id      []byte
+	handle  []byte
+	counter uint32
+}
+
+func newSoftAuthenticator(t *testing.T, origin, rpID string) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{origin: origin, rpID: rpID, key: key, id: id}
+}
+
+var b64 = base64.RawURLEncoding
+
+func (a *softAuthenticator) clientData(kind string, challenge protocol.URLEncodedBase64) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"type":      kind,
+		"challenge": challenge.String(),
+		"origin":    a.origin,
+	})
+	return b
+}
+
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rp := sha256.Sum256([]byte(a.rpID))
+	b := append(rp[:], flags)
+	b = binary.BigEndian.AppendUint32(b, a.counter)
+	return append(b, attested...)
+}
+
+func (a *softAuthenticator) create(t *testing.T, opts *protocol.CredentialCreation) []byte {
+	t.Helper()
+	a.handle = opts.Response.User.ID.(protocol.URLEncodedBase64)
+
+	cose, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, cose...)
+
+	// User present, user verified, and attested credential data included.
+	att, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(0x01|0x04|0x40, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(map[string]any{
+		"id":    b64.EncodeToString(a.id),
+		"rawId": b64.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64.EncodeToString(a.clientData("webauthn.create", opts.Response.Challenge)),
+			"attestationObject": b64.EncodeToString(att),
+		},
+	})
+	return b
+}
+
+func (a *softAuthenticator) get(t *testing.T, opts *protocol.CredentialAssertion) []byte {
+	t.Helper()
+	a.counter++
+
+	data := a.authData(0x01|0x04, nil)
+	client := a.clientData("webauthn.get", opts.Response.Challenge)
+	hash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(data, hash[:]...))
+
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := json.Marshal(map[string]any{
+		"id":    b64.EncodeToString(a.id),
+		"rawId": b64.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64.EncodeToString(client),
+			"authenticatorData": b64.EncodeToString(data),
+			"signature":         b64.EncodeToString(sig),
+			"userHandle":        b64.EncodeToString(a.handle),
+		},
+	})
+	return b
+}
+
+func TestPasskeyCeremonies(t *testing.T) {
+	initPasskeys()
+	origin := passkeys.Config.RPOrigins[0]
+	auth := newSoftAuthenticator(t, origin, passkeys.Config.RPID)
+
+	user := &passkeyUser{User: &models.User{Model: gorm.Model{ID: 7}, Username: "user"}}
+
+	// Registration.
+	creation, session, err := passkeys.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(auth.create(t, creation)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cred, err := passkeys.CreateCredential(user, *session, parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := newPasskey(user.ID, "  Software key  ", cred)
+	if key.Name != "Software key" {
+		t.Errorf("got: %q, exp: %q", key.Name, "Software key")
+	}
+	if !bytes.Equal(key.CredentialID, auth.id) {
+		t.Errorf("got: %x, exp: %x", key.CredentialID, auth.id)
+	}
+	if !key.UserVerified {
+		t.Error("expected passkey to be user-verified")
+	}
+	user.keys = []models.Passkey{*key}
+
+	login := func(a *softAuthenticator) (*webauthn.Credential, error) {
+		assertion, session, err := passkeys.BeginDiscoverableLogin()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(a.get(t, assertion)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handler := func(rawID, handle []byte) (webauthn.User, error) {
+			if !bytes.Equal(handle, userHandle(user.ID)) {
+				return nil, errPasskeyOwner
+			}
+			return user, nil
+		}
+
+		return passkeys.ValidateDiscoverableLogin(handler, *session, parsed)
+	}
+
+	// Sign-in.
+	got, err := login(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Authenticator.SignCount != 1 || got.Authenticator.CloneWarning {
+		t.Errorf("got: %d, exp: 1", got.Authenticator.SignCount)
+	}
+	if !got.Flags.UserVerified {
+		t.Error("expected sign-in to be user-verified")
+	}
+
+	// Counter that doesn't increase indicates a cloned passkey.
+	user.keys[0].SignCount = 5
+	got, err = login(auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Authenticator.CloneWarning {
+		t.Error("expected clone warning")
+	}
+
+	// Assertions from other origins must be rejected.
+	auth.origin = "https://example.org"
+	user.keys[0].SignCount = 0
+	if _, err = login(auth); err == nil {
+		t.Error("expected sign-in from a different origin to fail")
+	}
+
+	// Handles of other users must be rejected.
+	auth.origin = origin
+	auth.handle = userHandle(8)
+	if _, err = login(auth); err == nil {
+		t.Error("expected sign-in with a different user handle to fail")
+	}
+}
@@ -1,6 +1,8 @@
 package user
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 
 	"userstyles.world/handlers/jwt"
@@ -29,6 +31,19 @@ func Profile(c *fiber.Ctx) error {
 	}
 	c.Locals("Title", profile.Name()+"'s profile")
 
+	// Profile links are verified if they match linked accounts.
+	links, err := models.GetExternalUsers(profile.ID)
+	if err != nil {
+		log.Database.Printf("Failed to get linked accounts for %d: %s\n", profile.ID, err)
+	}
+	verified := make(map[string]bool)
+	for _, l := range links {
+		if s := profile.Socials.Get(l.Provider); s != "" && strings.EqualFold(s, l.Username) {
+			verified[l.Provider] = true
+		}
+	}
+	c.Locals("Verified", verified)
+
 	page, err := models.IsValidPage(c.Query("page"))
 	if err != nil {
 		c.Locals("Title", "Invalid page size")
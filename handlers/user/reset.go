@@ -130,9 +130,20 @@ func ResetPost(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := jwtware.RevokeSessions(user.ID, ""); err != nil {
+		log.Database.Printf("Failed to revoke sessions for %d: %s\n", user.ID, err)
+	}
+
+	// Owners who can reset their password don't have to wait for lockout.
+	if err := user.ResetFailedLogins(); err != nil {
+		log.Database.Printf("Failed to reset failed logins for %d: %s\n", user.ID, err)
+	}
+
 	args := fiber.Map{"User": user}
 	title := "Your password has been changed"
-	go email.Send("user/reset", user.Email, title, args)
+	if err := email.Send("user/reset", user.Email, title, args); err != nil {
+		log.Warn.Printf("Failed to email %d: %s\n", user.ID, err)
+	}
 
 	return c.Render("user/verification", fiber.Map{
 		"Title":        "Successful reset",
@@ -0,0 +1,116 @@
+package user
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/log"
+)
+
+func SessionRevokePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Invalid session ID",
+			"User":  u,
+		})
+	}
+
+	if err = jwt.RevokeUserSession(uint(id), u.ID); err != nil {
+		log.Database.Printf("Failed to revoke session %d for %d: %s\n", id, u.ID, err)
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Session not found",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=session-revoke id=%d username=%s\n", u.ID, u.Username)
+
+	a := models.NewSuccessAlert("Session has been revoked.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account#sessions", fiber.StatusSeeOther)
+}
+
+// RevokeGet asks users to confirm revoking a session from a sign-in email.
+func RevokeGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	uid, sid, ok := jwt.ParseRevokeKey(c.Params("key"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Revoke key not found",
+			"User":  u,
+		})
+	}
+
+	s, err := models.FindSession(sid)
+	if err != nil || s.UserID != uid {
+		return c.Render("user/verification", fiber.Map{
+			"Title":        "Session not found",
+			"User":         u,
+			"Verification": "Session not found",
+			"Reason":       "It has already been revoked or it has expired",
+		})
+	}
+
+	return c.Render("user/revoke", fiber.Map{
+		"Title":   "Revoke session",
+		"User":    u,
+		"Key":     c.Params("key"),
+		"Session": s,
+	})
+}
+
+// RevokePost revokes a session from a sign-in email.
+func RevokePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	uid, sid, ok := jwt.ParseRevokeKey(c.Params("key"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Revoke key not found",
+			"User":  u,
+		})
+	}
+
+	if s, err := models.FindSession(sid); err == nil && s.UserID == uid {
+		if err = jwt.RevokeSession(sid); err != nil {
+			log.Database.Printf("Failed to revoke session for %d: %s\n", uid, err)
+			return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+				"Title": "Failed to revoke session",
+				"User":  u,
+			})
+		}
+		log.Info.Printf("kind=session-revoke-link id=%d\n", uid)
+	}
+
+	return c.Render("user/verification", fiber.Map{
+		"Title":        "Session revoked",
+		"User":         u,
+		"Verification": "Session has been revoked",
+		"Reason":       "If you didn't sign in, please reset your password and review your linked accounts",
+	})
+}
+
+func SessionsRevokePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	if err := jwt.RevokeSessions(u.ID, ""); err != nil {
+		log.Database.Printf("Failed to revoke sessions for %d: %s\n", u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to revoke sessions",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=session-revoke-all id=%d username=%s\n", u.ID, u.Username)
+
+	c.ClearCookie(fiber.HeaderAuthorization)
+
+	return c.Redirect("/login", fiber.StatusSeeOther)
+}
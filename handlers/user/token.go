@@ -0,0 +1,108 @@
+package user
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+// accessTokenExpirations are the allowed lifetimes of access tokens in days,
+// where zero creates tokens that don't expire.
+var accessTokenExpirations = map[string]int{
+	"7": 7, "30": 30, "90": 90, "365": 365, "0": 0,
+}
+
+func AccessTokenCreatePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	name := strings.TrimSpace(c.FormValue("name"))
+	if name == "" || len(name) > 50 {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Token name must be between 1 and 50 characters",
+			"User":  u,
+		})
+	}
+
+	var scopes []string
+	for _, scope := range models.APIScopes {
+		if c.FormValue("scope_"+scope) == "on" {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Select at least one scope",
+			"User":  u,
+		})
+	}
+
+	days, ok := accessTokenExpirations[c.FormValue("expiration")]
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Invalid expiration",
+			"User":  u,
+		})
+	}
+
+	token := models.AccessTokenPrefix + util.RandomString(20)
+	t := &models.AccessToken{
+		Name:   name,
+		Hash:   util.HashToken(token),
+		Hint:   token[:len(models.AccessTokenPrefix)+4],
+		Scopes: scopes,
+		UserID: u.ID,
+	}
+	if days > 0 {
+		t.ExpiresAt = time.Now().AddDate(0, 0, days)
+	}
+
+	if err := models.CreateAccessToken(t); err != nil {
+		log.Database.Printf("Failed to create access token for %d: %s\n", u.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to create access token",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=token-create id=%d username=%s\n", u.ID, u.Username)
+
+	return c.Render("user/access-token", fiber.Map{
+		"Title": "Personal access token",
+		"User":  u,
+		"Token": token,
+		"Data":  t,
+	})
+}
+
+func AccessTokenDeletePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id < 1 {
+		return c.Status(fiber.StatusBadRequest).Render("err", fiber.Map{
+			"Title": "Invalid access token ID",
+			"User":  u,
+		})
+	}
+
+	if err = models.DeleteAccessToken(database.Conn, uint(id), u.ID); err != nil {
+		log.Database.Printf("Failed to delete access token %d for %d: %s\n", id, u.ID, err)
+		return c.Status(fiber.StatusNotFound).Render("err", fiber.Map{
+			"Title": "Access token not found",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=token-delete id=%d username=%s\n", u.ID, u.Username)
+
+	a := models.NewSuccessAlert("Access token has been revoked.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account#tokens", fiber.StatusSeeOther)
+}
@@ -0,0 +1,293 @@
+package user
+
+import (
+	"html/template"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"userstyles.world/handlers/jwt"
+	"userstyles.world/models"
+	"userstyles.world/modules/cache"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+const recoveryCodesAmount = 10
+
+// verifySecondFactor checks a TOTP code or a recovery code, and makes sure
+// that neither of them can be used again.
+func verifySecondFactor(u *models.User, code string) bool {
+	secret, err := util.DecryptText(u.TOTPSecret, util.AEADCrypto, config.ScrambleConfig)
+	if err != nil {
+		log.Warn.Printf("Failed to decrypt TOTP secret for %d: %s\n", u.ID, err)
+		return false
+	}
+
+	if step, ok := util.ValidateTOTP(secret, code, time.Now()); ok {
+		return u.UseTOTPStep(step)
+	}
+
+	return models.UseRecoveryCode(u.ID, util.HashToken(code))
+}
+
+// loginRedirect preserves the page where users will be sent after login.
+func loginRedirect(c *fiber.Ctx) string {
+	if r := c.Query("r"); r != "" {
+		return "?r=" + url.QueryEscape(r)
+	}
+
+	return ""
+}
+
+// sessionExpiration returns expiration of current session.
+func sessionExpiration(c *fiber.Ctx) time.Time {
+	if exp, ok := jwt.MapClaim(c)["exp"].(float64); ok {
+		return time.Unix(int64(exp), 0)
+	}
+
+	return time.Time{}
+}
+
+// newRecoveryCodes replaces user's recovery codes and returns the new ones.
+func newRecoveryCodes(db *gorm.DB, uid uint) ([]string, error) {
+	codes := util.NewRecoveryCodes(recoveryCodesAmount)
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = util.HashToken(code)
+	}
+
+	if err := models.ReplaceRecoveryCodes(db, uid, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func LoginTwoFactorGet(c *fiber.Ctx) error {
+	if _, _, ok := jwt.PendingTwoFactor(c); !ok {
+		return c.Redirect("/login", fiber.StatusSeeOther)
+	}
+
+	return c.Render("user/login-2fa", fiber.Map{
+		"Title":    "Two-factor authentication",
+		"Redirect": loginRedirect(c),
+	})
+}
+
+func LoginTwoFactorPost(c *fiber.Ctx) error {
+	id, expiration, ok := jwt.PendingTwoFactor(c)
+	if !ok {
+		return c.Redirect("/login", fiber.StatusSeeOther)
+	}
+
+	user, err := models.FindUserByID(strconv.Itoa(int(id)))
+	if err != nil || !user.HasTOTP() {
+		jwt.ClearTwoFactor(c)
+		return c.Redirect("/login", fiber.StatusSeeOther)
+	}
+
+	if d := user.LockedFor(); d > 0 {
+		return c.Status(fiber.StatusTooManyRequests).
+			Render("user/login-2fa", fiber.Map{
+				"Title":    "Two-factor authentication",
+				"Error":    lockedMessage(d),
+				"Redirect": loginRedirect(c),
+			})
+	}
+
+	if !verifySecondFactor(user, c.FormValue("code")) {
+		log.Warn.Println("Failed to verify second factor for user:", user.ID)
+		if err := user.FailLogin(); err != nil {
+			log.Database.Printf("Failed to record failed login for %d: %s\n", user.ID, err)
+		}
+
+		return c.Status(fiber.StatusUnauthorized).
+			Render("user/login-2fa", fiber.Map{
+				"Title":    "Two-factor authentication",
+				"Error":    "Invalid authentication code.",
+				"Redirect": loginRedirect(c),
+			})
+	}
+
+	jwt.ClearTwoFactor(c)
+
+	return finishLogin(c, user, expiration, true, "2fa")
+}
+
+func renderTwoFactorSetup(c *fiber.Ctx, u *models.APIUser, secret, e string) error {
+	uri := util.TOTPURI(config.AppName, u.Username, secret)
+	qr, err := util.QRCodeSVG(uri)
+	if err != nil {
+		log.Warn.Printf("Failed to render QR code for %d: %s\n", u.ID, err)
+	}
+
+	return c.Render("user/2fa-setup", fiber.Map{
+		"Title":  "Set up two-factor authentication",
+		"User":   u,
+		"Secret": secret,
+		"URI":    uri,
+		"QRCode": template.HTML(qr),
+		"Error":  e,
+	})
+}
+
+func TwoFactorSetupGet(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	user, err := models.FindUserByName(u.Username)
+	if err != nil {
+		return c.Render("err", fiber.Map{
+			"Title": "User not found",
+			"User":  u,
+		})
+	}
+
+	if user.HasTOTP() {
+		return c.Redirect("/account#2fa", fiber.StatusSeeOther)
+	}
+
+	secret := util.NewTOTPSecret()
+	cache.Store.Set("totp "+u.Username, secret, 15*time.Minute)
+
+	return renderTwoFactorSetup(c, u, secret, "")
+}
+
+func TwoFactorSetupPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	user, err := models.FindUserByName(u.Username)
+	if err != nil {
+		return c.Render("err", fiber.Map{
+			"Title": "User not found",
+			"User":  u,
+		})
+	}
+
+	if user.HasTOTP() {
+		return c.Redirect("/account#2fa", fiber.StatusSeeOther)
+	}
+
+	k := "totp " + u.Username
+	v, ok := cache.Store.Get(k)
+	if !ok {
+		return c.Redirect("/account/2fa", fiber.StatusSeeOther)
+	}
+	secret := v.(string)
+
+	step, ok := util.ValidateTOTP(secret, c.FormValue("code"), time.Now())
+	if !ok {
+		c.Status(fiber.StatusBadRequest)
+		return renderTwoFactorSetup(c, u, secret, "Invalid authentication code. Make sure the time on your device is correct.")
+	}
+
+	var codes []string
+	err = database.Conn.Transaction(func(tx *gorm.DB) error {
+		encrypted := util.EncryptText(secret, util.AEADCrypto, config.ScrambleConfig)
+		if err := user.EnableTOTP(tx, encrypted, step); err != nil {
+			return err
+		}
+
+		codes, err = newRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		log.Database.Printf("Failed to enable 2FA for %d: %s\n", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to enable two-factor authentication",
+			"User":  u,
+		})
+	}
+	cache.Store.Delete(k)
+	log.Info.Printf("kind=2fa-enable id=%d username=%s\n", user.ID, user.Username)
+
+	// Current session has just proved possession of the second factor.
+	if err := jwt.NewSession(c, user, sessionExpiration(c), true); err != nil {
+		log.Warn.Printf("Failed to refresh session for %d: %s\n", user.ID, err)
+	}
+
+	return c.Render("user/2fa-recovery", fiber.Map{
+		"Title": "Recovery codes",
+		"User":  u,
+		"Codes": codes,
+	})
+}
+
+func TwoFactorRecoveryPost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	user, err := models.FindUserByName(u.Username)
+	if err != nil || !user.HasTOTP() {
+		return c.Render("err", fiber.Map{
+			"Title": "Two-factor authentication isn't enabled",
+			"User":  u,
+		})
+	}
+
+	if !verifySecondFactor(user, c.FormValue("code")) {
+		return c.Status(fiber.StatusForbidden).Render("err", fiber.Map{
+			"Title": "Invalid authentication code",
+			"User":  u,
+		})
+	}
+
+	codes, err := newRecoveryCodes(database.Conn, user.ID)
+	if err != nil {
+		log.Database.Printf("Failed to create recovery codes for %d: %s\n", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to create recovery codes",
+			"User":  u,
+		})
+	}
+
+	return c.Render("user/2fa-recovery", fiber.Map{
+		"Title": "Recovery codes",
+		"User":  u,
+		"Codes": codes,
+	})
+}
+
+func TwoFactorDisablePost(c *fiber.Ctx) error {
+	u, _ := jwt.User(c)
+
+	user, err := models.FindUserByName(u.Username)
+	if err != nil || !user.HasTOTP() {
+		return c.Render("err", fiber.Map{
+			"Title": "Two-factor authentication isn't enabled",
+			"User":  u,
+		})
+	}
+
+	if !verifySecondFactor(user, c.FormValue("code")) {
+		return c.Status(fiber.StatusForbidden).Render("err", fiber.Map{
+			"Title": "Invalid authentication code",
+			"User":  u,
+		})
+	}
+
+	err = database.Conn.Transaction(func(tx *gorm.DB) error {
+		return user.DisableTOTP(tx)
+	})
+	if err != nil {
+		log.Database.Printf("Failed to disable 2FA for %d: %s\n", user.ID, err)
+		return c.Status(fiber.StatusInternalServerError).Render("err", fiber.Map{
+			"Title": "Failed to disable two-factor authentication",
+			"User":  u,
+		})
+	}
+	log.Info.Printf("kind=2fa-disable id=%d username=%s\n", user.ID, user.Username)
+
+	if err := jwt.NewSession(c, user, sessionExpiration(c), false); err != nil {
+		log.Warn.Printf("Failed to refresh session for %d: %s\n", user.ID, err)
+	}
+
+	a := models.NewSuccessAlert("Two-factor authentication has been disabled.")
+	cache.Store.Add("alert "+u.Username, a, time.Minute)
+
+	return c.Redirect("/account#2fa", fiber.StatusSeeOther)
+}
@@ -5,28 +5,56 @@ import (
 	"github.com/gofiber/fiber/v2"
 
 	jwtware "userstyles.world/handlers/jwt"
+	"userstyles.world/handlers/middleware"
 )
 
 // Routes provides routes for Fiber's router.
 func Routes(app *fiber.App) {
+	initPasskeys()
+
 	r := app.Group("/")
 	r.Get("/login", LoginGet)
-	r.Post("/login", LoginPost)
+	r.Post("/login", middleware.RateLimit("login", middleware.ByIP), LoginPost)
+	r.Get("/login/2fa", LoginTwoFactorGet)
+	r.Post("/login/2fa", middleware.RateLimit("login", middleware.ByIP), LoginTwoFactorPost)
+	r.Post("/login/passkey/begin", PasskeyLoginBegin)
+	r.Post("/login/passkey/finish", middleware.RateLimit("login", middleware.ByIP), PasskeyLoginFinish)
 	r.Get("/register", RegisterGet)
-	r.Post("/register", RegisterPost)
+	r.Post("/register", middleware.RateLimit("register", middleware.ByIP), RegisterPost)
 	r.Get("/oauth/:type", AuthLoginGet)
 	r.Get("/verify/:key", VerifyGet)
 	r.Get("/recover", RecoverGet)
-	r.Post("/recover", RecoverPost)
+	r.Post("/recover", middleware.RateLimit("recover", middleware.ByIP), RecoverPost)
 	r.Get("/reset/:key", ResetGet)
 	r.Post("/reset/:key", ResetPost)
+	r.Get("/revoke/:key", RevokeGet)
+	r.Post("/revoke/:key", RevokePost)
+	r.Get("/unsubscribe/:key", UnsubscribeGet)
+	r.Post("/unsubscribe/:key", UnsubscribePost)
 	r.Get("/user/:name", Profile)
 	r.Get("~:name", Profile)
 	r.Get("/logout", jwtware.Protected, Logout)
-	r.Get("/account", jwtware.Protected, Account)
+	r.Get("/account", jwtware.Protected, middleware.Alert, Account)
+	r.Get("/account/2fa", jwtware.Protected, TwoFactorSetupGet)
+	r.Post("/account/2fa", jwtware.Protected, TwoFactorSetupPost)
+	r.Post("/account/2fa/recovery", jwtware.Protected, TwoFactorRecoveryPost)
+	r.Post("/account/2fa/disable", jwtware.Protected, TwoFactorDisablePost)
+	r.Post("/account/passkeys/begin", jwtware.Protected, PasskeyRegisterBegin)
+	r.Post("/account/passkeys/finish", jwtware.Protected, PasskeyRegisterFinish)
+	r.Post("/account/passkeys/:id/delete", jwtware.Protected, PasskeyDeletePost)
+	r.Post("/account/sessions/revoke", jwtware.Protected, SessionsRevokePost)
+	r.Post("/account/sessions/:id/revoke", jwtware.Protected, SessionRevokePost)
+	r.Post("/account/tokens", jwtware.Protected, AccessTokenCreatePost)
+	r.Post("/account/tokens/:id/delete", jwtware.Protected, AccessTokenDeletePost)
+	r.Get("/account/apps", jwtware.Protected, middleware.Alert, AppsGet)
+	r.Post("/account/apps/:id/revoke", jwtware.Protected, AppRevokePost)
+	r.Get("/account/notifications", jwtware.Protected, middleware.Alert, NotificationsGet)
+	r.Post("/account/notifications", jwtware.Protected, NotificationsPost)
+	r.Post("/account/links/:id/unlink", jwtware.Protected, UnlinkPost)
+	r.Post("/account/links/:type", jwtware.Protected, LinkPost)
 	r.Post("/account/:form", jwtware.Protected, EditAccount)
-	r.Get("/user/ban/:id", jwtware.Protected, Ban)
-	r.Post("/user/ban/:id", jwtware.Protected, ConfirmBan)
+	r.Get("/user/ban/:id", jwtware.Protected, jwtware.TwoFactor, Ban)
+	r.Post("/user/ban/:id", jwtware.Protected, jwtware.TwoFactor, ConfirmBan)
 	r.Get("/user/delete/:id", jwtware.Protected, DeleteGet)
 	r.Post("/user/delete/:id", jwtware.Protected, DeletePost)
 }
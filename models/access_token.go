@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccessTokenPrefix distinguishes personal access tokens from OAuth tokens.
+const AccessTokenPrefix = "usw_"
+
+// APIScopes are permissions that can be granted to API tokens.
+var APIScopes = []string{"user", "style"}
+
+var errorAccessTokenNotFound = errors.New("access token not found")
+
+// AccessToken is a personal access token for the API.  Only a hash of the
+// token is stored, and Hint helps users tell tokens apart.
+type AccessToken struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	ExpiresAt  time.Time `gorm:"default:null"`
+	LastUsedAt time.Time `gorm:"default:null"`
+	Name       string
+	Hash       string `gorm:"uniqueIndex;not null"`
+	Hint       string
+	Scopes     StringList `gorm:"type:varchar(255);"`
+	UserID     uint       `gorm:"index"`
+}
+
+// Expired checks if a token can't be used anymore.
+func (t *AccessToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && t.ExpiresAt.Before(time.Now())
+}
+
+// CreateAccessToken inserts a new access token.
+func CreateAccessToken(t *AccessToken) error {
+	return db().Create(t).Error
+}
+
+// GetAccessTokens returns access tokens that belong to a user.
+func GetAccessTokens(uid uint) ([]AccessToken, error) {
+	var t []AccessToken
+	err := db().Where("user_id = ?", uid).Order("id DESC").Find(&t).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// FindAccessToken returns an unexpired access token by its hash.
+func FindAccessToken(hash string) (*AccessToken, error) {
+	var t AccessToken
+	if err := db().Where("hash = ?", hash).First(&t).Error; err != nil {
+		return nil, err
+	}
+	if t.Expired() {
+		return nil, errorAccessTokenNotFound
+	}
+
+	return &t, nil
+}
+
+// UpdateLastUsed records when a token was used.
+func (t *AccessToken) UpdateLastUsed() error {
+	t.LastUsedAt = time.Now()
+	return db().Model(t).UpdateColumn("last_used_at", t.LastUsedAt).Error
+}
+
+// DeleteAccessToken revokes a user's access token.
+func DeleteAccessToken(db *gorm.DB, id, uid uint) error {
+	tx := db.Where("id = ? AND user_id = ?", id, uid).Delete(&AccessToken{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return errorAccessTokenNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessToken_Expired(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		expiresAt time.Time
+		exp       bool
+	}{
+		{"no expiration", time.Time{}, false},
+		{"expires later", time.Now().Add(time.Hour), false},
+		{"expired", time.Now().Add(-time.Hour), true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			token := &AccessToken{ExpiresAt: c.expiresAt}
+			got := token.Expired()
+			if got != c.exp {
+				t.Errorf("got: %v", got)
+				t.Errorf("exp: %v", c.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"userstyles.world/modules/database"
+)
+
+var (
+	errorAppealShortMessage = errors.New("message is too short")
+	errorAppealLongMessage  = errors.New("message is too long")
+	errorAppealDecided      = errors.New("appeal has already been decided")
+)
+
+type AppealState uint8
+
+const (
+	AppealPending AppealState = iota
+	AppealAccepted
+	AppealRejected
+)
+
+// Appeal is a request from an author to reverse a moderation action.
+type Appeal struct {
+	gorm.Model
+	State    AppealState `gorm:"default:0"`
+	Message  string
+	Response string
+
+	// Log is the modlog entry that is being appealed.
+	Log   Log
+	LogID uint `gorm:"index"`
+
+	User   User
+	UserID uint
+
+	// StyleID is the removed style, as it isn't stored in the modlog entry.
+	StyleID uint
+
+	// ModID is the moderator who made a decision.
+	ModID     uint      `gorm:"default:null"`
+	DecidedAt time.Time `gorm:"default:null"`
+}
+
+// APIAppeal is used to render appeals in the moderator queue.
+type APIAppeal struct {
+	ID             uint
+	CreatedAt      time.Time
+	State          AppealState
+	Message        string
+	Response       string
+	LogID          uint
+	StyleID        uint
+	Username       string
+	Reason         string
+	TargetData     string
+	ModeratorName  string
+	DecidedAt      time.Time
+	TargetUserName string
+}
+
+// Pending checks if appeal is waiting for a decision.
+func (a APIAppeal) Pending() bool { return a.State == AppealPending }
+
+// StateString returns appeal's state in string format.
+func (a APIAppeal) StateString() (s string) {
+	switch a.State {
+	case AppealPending:
+		s = "Pending"
+	case AppealAccepted:
+		s = "Accepted"
+	case AppealRejected:
+		s = "Rejected"
+	}
+	return s
+}
+
+// Validate verifies user-generated content.
+func (a *Appeal) Validate() error {
+	a.Message = strings.TrimSpace(a.Message)
+	switch {
+	case len(a.Message) < 10:
+		return errorAppealShortMessage
+	case len(a.Message) > 5000:
+		return errorAppealLongMessage
+	default:
+		return nil
+	}
+}
+
+// CreateAppeal inserts a new appeal.
+func CreateAppeal(a *Appeal) error {
+	return db().Create(a).Error
+}
+
+// GetAppeal returns a specific appeal, or an error if it doesn't exist.
+func GetAppeal(id int) (*Appeal, error) {
+	var a Appeal
+	err := database.Conn.Preload("Log").Preload("User").First(&a, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// FindAppealForLog returns an existing appeal for a modlog entry.
+func FindAppealForLog(logID, userID uint) (*Appeal, error) {
+	var a Appeal
+	err := db().First(&a, "log_id = ? AND user_id = ?", logID, userID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// GetAppeals returns appeals for the moderator queue, pending ones first.
+func GetAppeals() ([]APIAppeal, error) {
+	var q []APIAppeal
+
+	err := db().
+		Model(&Appeal{}).
+		Select(`appeals.*, logs.reason, logs.target_data, logs.target_user_name,
+(SELECT username FROM users WHERE id = appeals.user_id) AS Username,
+(SELECT username FROM users WHERE id = appeals.mod_id) AS ModeratorName`).
+		Joins("JOIN logs ON logs.id = appeals.log_id").
+		Order("appeals.state ASC, appeals.created_at DESC").
+		Find(&q).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// CountPendingAppeals returns the number of appeals waiting for a decision.
+func CountPendingAppeals() (int64, error) {
+	var i int64
+	err := db().Model(&Appeal{}).Where("state = ?", AppealPending).Count(&i).Error
+	return i, err
+}
+
+// Decide records a moderator's decision on an appeal.
+func (a *Appeal) Decide(db *gorm.DB, state AppealState, modID uint, response string) error {
+	a.State = state
+	a.ModID = modID
+	a.Response = response
+	a.DecidedAt = time.Now()
+
+	tx := db.
+		Model(a).
+		Select("state", "mod_id", "response", "decided_at").
+		Where("id = ? AND state = ?", a.ID, AppealPending).
+		Updates(a)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return errorAppealDecided
+	}
+
+	return nil
+}
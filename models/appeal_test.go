@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppeal_Validate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		message string
+		exp     error
+	}{
+		{"empty", "", errorAppealShortMessage},
+		{"only spaces", strings.Repeat(" ", 20), errorAppealShortMessage},
+		{"too long", strings.Repeat("a", 5001), errorAppealLongMessage},
+		{"valid", "Please restore my style.", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := &Appeal{Message: c.message}
+			got := a.Validate()
+			if got != c.exp {
+				t.Errorf("got: %v", got)
+				t.Errorf("exp: %v", c.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DailyStats holds counts of raw stats that were removed after the retention
+// period, so that total stats stay the same.
+type DailyStats struct {
+	StyleID  int    `gorm:"primaryKey;autoIncrement:false"`
+	Date     string `gorm:"primaryKey"`
+	Views    int64
+	Installs int64
+	Updates  int64
+}
+
+// expiredStats selects raw stats that weren't updated since a time.
+const expiredStats = "SELECT id FROM stats WHERE updated_at < @before ORDER BY id LIMIT @limit"
+
+const compactStats = `
+INSERT INTO daily_stats(style_id, date, views, installs, updates)
+SELECT
+	style_id, DATE(created_at),
+	SUM(CASE WHEN view > 0 THEN 1 ELSE 0 END),
+	SUM(CASE WHEN install > 0 THEN 1 ELSE 0 END),
+	SUM(CASE WHEN install != created_at THEN 1 ELSE 0 END)
+FROM stats
+WHERE id IN (` + expiredStats + `) AND deleted_at IS NULL
+GROUP BY style_id, DATE(created_at)
+ON CONFLICT(style_id, date) DO UPDATE SET
+	views = views + excluded.views,
+	installs = installs + excluded.installs,
+	updates = updates + excluded.updates
+`
+
+// CountExpiredStats returns how many raw stats weren't updated since a time.
+func CountExpiredStats(db *gorm.DB, before time.Time) (int64, error) {
+	var i int64
+	err := db.Model(&Stats{}).Unscoped().
+		Where("updated_at < ?", before.UTC()).
+		Count(&i).
+		Error
+	if err != nil {
+		return 0, err
+	}
+
+	return i, nil
+}
+
+// CompactStats adds up to limit raw stats, which weren't updated since a time,
+// to daily stats and removes them.  It returns how many were removed.
+func CompactStats(db *gorm.DB, before time.Time, limit int) (int64, error) {
+	args := map[string]any{"before": before.UTC(), "limit": limit}
+
+	var n int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(compactStats, args).Error; err != nil {
+			return err
+		}
+
+		res := tx.Exec("DELETE FROM stats WHERE id IN ("+expiredStats+")", args)
+		n = res.RowsAffected
+		return res.Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WeeklyCount is a count for a week and the week before it.
+type WeeklyCount struct {
+	This int64
+	Last int64
+}
+
+// Change returns a week-over-week change, e.g. "up 25%" or "new".
+func (c WeeklyCount) Change() string {
+	switch {
+	case c.This == c.Last:
+		return "no change"
+	case c.Last == 0:
+		return "new"
+	}
+
+	p := (c.This - c.Last) * 100 / c.Last
+	if p >= 0 {
+		return "up " + strconv.FormatInt(p, 10) + "%"
+	}
+	return "down " + strconv.FormatInt(-p, 10) + "%"
+}
+
+// DigestStyle is activity of a style over a week, which is sent to authors in
+// weekly digests.
+type DigestStyle struct {
+	ID       uint
+	Name     string
+	Installs WeeklyCount `gorm:"embedded;embeddedPrefix:installs_"`
+	Updates  WeeklyCount `gorm:"embedded;embeddedPrefix:updates_"`
+	Views    WeeklyCount `gorm:"embedded;embeddedPrefix:views_"`
+	Reviews  int64
+	Ratings  int64
+}
+
+// Active checks if anything happened to a style in the last two weeks.
+func (s DigestStyle) Active() bool {
+	return s.Installs != WeeklyCount{} || s.Updates != WeeklyCount{} ||
+		s.Views != WeeklyCount{} || s.Reviews > 0 || s.Ratings > 0
+}
+
+const digestStyles = `
+SELECT
+	s.id, s.name,
+	COALESCE(SUM(CASE WHEN h.created_at >= @week THEN h.daily_installs END), 0) installs_this,
+	COALESCE(SUM(CASE WHEN h.created_at < @week THEN h.daily_installs END), 0) installs_last,
+	COALESCE(SUM(CASE WHEN h.created_at >= @week THEN h.daily_updates END), 0) updates_this,
+	COALESCE(SUM(CASE WHEN h.created_at < @week THEN h.daily_updates END), 0) updates_last,
+	COALESCE(SUM(CASE WHEN h.created_at >= @week THEN h.daily_views END), 0) views_this,
+	COALESCE(SUM(CASE WHEN h.created_at < @week THEN h.daily_views END), 0) views_last,
+	(SELECT COUNT(*) FROM reviews r WHERE r.style_id = s.id AND r.deleted_at IS NULL
+		AND r.created_at >= @week AND r.created_at < @now AND r.comment != '') reviews,
+	(SELECT COUNT(*) FROM reviews r WHERE r.style_id = s.id AND r.deleted_at IS NULL
+		AND r.created_at >= @week AND r.created_at < @now AND r.rating > 0) ratings
+FROM styles s
+LEFT JOIN histories h ON h.style_id = s.id AND h.deleted_at IS NULL
+	AND h.created_at >= @prev AND h.created_at < @now
+WHERE s.user_id = @user AND s.deleted_at IS NULL
+GROUP BY s.id
+ORDER BY installs_this DESC, s.id
+`
+
+// GetDigestStyles returns activity of user's styles in the week before now,
+// and in the week before that.
+func GetDigestStyles(db *gorm.DB, uid uint, now time.Time) ([]DigestStyle, error) {
+	now = now.UTC()
+	week := now.AddDate(0, 0, -7)
+
+	var s []DigestStyle
+	err := db.Raw(digestStyles, map[string]any{
+		"user": uid,
+		"now":  now,
+		"week": week,
+		"prev": week.AddDate(0, 0, -7),
+	}).Scan(&s).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// GetDigestRecipients returns users who want weekly digests by email.
+func GetDigestRecipients(db *gorm.DB) ([]User, error) {
+	var u []User
+	err := db.
+		Select("users.id", "users.username", "users.email").
+		Joins("JOIN notification_settings ns ON ns.user_id = users.id").
+		Where("ns.kind = ? AND ns.channel = ?", KindWeeklyDigest, ChannelEmail).
+		Find(&u).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestWeeklyCountChange(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		count WeeklyCount
+		exp   string
+	}{
+		{WeeklyCount{}, "no change"},
+		{WeeklyCount{This: 8, Last: 8}, "no change"},
+		{WeeklyCount{This: 5, Last: 0}, "new"},
+		{WeeklyCount{This: 15, Last: 12}, "up 25%"},
+		{WeeklyCount{This: 40, Last: 60}, "down 33%"},
+		{WeeklyCount{This: 0, Last: 3}, "down 100%"},
+	}
+
+	for _, c := range cases {
+		if got := c.count.Change(); got != c.exp {
+			t.Errorf("%+v: got %q, expected %q", c.count, got, c.exp)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+var errorExternalUserNotFound = errors.New("external user not found")
+
 // ExternalUser contains external user information from OAuth providers.
 type ExternalUser struct {
 	ID          uint `gorm:"primarykey"`
@@ -32,3 +35,75 @@ func (u *ExternalUser) NormalizeUsername(username string) {
 		u.Username = username
 	}
 }
+
+// GetExternalUsers returns accounts that are linked to a user.
+func GetExternalUsers(uid uint) ([]ExternalUser, error) {
+	var eu []ExternalUser
+	err := db().Where("user_id = ?", uid).Order("id ASC").Find(&eu).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return eu, nil
+}
+
+// FindExternalUser returns a linked account along with its user.
+func FindExternalUser(provider, externalID string) (*ExternalUser, error) {
+	var eu ExternalUser
+	err := db().
+		Preload("User").
+		Where("provider = ? AND external_id = ?", provider, externalID).
+		First(&eu).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &eu, nil
+}
+
+// LinkExternalUser links an account to a user, and sets their profile link for
+// that provider, as the account's ownership has been confirmed.
+func LinkExternalUser(eu *ExternalUser) error {
+	return db().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User").Create(eu).Error; err != nil {
+			return err
+		}
+
+		var s SocialMedia
+		if !s.Set(eu.Provider, eu.Username) {
+			return nil
+		}
+
+		return tx.Model(&User{}).Where("id = ?", eu.UserID).Updates(User{Socials: s}).Error
+	})
+}
+
+// UnlinkExternalUser removes an account that is linked to a user, and clears
+// their profile link for that provider if it was set by linking.
+func UnlinkExternalUser(db *gorm.DB, id, uid uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var eu ExternalUser
+		res := tx.Unscoped().Where("id = ? AND user_id = ?", id, uid).Limit(1).Find(&eu)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errorExternalUserNotFound
+		}
+
+		if err := tx.Unscoped().Delete(&eu).Error; err != nil {
+			return err
+		}
+
+		var s SocialMedia
+		if !s.Set(eu.Provider, eu.Username) {
+			return nil
+		}
+
+		// Provider is a column name, as it's one of the checked ones.
+		return tx.Model(&User{}).
+			Where("id = ? AND "+eu.Provider+" = ? COLLATE NOCASE", uid, eu.Username).
+			UpdateColumn(eu.Provider, "").Error
+	})
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"userstyles.world/modules/errors"
@@ -48,3 +50,106 @@ func GetAllStyleHistories() (h []History, err error) {
 
 	return h, nil
 }
+
+// GetStyleIDsWithHistory returns IDs of styles that have at least n snapshots.
+func GetStyleIDsWithHistory(db *gorm.DB, n int) ([]uint, error) {
+	var ids []uint
+	err := db.
+		Model(modelHistory).
+		Joins("JOIN styles s ON s.id = histories.style_id AND s.deleted_at IS NULL").
+		Group("histories.style_id").
+		Having("COUNT(*) >= ?", n).
+		Pluck("histories.style_id", &ids).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// Granularity is a period of time that history is grouped by.
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityWeek  Granularity = "week"
+	GranularityMonth Granularity = "month"
+)
+
+// Valid checks if history can be grouped by a granularity.
+func (g Granularity) Valid() bool {
+	return g == GranularityDay || g == GranularityWeek || g == GranularityMonth
+}
+
+// start returns the beginning of a period that contains t.  Weeks start on
+// Monday.
+func (g Granularity) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch g {
+	case GranularityWeek:
+		d -= (int(t.Weekday()) + 6) % 7
+	case GranularityMonth:
+		d = 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// HistoryPoint is stats of a style for a period of time.  Views, installs and
+// updates are sums for the period, while weekly and total stats are taken from
+// the last snapshot in it.
+type HistoryPoint struct {
+	Date           string `json:"date"`
+	Views          int64  `json:"views"`
+	Installs       int64  `json:"installs"`
+	Updates        int64  `json:"updates"`
+	WeeklyViews    int64  `json:"weekly_views"`
+	WeeklyInstalls int64  `json:"weekly_installs"`
+	WeeklyUpdates  int64  `json:"weekly_updates"`
+	TotalViews     int64  `json:"total_views"`
+	TotalInstalls  int64  `json:"total_installs"`
+	TotalUpdates   int64  `json:"total_updates"`
+}
+
+// GetHistoryRange returns snapshots of styles taken between from and to,
+// ordered by style and time.
+func GetHistoryRange(db *gorm.DB, ids []uint, from, to time.Time) ([]History, error) {
+	var h []History
+	err := db.
+		Where("style_id IN ? AND created_at >= ? AND created_at < ?", ids, from.UTC(), to.UTC()).
+		Order("style_id, created_at").
+		Find(&h).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// GroupHistory groups snapshots of a style, which are ordered by time, into
+// periods of a granularity.
+func GroupHistory(h []History, g Granularity) []HistoryPoint {
+	var list []HistoryPoint
+	var last time.Time
+	for _, s := range h {
+		t := g.start(s.CreatedAt.UTC())
+		if len(list) == 0 || !t.Equal(last) {
+			list = append(list, HistoryPoint{Date: t.Format("2006-01-02")})
+			last = t
+		}
+
+		p := &list[len(list)-1]
+		p.Views += s.DailyViews
+		p.Installs += s.DailyInstalls
+		p.Updates += s.DailyUpdates
+		p.WeeklyViews = s.WeeklyViews
+		p.WeeklyInstalls = s.WeeklyInstalls
+		p.WeeklyUpdates = s.WeeklyUpdates
+		p.TotalViews = s.TotalViews
+		p.TotalInstalls = s.TotalInstalls
+		p.TotalUpdates = s.TotalUpdates
+	}
+
+	return list
+}
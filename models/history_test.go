@@ -0,0 +1,57 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGroupHistory(t *testing.T) {
+	t.Parallel()
+
+	// Snapshots of a style from Saturday, 2026-09-26 to Sunday, 2026-10-04.
+	var h []History
+	for i := 0; i < 9; i++ {
+		s := History{
+			DailyViews:    10,
+			DailyInstalls: int64(i),
+			DailyUpdates:  1,
+			WeeklyViews:   int64(70 + i),
+			TotalInstalls: int64(100 + i),
+		}
+		s.CreatedAt = time.Date(2026, 9, 26+i, 23, 59, 0, 0, time.UTC)
+		h = append(h, s)
+	}
+
+	cases := []struct {
+		granularity Granularity
+		exp         []HistoryPoint
+	}{
+		{GranularityDay, nil},
+		{GranularityWeek, []HistoryPoint{
+			{Date: "2026-09-21", Views: 20, Installs: 1, Updates: 2, WeeklyViews: 71, TotalInstalls: 101},
+			{Date: "2026-09-28", Views: 70, Installs: 35, Updates: 7, WeeklyViews: 78, TotalInstalls: 108},
+		}},
+		{GranularityMonth, []HistoryPoint{
+			{Date: "2026-09-01", Views: 50, Installs: 10, Updates: 5, WeeklyViews: 74, TotalInstalls: 104},
+			{Date: "2026-10-01", Views: 40, Installs: 26, Updates: 4, WeeklyViews: 78, TotalInstalls: 108},
+		}},
+	}
+
+	for _, c := range cases {
+		got := GroupHistory(h, c.granularity)
+		if c.granularity == GranularityDay {
+			if len(got) != len(h) || got[0].Date != "2026-09-26" || got[8].Installs != 8 {
+				t.Errorf("day: unexpected points: %+v", got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, c.exp) {
+			t.Errorf("%s: got %+v, expected %+v", c.granularity, got, c.exp)
+		}
+	}
+
+	if got := GroupHistory(nil, GranularityWeek); len(got) != 0 {
+		t.Errorf("got %+v, expected no points", got)
+	}
+}
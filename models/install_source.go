@@ -0,0 +1,124 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InstallSource is where an install came from.
+type InstallSource string
+
+const (
+	SourceStylus    InstallSource = "stylus"
+	SourceXStyle    InstallSource = "xstyle"
+	SourceExtension InstallSource = "extension"
+	SourceAPI       InstallSource = "api"
+	SourceCurl      InstallSource = "curl"
+	SourceBrowser   InstallSource = "browser"
+	SourceOther     InstallSource = "other"
+
+	// SourceUnknown stands for installs made before sources were saved.
+	SourceUnknown InstallSource = "unknown"
+)
+
+// extensionIDs maps IDs of style managers in Chrome Web Store to sources.
+// Firefox uses random IDs for each installation, so those are only known to
+// be extensions.
+var extensionIDs = map[string]InstallSource{
+	"clngdbkpkpeebahjckkjfobafhncgmne": SourceStylus,
+	"hncgkmhphmncjohllpoleelnibpmccpj": SourceXStyle,
+}
+
+// sourceRanks orders sources from least to most specific.
+var sourceRanks = map[InstallSource]int{
+	SourceOther:     1,
+	SourceBrowser:   2,
+	SourceCurl:      3,
+	SourceAPI:       3,
+	SourceExtension: 4,
+	SourceStylus:    5,
+	SourceXStyle:    5,
+}
+
+// MoreSpecific checks if a source tells more about an install than another,
+// like a style manager fetching code after a browser opened it.
+func (s InstallSource) MoreSpecific(than InstallSource) bool {
+	return sourceRanks[s] > sourceRanks[than]
+}
+
+// SourceRank returns an SQL expression that ranks sources in a column the same
+// way as MoreSpecific, so that upserts don't replace a more specific source.
+func SourceRank(col string) string {
+	sources := make([]string, 0, len(sourceRanks))
+	for s := range sourceRanks {
+		sources = append(sources, string(s))
+	}
+	sort.Strings(sources)
+
+	var b strings.Builder
+	b.WriteString("CASE " + col)
+	for _, s := range sources {
+		b.WriteString(" WHEN '" + s + "' THEN ")
+		b.WriteString(strconv.Itoa(sourceRanks[InstallSource(s)]))
+	}
+	b.WriteString(" ELSE 0 END")
+
+	return b.String()
+}
+
+// ClassifyInstall finds where an install came from by its user agent, the
+// origin of an extension that fetched it, and whether it has a valid access
+// token.
+func ClassifyInstall(ua, origin string, token bool) InstallSource {
+	for _, scheme := range []string{"chrome-extension://", "moz-extension://", "safari-web-extension://"} {
+		if id, ok := strings.CutPrefix(origin, scheme); ok {
+			id, _, _ = strings.Cut(id, "/")
+			if s, ok := extensionIDs[id]; ok {
+				return s
+			}
+			return SourceExtension
+		}
+	}
+
+	switch {
+	case token:
+		return SourceAPI
+	case strings.HasPrefix(ua, "curl/"):
+		return SourceCurl
+	case strings.HasPrefix(ua, "Mozilla/"):
+		return SourceBrowser
+	default:
+		return SourceOther
+	}
+}
+
+// SourceCount is how many installs came from a source.
+type SourceCount struct {
+	Source   InstallSource `json:"source"`
+	Installs int64         `json:"installs"`
+}
+
+// GetInstallSources returns how many installs came from each source since a
+// time, for a style or the whole site if styleID is zero.
+func GetInstallSources(db *gorm.DB, styleID int, since time.Time) ([]SourceCount, error) {
+	source := "COALESCE(NULLIF(source, ''), '" + string(SourceUnknown) + "')"
+	tx := db.
+		Model(&Stats{}).
+		Select(source+" source, COUNT(*) installs").
+		Where("install > ?", since.UTC())
+	if styleID > 0 {
+		tx = tx.Where("style_id = ?", styleID)
+	}
+
+	var res []SourceCount
+	err := tx.Group(source).Order("installs DESC, source").Scan(&res).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
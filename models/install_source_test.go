@@ -0,0 +1,35 @@
+package models
+
+import "testing"
+
+func TestClassifyInstall(t *testing.T) {
+	t.Parallel()
+
+	const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/112.0"
+
+	cases := []struct {
+		desc, ua, origin string
+		token            bool
+		exp              InstallSource
+	}{
+		{"stylus", firefox, "chrome-extension://clngdbkpkpeebahjckkjfobafhncgmne", false, SourceStylus},
+		{"xstyle", firefox, "chrome-extension://hncgkmhphmncjohllpoleelnibpmccpj/install.html", false, SourceXStyle},
+		{"firefox extension", firefox, "moz-extension://0b9e0a3c-5d2b-4c4e-9a8e-3c1b2a4d5e6f", false, SourceExtension},
+		{"api client", "my-client/1.0", "", true, SourceAPI},
+		{"curl", "curl/8.1.2", "", false, SourceCurl},
+		{"browser", firefox, "https://userstyles.world/style/1", false, SourceBrowser},
+		{"other", "Stylish/2.0", "", false, SourceOther},
+	}
+	for _, c := range cases {
+		if got := ClassifyInstall(c.ua, c.origin, c.token); got != c.exp {
+			t.Errorf("%s: got %q, expected %q", c.desc, got, c.exp)
+		}
+	}
+
+	if !SourceStylus.MoreSpecific(SourceBrowser) || SourceBrowser.MoreSpecific(SourceStylus) {
+		t.Error("expected stylus to be more specific than browser")
+	}
+	if SourceXStyle.MoreSpecific(SourceStylus) {
+		t.Error("expected style managers to be equally specific")
+	}
+}
@@ -1,10 +1,12 @@
 package models
 
 import (
+	"strconv"
 	"time"
 
 	"gorm.io/gorm"
 
+	"userstyles.world/modules/config"
 	"userstyles.world/modules/errors"
 )
 
@@ -50,6 +52,21 @@ func CreateLog(db *gorm.DB, log *Log) (err error) {
 	return db.Model(modelLog).Create(log).Error
 }
 
+// GetLog returns a specific log entry.
+func GetLog(id int) (*Log, error) {
+	var l Log
+	if err := db().First(&l, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// AppealLink returns a link to the appeal form for this log entry.
+func (l *Log) AppealLink() string {
+	return config.BaseURL + "/styles/appeal/" + strconv.Itoa(int(l.ID))
+}
+
 // GetLogOfKind returns all the logs of the specified kind and
 // select the correct user Author.
 func GetLogOfKind(kind LogKind) ([]APILog, error) {
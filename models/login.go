@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// LoginRetention limits how long sign-in history is kept.
+const LoginRetention = 90 * 24 * time.Hour
+
+// Login is a successful sign-in to an account.
+type Login struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	UserID    uint      `gorm:"index"`
+	SID       string    `gorm:"column:sid"`
+	IPHash    string
+	UserAgent string
+
+	// Method is how user signed in, e.g. password, 2fa, passkey, or name of
+	// an OAuth provider.
+	Method string
+
+	// Device is a hash of a cookie that identifies a browser.
+	Device string `gorm:"index"`
+}
+
+// CreateLogin inserts a new sign-in.
+func CreateLogin(l *Login) error {
+	return db().Create(l).Error
+}
+
+// GetLogins returns a user's recent sign-ins, most recent ones first.
+func GetLogins(uid uint, limit int) ([]Login, error) {
+	var l []Login
+	err := db().
+		Where("user_id = ?", uid).
+		Order("id DESC").
+		Limit(limit).
+		Find(&l).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// KnownDevice checks if a user has signed in from a device before.  It also
+// reports whether user has any sign-in history.
+func KnownDevice(uid uint, device string) (known, history bool, err error) {
+	var n int64
+	err = db().Model(&Login{}).Where("user_id = ? AND device = ?", uid, device).Count(&n).Error
+	if err != nil || n > 0 {
+		return n > 0, n > 0, err
+	}
+
+	err = db().Model(&Login{}).Where("user_id = ?", uid).Count(&n).Error
+	return false, n > 0, err
+}
+
+// DeleteOldLogins removes sign-in history past its retention period.
+func DeleteOldLogins() (int64, error) {
+	tx := db().Where("created_at <= ?", time.Now().Add(-LoginRetention)).Delete(&Login{})
+	return tx.RowsAffected, tx.Error
+}
@@ -11,6 +11,9 @@ const (
 	KindStylePromotion
 	KindBannedStyle
 	KindRemovedReview
+	KindAcceptedAppeal
+	KindRejectedAppeal
+	KindWeeklyDigest
 )
 
 type Notification struct {
@@ -33,3 +36,14 @@ type Notification struct {
 func CreateNotification(db *gorm.DB, n *Notification) error {
 	return db.Create(&n).Error
 }
+
+// FindNotificationForLog returns a notification that points to a log entry.
+func FindNotificationForLog(kind Kind, logID, userID uint) (*Notification, error) {
+	var n Notification
+	err := db().First(&n, "kind = ? AND target_id = ? AND user_id = ?", kind, logID, userID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
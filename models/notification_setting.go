@@ -0,0 +1,155 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Channel is how users want to be notified about a kind of notification.
+type Channel uint8
+
+const (
+	ChannelNone Channel = iota
+	ChannelInApp
+	ChannelEmail
+)
+
+// ChannelOption is a channel as it's shown to users.
+type ChannelOption struct {
+	Channel Channel
+	Name    string
+}
+
+var (
+	channels = []ChannelOption{
+		{ChannelEmail, "Email and in-app"},
+		{ChannelInApp, "In-app only"},
+		{ChannelNone, "None"},
+	}
+	emailChannels = []ChannelOption{
+		{ChannelEmail, "Email"},
+		{ChannelNone, "None"},
+	}
+	requiredChannels = []ChannelOption{
+		{ChannelEmail, "Email and in-app"},
+		{ChannelInApp, "In-app only"},
+	}
+)
+
+// NotificationKind describes a kind of notification that users can configure.
+// Email-only kinds don't show up in notifications on the website.  Required
+// kinds always do, as users need them to appeal moderation actions.
+type NotificationKind struct {
+	Kind      Kind
+	Slug      string
+	Name      string
+	Default   Channel
+	EmailOnly bool
+	Required  bool
+}
+
+// NotificationKinds lists configurable kinds of notifications.  New kinds of
+// notifications should be added here with a default channel.
+var NotificationKinds = []NotificationKind{
+	{KindReview, "review", "New reviews of your styles", ChannelInApp, false, false},
+	{KindStylePromotion, "promotion", "Your styles being featured", ChannelEmail, false, false},
+	{KindBannedStyle, "removal", "Removals of your styles", ChannelEmail, false, true},
+	{KindRemovedReview, "review-removal", "Removals of your reviews", ChannelEmail, false, true},
+	{KindAcceptedAppeal, "appeal-accepted", "Accepted appeals", ChannelEmail, false, true},
+	{KindRejectedAppeal, "appeal-rejected", "Rejected appeals", ChannelEmail, false, true},
+	{KindWeeklyDigest, "weekly-digest", "Weekly stats of your styles", ChannelNone, true, false},
+}
+
+// Channels returns channels that users can choose for a kind of notification.
+func (nk NotificationKind) Channels() []ChannelOption {
+	switch {
+	case nk.EmailOnly:
+		return emailChannels
+	case nk.Required:
+		return requiredChannels
+	}
+	return channels
+}
+
+// Allows checks if users can choose a channel for a kind of notification.
+func (nk NotificationKind) Allows(c Channel) bool {
+	for _, o := range nk.Channels() {
+		if o.Channel == c {
+			return true
+		}
+	}
+	return false
+}
+
+// channel returns a channel that users chose, or in-app notifications for
+// required kinds that were turned off before they were required.
+func (nk NotificationKind) channel(c Channel) Channel {
+	if nk.Required && c == ChannelNone {
+		return ChannelInApp
+	}
+	return c
+}
+
+// FindNotificationKind returns a configurable kind of notification.
+func FindNotificationKind(k Kind) (NotificationKind, bool) {
+	for _, nk := range NotificationKinds {
+		if nk.Kind == k {
+			return nk, true
+		}
+	}
+	return NotificationKind{}, false
+}
+
+// NotificationSetting is a channel that a user chose for a kind of
+// notification.  Kinds without settings use their default channel.
+type NotificationSetting struct {
+	UserID  uint    `gorm:"primaryKey;autoIncrement:false"`
+	Kind    Kind    `gorm:"primaryKey;autoIncrement:false"`
+	Channel Channel `gorm:"not null"`
+}
+
+// GetNotificationSettings returns channels for all configurable kinds of
+// notifications.
+func GetNotificationSettings(db *gorm.DB, uid uint) (map[Kind]Channel, error) {
+	var list []NotificationSetting
+	if err := db.Where("user_id = ?", uid).Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	m := make(map[Kind]Channel, len(NotificationKinds))
+	for _, nk := range NotificationKinds {
+		m[nk.Kind] = nk.Default
+	}
+	for _, s := range list {
+		if nk, ok := FindNotificationKind(s.Kind); ok {
+			m[s.Kind] = nk.channel(s.Channel)
+		}
+	}
+
+	return m, nil
+}
+
+// NotificationChannel returns a channel for a kind of notification.
+func NotificationChannel(db *gorm.DB, uid uint, k Kind) (Channel, error) {
+	nk, _ := FindNotificationKind(k)
+
+	var s NotificationSetting
+	tx := db.Where("user_id = ? AND kind = ?", uid, k).Limit(1).Find(&s)
+	if tx.Error != nil {
+		return nk.Default, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nk.Default, nil
+	}
+
+	return nk.channel(s.Channel), nil
+}
+
+// SetNotificationChannel stores a channel for a kind of notification.
+func SetNotificationChannel(db *gorm.DB, uid uint, k Kind, c Channel) error {
+	s := NotificationSetting{UserID: uid, Kind: k, Channel: c}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{"channel"}),
+	}).Create(&s).Error
+}
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestNotificationKindChannels(t *testing.T) {
+	t.Parallel()
+
+	for _, nk := range NotificationKinds {
+		if !nk.Allows(nk.Default) {
+			t.Errorf("%s: default channel %d isn't allowed", nk.Slug, nk.Default)
+		}
+		if nk.Required && nk.Allows(ChannelNone) {
+			t.Errorf("%s: required kind can be turned off", nk.Slug)
+		}
+	}
+
+	nk, _ := FindNotificationKind(KindBannedStyle)
+	if got := nk.channel(ChannelNone); got != ChannelInApp {
+		t.Errorf("got %d, expected in-app notifications for removals", got)
+	}
+	nk, _ = FindNotificationKind(KindReview)
+	if got := nk.channel(ChannelNone); got != ChannelNone {
+		t.Errorf("got %d, expected reviews to stay turned off", got)
+	}
+}
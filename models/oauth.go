@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/subtle"
 	"database/sql/driver"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
@@ -9,6 +11,7 @@ import (
 	"gorm.io/gorm"
 
 	"userstyles.world/modules/errors"
+	"userstyles.world/modules/util"
 )
 
 type OAuth struct {
@@ -20,7 +23,8 @@ type OAuth struct {
 	Scopes       StringList `gorm:"type:varchar(255);"`
 	RedirectURI  string
 	ClientID     string
-	ClientSecret string
+	ClientSecret string // Hash of the secret, which is shown only once.
+	Public       bool   // Public clients can't keep a secret, and use PKCE.
 }
 
 type APIOAuth struct {
@@ -33,6 +37,16 @@ type APIOAuth struct {
 	Username     string
 	ClientID     string
 	ClientSecret string
+	Public       bool
+}
+
+// CheckSecret compares a client secret against the stored hash.
+func (o *APIOAuth) CheckSecret(secret string) bool {
+	if secret == "" || o.ClientSecret == "" {
+		return false
+	}
+	hash := util.HashToken(secret)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(o.ClientSecret)) == 1
 }
 
 // Custom []string time for the GORM.
@@ -129,6 +143,7 @@ func UpdateOAuth(o *OAuth, id string) error {
 	err := db().
 		Model(modelOAuth).
 		Where("id = ?", id).
+		Select("name", "description", "scopes", "redirect_uri", "public").
 		Updates(o).
 		Error
 	if err != nil {
@@ -137,3 +152,46 @@ func UpdateOAuth(o *OAuth, id string) error {
 
 	return nil
 }
+
+// UpdateClientSecret replaces the client secret of an OAuth application.
+func UpdateClientSecret(id uint, secret string) error {
+	return db().
+		Model(modelOAuth).
+		Where("id = ?", id).
+		UpdateColumn("client_secret", util.HashToken(secret)).
+		Error
+}
+
+// HashClientSecrets hashes client secrets that were stored in plain text.
+// Secrets that are already hashed are skipped, so it's safe to run it again.
+func HashClientSecrets(tx *gorm.DB) error {
+	var o []OAuth
+	err := tx.Select("id", "client_secret").
+		Where("client_secret <> ''").
+		Find(&o).
+		Error
+	if err != nil {
+		return err
+	}
+
+	for _, v := range o {
+		if isHashedSecret(v.ClientSecret) {
+			continue
+		}
+		err = tx.Model(&v).UpdateColumn("client_secret", util.HashToken(v.ClientSecret)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isHashedSecret checks if a secret looks like a hex-encoded SHA-256 hash.
+func isHashedSecret(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"userstyles.world/modules/util"
+)
+
+// OAuthConsent holds scopes that a user agreed to give to an OAuth
+// application.  Users are asked again when an application requests more.
+type OAuthConsent struct {
+	UserID  uint       `gorm:"primaryKey;autoIncrement:false"`
+	OAuthID uint       `gorm:"column:oauth_id;primaryKey;autoIncrement:false"`
+	Scopes  StringList `gorm:"type:varchar(255);"`
+}
+
+// TableName specify the table name that should be used.
+func (OAuthConsent) TableName() string {
+	return "oauth_consents"
+}
+
+// Covers checks if users agreed to give all of the scopes.
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	return util.EveryString(scopes, func(s string) bool {
+		return util.ContainsString(c.Scopes, s)
+	})
+}
+
+// FindOAuthConsent returns scopes that a user agreed to give to an OAuth
+// application.  Authorizations from before consents were saved covered the
+// application's scopes, as those were shown on the consent screen.
+func FindOAuthConsent(db *gorm.DB, u *User, oauth *APIOAuth) (*OAuthConsent, bool, error) {
+	var c OAuthConsent
+	tx := db.Where("user_id = ? AND oauth_id = ?", u.ID, oauth.ID).Limit(1).Find(&c)
+	if tx.Error != nil {
+		return nil, false, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		return &c, true, nil
+	}
+
+	if util.ContainsString(u.AuthorizedOAuth, strconv.FormatUint(uint64(oauth.ID), 10)) {
+		c = OAuthConsent{UserID: u.ID, OAuthID: oauth.ID, Scopes: oauth.Scopes}
+		return &c, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// SaveOAuthConsent stores scopes that a user agreed to give to an OAuth
+// application.
+func SaveOAuthConsent(db *gorm.DB, c *OAuthConsent) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "oauth_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"scopes"}),
+	}).Create(c).Error
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestOAuthConsentCovers(t *testing.T) {
+	t.Parallel()
+
+	c := OAuthConsent{Scopes: StringList{"user", "style"}}
+	cases := []struct {
+		scopes []string
+		exp    bool
+	}{
+		{nil, true},
+		{[]string{"user"}, true},
+		{[]string{"style", "user"}, true},
+		{[]string{"user", "openid"}, false},
+	}
+	for _, tc := range cases {
+		if got := c.Covers(tc.scopes); got != tc.exp {
+			t.Errorf("%v: got %t, expected %t", tc.scopes, got, tc.exp)
+		}
+	}
+}
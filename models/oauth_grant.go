@@ -0,0 +1,277 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"userstyles.world/modules/util"
+)
+
+var errorOAuthGrantNotFound = errors.New("oauth grant not found")
+
+// OAuthGrant is an authorization that a user gave to an OAuth application.
+// Access tokens are short-lived and refer to a grant, which is kept alive by
+// exchanging refresh tokens.  Only a hash of the current refresh token is
+// stored, and it's replaced every time it's used.
+type OAuthGrant struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	ExpiresAt   time.Time  `gorm:"index"`
+	LastUsedAt  time.Time  `gorm:"default:null"`
+	RefreshHash string     `gorm:"uniqueIndex;not null"`
+	Scopes      StringList `gorm:"type:varchar(255);"`
+	OAuthID     uint       `gorm:"column:oauth_id;index"`
+	StyleID     uint
+	UserID      uint `gorm:"index"`
+}
+
+// TableName specify the table name that should be used.
+func (OAuthGrant) TableName() string {
+	return "oauth_grants"
+}
+
+// Valid checks if a grant can be used.
+func (g *OAuthGrant) Valid() bool {
+	return g.ExpiresAt.After(time.Now())
+}
+
+// CreateOAuthGrant inserts a new grant.
+func CreateOAuthGrant(g *OAuthGrant) error {
+	return db().Create(g).Error
+}
+
+// FindOAuthGrant returns a valid grant by its ID.
+func FindOAuthGrant(id uint) (*OAuthGrant, error) {
+	var g OAuthGrant
+	if err := db().First(&g, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if !g.Valid() {
+		return nil, errorOAuthGrantNotFound
+	}
+
+	return &g, nil
+}
+
+// FindOAuthGrantByRefresh returns a valid grant by hash of its refresh token.
+func FindOAuthGrantByRefresh(hash string) (*OAuthGrant, error) {
+	var g OAuthGrant
+	if err := db().First(&g, "refresh_hash = ?", hash).Error; err != nil {
+		return nil, err
+	}
+	if !g.Valid() {
+		return nil, errorOAuthGrantNotFound
+	}
+
+	return &g, nil
+}
+
+// UpdateLastUsed records when a grant was used.
+func (g *OAuthGrant) UpdateLastUsed() error {
+	g.LastUsedAt = time.Now()
+	return db().Model(g).UpdateColumn("last_used_at", g.LastUsedAt).Error
+}
+
+// Rotate replaces the refresh token of a grant.  It fails if the refresh
+// token was already used, so that each one can be exchanged only once.
+func (g *OAuthGrant) Rotate(hash string, expiresAt time.Time) error {
+	tx := db().
+		Model(g).
+		Where("refresh_hash = ?", g.RefreshHash).
+		Updates(map[string]any{
+			"refresh_hash": hash,
+			"expires_at":   expiresAt,
+			"last_used_at": time.Now(),
+			"updated_at":   time.Now(),
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return errorOAuthGrantNotFound
+	}
+
+	g.RefreshHash = hash
+	g.ExpiresAt = expiresAt
+	g.LastUsedAt = time.Now()
+
+	return nil
+}
+
+// DeleteOAuthGrant revokes a grant that belongs to an OAuth application.
+func DeleteOAuthGrant(id, oauthID uint) error {
+	return db().Where("id = ? AND oauth_id = ?", id, oauthID).Delete(&OAuthGrant{}).Error
+}
+
+// DeleteExpiredOAuthGrants removes grants that can't be used anymore.
+func DeleteExpiredOAuthGrants() (int64, error) {
+	tx := db().Where("expires_at <= ?", time.Now()).Delete(&OAuthGrant{})
+	return tx.RowsAffected, tx.Error
+}
+
+// AuthorizedApp is an OAuth application that a user has authorized.
+type AuthorizedApp struct {
+	ID         uint
+	Name       string
+	Username   string
+	Scopes     []string
+	StyleIDs   []uint
+	LastUsedAt time.Time
+}
+
+// GetAuthorizedApps returns OAuth applications that a user has authorized,
+// along with scopes and styles that were granted to them.
+func GetAuthorizedApps(u *User) ([]AuthorizedApp, error) {
+	var grants []OAuthGrant
+	err := db().
+		Where("user_id = ? AND expires_at > ?", u.ID, time.Now()).
+		Find(&grants).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	for _, v := range u.AuthorizedOAuth {
+		if id, err := strconv.ParseUint(v, 10, 0); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	for _, g := range grants {
+		ids = append(ids, g.OAuthID)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var oauths []APIOAuth
+	err = db().
+		Model(modelOAuth).
+		Select("oauths.id, oauths.name, oauths.scopes, u.username").
+		Joins("join users u on u.id = oauths.user_id").
+		Where("oauths.id IN ?", ids).
+		Order("oauths.name").
+		Find(&oauths).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	apps := make([]AuthorizedApp, 0, len(oauths))
+	for _, o := range oauths {
+		app := AuthorizedApp{ID: o.ID, Name: o.Name, Username: o.Username}
+		granted := false
+		for _, g := range grants {
+			if g.OAuthID != o.ID {
+				continue
+			}
+			granted = true
+			for _, s := range g.Scopes {
+				if !util.ContainsString(app.Scopes, s) {
+					app.Scopes = append(app.Scopes, s)
+				}
+			}
+			if g.StyleID != 0 {
+				app.StyleIDs = append(app.StyleIDs, g.StyleID)
+			}
+			if g.LastUsedAt.After(app.LastUsedAt) {
+				app.LastUsedAt = g.LastUsedAt
+			}
+		}
+
+		// Authorizations without active tokens could use any of app's scopes.
+		if !granted {
+			app.Scopes = o.Scopes
+		}
+
+		apps = append(apps, app)
+	}
+
+	return apps, nil
+}
+
+// RevokeAuthorizedApp removes user's authorization of an OAuth application
+// and revokes all of its grants, so that its tokens stop working.
+func RevokeAuthorizedApp(db *gorm.DB, uid, oauthID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var u User
+		err := tx.Select("id", "authorized_o_auth").First(&u, "id = ?", uid).Error
+		if err != nil {
+			return err
+		}
+
+		id := strconv.FormatUint(uint64(oauthID), 10)
+		var authorized StringList
+		for _, v := range u.AuthorizedOAuth {
+			if v != id {
+				authorized = append(authorized, v)
+			}
+		}
+
+		err = tx.Model(&u).UpdateColumn("authorized_o_auth", authorized).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.
+			Where("user_id = ? AND oauth_id = ?", uid, oauthID).
+			Delete(&OAuthConsent{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return tx.
+			Where("user_id = ? AND oauth_id = ?", uid, oauthID).
+			Delete(&OAuthGrant{}).
+			Error
+	})
+}
+
+// OAuthStats are aggregate usage statistics of an OAuth application.
+type OAuthStats struct {
+	Users       int64
+	Tokens      int64
+	ActiveUsers int64
+}
+
+// GetOAuthStats returns aggregate usage statistics of an OAuth application.
+// Active users have used it in the last 30 days.
+func GetOAuthStats(oauthID uint) (*OAuthStats, error) {
+	var s OAuthStats
+	now := time.Now()
+
+	err := db().
+		Model(&User{}).
+		Where("authorized_o_auth LIKE ?", `%"`+strconv.FormatUint(uint64(oauthID), 10)+`"%`).
+		Count(&s.Users).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db().
+		Model(&OAuthGrant{}).
+		Where("oauth_id = ? AND expires_at > ?", oauthID, now).
+		Count(&s.Tokens).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db().
+		Model(&OAuthGrant{}).
+		Where("oauth_id = ? AND last_used_at > ?", oauthID, now.AddDate(0, 0, -30)).
+		Distinct("user_id").
+		Count(&s.ActiveUsers).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
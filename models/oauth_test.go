@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"userstyles.world/modules/util"
+)
+
+func TestIsHashedSecret(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		desc, secret string
+		exp          bool
+	}{
+		{"hashed", util.HashToken("secret"), true},
+		{"plain", "JpqL2nYx0vD3sKz8", false},
+		{"plain with hash length", "zzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzzz", false},
+	}
+	for _, c := range cases {
+		if got := isHashedSecret(c.secret); got != c.exp {
+			t.Errorf("%s: got %t, expected %t", c.desc, got, c.exp)
+		}
+	}
+}
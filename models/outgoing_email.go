@@ -0,0 +1,145 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailStatus is a delivery state of an outgoing email.
+type EmailStatus uint8
+
+const (
+	EmailPending EmailStatus = iota
+	EmailSent
+	EmailFailed
+)
+
+func (s EmailStatus) String() string {
+	switch s {
+	case EmailPending:
+		return "pending"
+	case EmailSent:
+		return "sent"
+	case EmailFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// OutgoingEmail is a rendered email waiting in the outbox.  Failed emails are
+// kept for admins to inspect or retry.
+type OutgoingEmail struct {
+	ID            uint `gorm:"primarykey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Status        EmailStatus `gorm:"index;default:0"`
+	Template      string
+	Recipient     string
+	Subject       string
+	Text          string
+	HTML          string
+	Unsubscribe   string
+	Attempts      int       `gorm:"default:0"`
+	NextAttemptAt time.Time `gorm:"index"`
+	LastError     string
+	SentAt        time.Time `gorm:"default:null"`
+}
+
+// CreateOutgoingEmail adds an email to the outbox.
+func CreateOutgoingEmail(db *gorm.DB, e *OutgoingEmail) error {
+	return db.Create(e).Error
+}
+
+// GetDueEmails returns pending emails that should be sent by now.
+func GetDueEmails(db *gorm.DB, now time.Time, limit int) ([]OutgoingEmail, error) {
+	var e []OutgoingEmail
+	err := db.
+		Where("status = ? AND next_attempt_at <= ?", EmailPending, now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&e).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// GetOutgoingEmails returns emails with a status, most recent ones first.
+func GetOutgoingEmails(db *gorm.DB, status EmailStatus, limit int) ([]OutgoingEmail, error) {
+	var e []OutgoingEmail
+	err := db.
+		Select("id", "created_at", "updated_at", "status", "template", "recipient", "subject",
+			"attempts", "next_attempt_at", "last_error", "sent_at").
+		Where("status = ?", status).
+		Order("id DESC").
+		Limit(limit).
+		Find(&e).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// CountOutgoingEmails returns how many emails there are with a status.
+func CountOutgoingEmails(db *gorm.DB, status EmailStatus) (int64, error) {
+	var n int64
+	err := db.Model(&OutgoingEmail{}).Where("status = ?", status).Count(&n).Error
+	return n, err
+}
+
+// MarkEmailSent records a successful delivery.  Bodies are cleared, as they
+// can hold password reset and verification links.
+func MarkEmailSent(db *gorm.DB, id uint, now time.Time) error {
+	return db.Model(&OutgoingEmail{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status":      EmailSent,
+			"attempts":    gorm.Expr("attempts + 1"),
+			"last_error":  "",
+			"sent_at":     now,
+			"text":        "",
+			"html":        "",
+			"unsubscribe": "",
+		}).Error
+}
+
+// MarkEmailFailed records a failed delivery.  Emails that won't be retried
+// should have status set to EmailFailed.
+func MarkEmailFailed(db *gorm.DB, e *OutgoingEmail) error {
+	return db.Model(&OutgoingEmail{}).Where("id = ?", e.ID).
+		Updates(map[string]any{
+			"status":          e.Status,
+			"attempts":        e.Attempts,
+			"next_attempt_at": e.NextAttemptAt,
+			"last_error":      e.LastError,
+		}).Error
+}
+
+// RetryOutgoingEmail moves a failed email back to the outbox.
+func RetryOutgoingEmail(db *gorm.DB, id uint) error {
+	tx := db.Model(&OutgoingEmail{}).
+		Where("id = ? AND status = ?", id, EmailFailed).
+		Updates(map[string]any{
+			"status":          EmailPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteSentEmails removes emails that were sent before a time.
+func DeleteSentEmails(db *gorm.DB, before time.Time) (int64, error) {
+	tx := db.Where("status = ? AND sent_at < ?", EmailSent, before).Delete(&OutgoingEmail{})
+	return tx.RowsAffected, tx.Error
+}
@@ -141,6 +141,8 @@ func (p *Pagination) SortStyles() string {
 		return "rating DESC"
 	case "ratinglow":
 		return "rating ASC"
+	case "trending":
+		return "trending DESC, installs DESC"
 	default:
 		return "styles.id ASC"
 	}
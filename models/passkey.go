@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var errorPasskeyNotFound = errors.New("passkey not found")
+
+// Passkey is a WebAuthn public key credential that can be used to sign in.
+type Passkey struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	LastUsedAt time.Time `gorm:"default:null"`
+	Name       string
+	UserID     uint `gorm:"index"`
+
+	// CredentialID is chosen by the authenticator and identifies a passkey.
+	CredentialID    []byte `gorm:"uniqueIndex;not null"`
+	PublicKey       []byte `gorm:"not null"`
+	AttestationType string
+	Transports      string
+	AAGUID          []byte
+	SignCount       uint32
+	UserVerified    bool
+	BackupEligible  bool
+	BackupState     bool
+}
+
+// TransportList returns transports reported by an authenticator.
+func (p *Passkey) TransportList() []string {
+	if p.Transports == "" {
+		return nil
+	}
+	return strings.Split(p.Transports, ",")
+}
+
+// CreatePasskey inserts a new passkey.
+func CreatePasskey(p *Passkey) error {
+	return db().Create(p).Error
+}
+
+// GetPasskeys returns passkeys that belong to a user.
+func GetPasskeys(uid uint) ([]Passkey, error) {
+	var p []Passkey
+	err := db().Where("user_id = ?", uid).Order("id ASC").Find(&p).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// FindPasskey returns a passkey by its credential ID.
+func FindPasskey(credentialID []byte) (*Passkey, error) {
+	var p Passkey
+	err := db().Where("credential_id = ?", credentialID).First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// UpdateUsage records a successful sign-in with a passkey.
+func (p *Passkey) UpdateUsage(signCount uint32, backupState bool) error {
+	p.SignCount = signCount
+	p.BackupState = backupState
+	p.LastUsedAt = time.Now()
+
+	return db().
+		Model(p).
+		Select("sign_count", "backup_state", "last_used_at").
+		Updates(p).
+		Error
+}
+
+// DeletePasskey removes a user's passkey.
+func DeletePasskey(db *gorm.DB, id, uid uint) error {
+	tx := db.Where("id = ? AND user_id = ?", id, uid).Delete(&Passkey{})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return errorPasskeyNotFound
+	}
+
+	return nil
+}
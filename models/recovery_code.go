@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a hashed single-use code that can be used instead of TOTP.
+type RecoveryCode struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UsedAt    time.Time `gorm:"default:null"`
+	Hash      string    `gorm:"unique;not null"`
+	UserID    uint      `gorm:"index"`
+}
+
+// ReplaceRecoveryCodes removes existing recovery codes and adds new ones.
+func ReplaceRecoveryCodes(db *gorm.DB, uid uint, hashes []string) error {
+	if err := db.Where("user_id = ?", uid).Delete(&RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	codes := make([]RecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		codes[i] = RecoveryCode{Hash: hash, UserID: uid}
+	}
+
+	return db.Create(&codes).Error
+}
+
+// UseRecoveryCode marks a recovery code as used, and reports whether it was
+// valid and unused.
+func UseRecoveryCode(uid uint, hash string) bool {
+	tx := db().Model(&RecoveryCode{}).
+		Where("user_id = ? AND hash = ? AND used_at IS NULL", uid, hash).
+		UpdateColumn("used_at", time.Now())
+
+	return tx.Error == nil && tx.RowsAffected == 1
+}
+
+// CountRecoveryCodes returns the number of unused recovery codes.
+func CountRecoveryCodes(uid uint) (int64, error) {
+	var i int64
+	err := db().Model(&RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", uid).
+		Count(&i).Error
+
+	return i, err
+}
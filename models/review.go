@@ -47,7 +47,12 @@ func FindAllForStyle(id any) (q []Review, err error) {
 }
 
 func (r *Review) CreateForStyle() error {
-	return db().Create(r).Error
+	return db().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(r).Error; err != nil {
+			return err
+		}
+		return UpdateStyleRating(tx, r.StyleID)
+	})
 }
 
 func (r *Review) FindLastFromUser(styleID, userID any) error {
@@ -81,11 +86,18 @@ func (r *Review) Permalink() string {
 
 // UpdateFromUser updates a review from its author.
 func (r *Review) UpdateFromUser() error {
-	return database.Conn.
-		Select("updated_at", "rating", "comment").
-		Where("id = ? AND user_id = ?", r.ID, r.UserID).
-		Updates(r).
-		Error
+	return database.Conn.Transaction(func(tx *gorm.DB) error {
+		err := tx.
+			Select("updated_at", "rating", "comment").
+			Where("id = ? AND user_id = ?", r.ID, r.UserID).
+			Updates(r).
+			Error
+		if err != nil {
+			return err
+		}
+
+		return updateReviewRating(tx, r.ID)
+	})
 }
 
 // GetReview returns a specific review, or an error if the review doesn't exist.
@@ -112,10 +124,25 @@ func DeleteReviewFromUser(id, uid int) error {
 			return err
 		}
 
-		return nil
+		return updateReviewRating(tx, uint(id))
 	})
 }
 
+// updateReviewRating recounts ratings of the style that a review belongs to.
+func updateReviewRating(tx *gorm.DB, id uint) error {
+	var sid uint
+	err := tx.Unscoped().Model(modelReview).
+		Select("style_id").
+		Where("id = ?", id).
+		Scan(&sid).
+		Error
+	if err != nil {
+		return err
+	}
+
+	return UpdateStyleRating(tx, sid)
+}
+
 // MatchReviewUser returns whether or not current user matches review's user.
 func MatchReviewUser(id, uid int) bool {
 	var i int64
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a signed-in device.  Its SID is stored in the "sid" claim of
+// session tokens, so that they can be revoked before they expire.
+type Session struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	ExpiresAt  time.Time `gorm:"index"`
+	LastSeenAt time.Time
+	SID        string `gorm:"column:sid;uniqueIndex;not null"`
+	UserID     uint   `gorm:"index"`
+	IP         string
+	Device     string
+
+	// Current is set for the session that made a request.
+	Current bool `gorm:"-"`
+}
+
+// Valid checks if a session can be used.
+func (s *Session) Valid() bool {
+	return s.ExpiresAt.After(time.Now())
+}
+
+// CreateSession inserts a new session.
+func CreateSession(s *Session) error {
+	return db().Create(s).Error
+}
+
+// FindSession returns a session by its SID.
+func FindSession(sid string) (*Session, error) {
+	var s Session
+	if err := db().Where("sid = ?", sid).First(&s).Error; err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetSessions returns active sessions of a user, most recent ones first.
+func GetSessions(uid uint) ([]Session, error) {
+	var s []Session
+	err := db().
+		Where("user_id = ? AND expires_at > ?", uid, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&s).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Touch updates when and where a session was last seen.
+func (s *Session) Touch(ip string) error {
+	s.IP = ip
+	s.LastSeenAt = time.Now()
+
+	return db().
+		Model(s).
+		Select("ip", "last_seen_at").
+		Updates(s).
+		Error
+}
+
+// DeleteSession removes a session by its SID.
+func DeleteSession(sid string) error {
+	return db().Where("sid = ?", sid).Delete(&Session{}).Error
+}
+
+// RevokeSession removes a user's session, and returns its SID.
+func RevokeSession(id, uid uint) (string, error) {
+	var s Session
+	if err := db().Where("id = ? AND user_id = ?", id, uid).First(&s).Error; err != nil {
+		return "", err
+	}
+
+	return s.SID, db().Delete(&s).Error
+}
+
+// RevokeSessions removes all sessions of a user except for the kept one, and
+// returns SIDs of removed sessions.
+func RevokeSessions(db *gorm.DB, uid uint, keep string) ([]string, error) {
+	var sids []string
+	err := db.Model(&Session{}).
+		Where("user_id = ? AND sid <> ?", uid, keep).
+		Pluck("sid", &sids).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Where("user_id = ? AND sid <> ?", uid, keep).Delete(&Session{}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return sids, nil
+}
+
+// DeleteExpiredSessions removes sessions that can't be used anymore.
+func DeleteExpiredSessions() (int64, error) {
+	tx := db().Where("expires_at <= ?", time.Now()).Delete(&Session{})
+	return tx.RowsAffected, tx.Error
+}
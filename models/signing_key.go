@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// SigningKey is a private key that signs ID tokens.  Keys are rotated, and
+// PrivateKey is stored encrypted.
+type SigningKey struct {
+	ID         uint      `gorm:"primarykey"`
+	CreatedAt  time.Time `gorm:"index"`
+	KID        string    `gorm:"column:kid;uniqueIndex;not null"`
+	PrivateKey string    `gorm:"not null"`
+}
+
+// CreateSigningKey inserts a new signing key.
+func CreateSigningKey(k *SigningKey) error {
+	return db().Create(k).Error
+}
+
+// GetSigningKeys returns keys created after a point in time, newest first.
+func GetSigningKeys(since time.Time) ([]SigningKey, error) {
+	var k []SigningKey
+	err := db().
+		Where("created_at > ?", since).
+		Order("created_at DESC").
+		Find(&k).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// DeleteSigningKeys removes keys created before a point in time.
+func DeleteSigningKeys(before time.Time) error {
+	return db().Where("created_at <= ?", before).Delete(&SigningKey{}).Error
+}
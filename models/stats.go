@@ -19,6 +19,7 @@ type Stats struct {
 	StyleID   int       `gorm:"index:idx_stats_installed; index:idx_stats_viewed; index:idx_stats_weekly_installs"`
 	Install   time.Time `gorm:"default:null; index:idx_stats_installed; index:idx_stats_weekly_installs"`
 	View      time.Time `gorm:"default:null; index:idx_stats_viewed"`
+	Source    InstallSource
 }
 
 type SiteStats struct {
@@ -53,7 +54,10 @@ func (DashStats) GetCounts(t string) (q []DashStats, err error) {
 
 // DeleteStats removes stats from database.
 func DeleteStats(db *gorm.DB, id int) error {
-	return db.Delete(&modelStats, "style_id = ?", id).Error
+	if err := db.Delete(&modelStats, "style_id = ?", id).Error; err != nil {
+		return err
+	}
+	return db.Delete(&DailyStats{}, "style_id = ?", id).Error
 }
 
 func GetHomepageStatistics() *SiteStats {
@@ -81,7 +85,8 @@ SELECT
 	 WHERE s.deleted_at IS NULL AND s.install > 0 AND
 	       s.created_at > @w) WeeklyInstalls,
 	(SELECT count(*) FROM stats s
-	 WHERE s.deleted_at IS NULL AND s.install > 0) TotalInstalls,
+	 WHERE s.deleted_at IS NULL AND s.install > 0) +
+	(SELECT COALESCE(SUM(installs), 0) FROM daily_stats) TotalInstalls,
 
 	(SELECT count(*) FROM stats s
 	 WHERE s.deleted_at IS NULL AND s.view > 0 AND
@@ -90,7 +95,8 @@ SELECT
 	 WHERE s.deleted_at IS NULL AND s.view > 0 AND
 	       s.created_at > @w) WeeklyViews,
 	(SELECT count(*) FROM stats s
-	 WHERE s.deleted_at IS NULL AND s.view > 0) TotalViews,
+	 WHERE s.deleted_at IS NULL AND s.view > 0) +
+	(SELECT COALESCE(SUM(views), 0) FROM daily_stats) TotalViews,
 
 	(SELECT count(*) FROM stats s
 	 WHERE s.deleted_at IS NULL AND s.install > 0 AND
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StatsSalt is a salt for hashing stats of a day.  Salts are removed after a
+// few days, so that hashes of visitors can't be linked to them anymore.
+type StatsSalt struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Salt      []byte
+}
+
+// Legacy checks if a salt stands for the stats key without a salt, which was
+// used to hash stats before salts.
+func (s StatsSalt) Legacy() bool {
+	return len(s.Salt) == 0
+}
+
+// GetStatsSalts returns salts created since a time, from newest to oldest.
+func GetStatsSalts(db *gorm.DB, since time.Time) ([]StatsSalt, error) {
+	var s []StatsSalt
+	err := db.
+		Where("created_at >= ?", since.UTC()).
+		Order("created_at DESC, id DESC").
+		Find(&s).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// CreateStatsSalt saves a new salt.
+func CreateStatsSalt(db *gorm.DB, salt []byte, now time.Time) (*StatsSalt, error) {
+	s := StatsSalt{CreatedAt: now.UTC(), Salt: salt}
+	if err := db.Create(&s).Error; err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// DeleteStatsSalts removes salts created before a time.  Stats that were
+// hashed with the legacy stats key, and haven't been rehashed since, are
+// erased with the legacy salt, as they could always be linked to visitors.
+func DeleteStatsSalts(db *gorm.DB, before time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var legacy []StatsSalt
+		err := tx.
+			Where("created_at < ? AND (salt IS NULL OR LENGTH(salt) = 0)", before.UTC()).
+			Find(&legacy).
+			Error
+		if err != nil {
+			return err
+		}
+
+		for _, s := range legacy {
+			err = tx.Model(&Stats{}).
+				Where("updated_at < ?", s.CreatedAt.UTC()).
+				Update("hash", gorm.Expr("'erased-' || id")).
+				Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("created_at < ?", before.UTC()).Delete(&StatsSalt{}).Error
+	})
+}
+
+// InitStatsSalts adds a legacy salt if stats were hashed before salts, so that
+// visitors who return in the next few days aren't counted twice.
+func InitStatsSalts(db *gorm.DB) error {
+	var salts, stats int64
+	if err := db.Model(&StatsSalt{}).Count(&salts).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&Stats{}).Count(&stats).Error; err != nil {
+		return err
+	}
+	if salts > 0 || stats == 0 {
+		return nil
+	}
+
+	_, err := CreateStatsSalt(db, nil, time.Now())
+	return err
+}
@@ -139,6 +139,22 @@ func GetStyleCount() (int, error) {
 	return int(c), nil
 }
 
+// FindStylesByIDs returns IDs, names and authors of styles.
+func FindStylesByIDs(db *gorm.DB, ids []uint) ([]Style, error) {
+	var s []Style
+	err := db.
+		Select("id", "name", "user_id").
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&s).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
 // GetStyleByID note: Using ID as a string is fine in this case.
 func GetStyleByID(id string) (*APIStyle, error) {
 	q := new(APIStyle)
@@ -261,6 +277,15 @@ func GetStyleFromAuthor(id, uid int) (Style, error) {
 	return s, err
 }
 
+// RestoreStyle reverts a removal of a userstyle.
+func RestoreStyle(db *gorm.DB, id uint) error {
+	return db.Unscoped().
+		Model(modelStyle).
+		Where("id = ?", id).
+		Update("deleted_at", nil).
+		Error
+}
+
 func (*Style) BanWhereUserID(id any) error {
 	return db().Delete(&Style{}, "user_id = ?", id).Error
 }
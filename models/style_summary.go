@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StyleSummary holds the latest stats and ratings of a style, so that style
+// cards don't have to look them up in histories and reviews for every row.
+type StyleSummary struct {
+	StyleID        uint `gorm:"primaryKey;autoIncrement:false"`
+	TotalViews     int64
+	TotalInstalls  int64
+	TotalUpdates   int64
+	WeeklyViews    int64
+	WeeklyInstalls int64
+	WeeklyUpdates  int64
+	Rating         float64
+	ReviewCount    int64
+	Trending       float64 `gorm:"index"`
+}
+
+const updateStyleTotals = `
+INSERT INTO style_summaries(
+	style_id,
+	total_views, total_installs, total_updates,
+	weekly_views, weekly_installs, weekly_updates
+)
+SELECT
+	style_id,
+	total_views, total_installs, total_updates,
+	weekly_views, weekly_installs, weekly_updates
+FROM histories
+WHERE id IN (SELECT MAX(id) FROM histories WHERE deleted_at IS NULL GROUP BY style_id)
+ON CONFLICT(style_id) DO UPDATE SET
+	total_views = excluded.total_views,
+	total_installs = excluded.total_installs,
+	total_updates = excluded.total_updates,
+	weekly_views = excluded.weekly_views,
+	weekly_installs = excluded.weekly_installs,
+	weekly_updates = excluded.weekly_updates
+`
+
+const updateStyleRating = `
+INSERT INTO style_summaries(style_id, rating, review_count)
+SELECT @id, COALESCE(AVG(rating), 0), COUNT(*)
+FROM reviews
+WHERE style_id = @id AND rating > 0 AND deleted_at IS NULL
+ON CONFLICT(style_id) DO UPDATE SET
+	rating = excluded.rating,
+	review_count = excluded.review_count
+`
+
+const addReviewedStyles = `
+INSERT INTO style_summaries(style_id)
+SELECT DISTINCT style_id FROM reviews WHERE true
+ON CONFLICT(style_id) DO NOTHING
+`
+
+const updateStyleRatings = `
+UPDATE style_summaries
+SET
+	rating = COALESCE(r.rating, 0),
+	review_count = COALESCE(r.review_count, 0)
+FROM style_summaries s
+LEFT JOIN (
+	SELECT style_id, AVG(rating) rating, COUNT(*) review_count
+	FROM reviews
+	WHERE rating > 0 AND deleted_at IS NULL
+	GROUP BY style_id
+) r ON r.style_id = s.style_id
+WHERE style_summaries.style_id = s.style_id
+`
+
+// UpdateStyleTotals copies stats from the latest snapshot of every style.
+func UpdateStyleTotals(db *gorm.DB) error {
+	return db.Exec(updateStyleTotals).Error
+}
+
+// UpdateStyleRating recounts ratings of a style.  It should be called after
+// a review of the style is added, changed or removed.
+func UpdateStyleRating(db *gorm.DB, id uint) error {
+	return db.Exec(updateStyleRating, map[string]any{"id": id}).Error
+}
+
+// UpdateReviewedStyles recounts ratings of styles that were reviewed by a
+// user.  It should be called after the user's reviews are removed.
+func UpdateReviewedStyles(db *gorm.DB, uid uint) error {
+	var ids []uint
+	err := db.Unscoped().Model(&Review{}).Distinct("style_id").
+		Where("user_id = ?", uid).
+		Pluck("style_id", &ids).
+		Error
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err = UpdateStyleRating(db, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InitStyleSummaries fills in summaries of all styles.
+func InitStyleSummaries(db *gorm.DB) error {
+	if err := UpdateStyleTotals(db); err != nil {
+		return err
+	}
+
+	// Styles without snapshots can still have ratings.
+	if err := db.Exec(addReviewedStyles).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(updateStyleRatings).Error; err != nil {
+		return err
+	}
+
+	return UpdateTrendingScores(db, time.Now())
+}
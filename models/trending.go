@@ -0,0 +1,138 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// trendingDays is how many days of installs are counted.
+	trendingDays = 14
+
+	// trendingHalfLife is how many days it takes for installs to count half.
+	trendingHalfLife = 3.0
+
+	// trendingMinInstalls keeps styles with a handful of installs out.
+	trendingMinInstalls = 5
+
+	// trendingPrior is added to the size of every style, so that a few
+	// installs of a new style don't outweigh steady growth of others.
+	trendingPrior = 50
+
+	// trendingSpike limits installs of a day to a multiple of the next busiest
+	// day, so that a single burst can't carry a style.
+	trendingSpike = 3
+
+	// trendingChunkSize limits how many scores are saved in one query.
+	trendingChunkSize = 500
+)
+
+// TrendingScore weighs installs of the last two weeks, where daily[i] is how
+// many installs a style had i days ago, so that recent ones count the most.
+// The sum is divided by the square root of how many installs the style had
+// before, so that small styles that grow quickly can rank next to big ones.
+func TrendingScore(daily []int64, total int64) float64 {
+	var sum, first, second int64
+	for _, n := range daily {
+		sum += n
+		if n > first {
+			first, second = n, first
+		} else if n > second {
+			second = n
+		}
+	}
+	if sum < trendingMinInstalls {
+		return 0
+	}
+
+	limit := float64(trendingSpike*second + 10)
+
+	var v float64
+	for age, n := range daily {
+		v += math.Min(float64(n), limit) * math.Pow(0.5, float64(age)/trendingHalfLife)
+	}
+
+	base := total - sum
+	if base < 0 {
+		base = 0
+	}
+
+	return v / math.Sqrt(float64(base+trendingPrior))
+}
+
+type trendingRow struct {
+	StyleID       uint
+	Age           int
+	DailyInstalls int64
+	TotalInstalls int64
+}
+
+// UpdateTrendingScores recalculates trending scores of all styles from their
+// snapshots.  Snapshots only count installs that got through bot filters.
+func UpdateTrendingScores(db *gorm.DB, now time.Time) error {
+	var rows []trendingRow
+	err := db.
+		Model(&History{}).
+		Select("style_id, daily_installs, total_installs, "+
+			"CAST(JULIANDAY(DATE(?)) - JULIANDAY(DATE(created_at)) AS INTEGER) age", now.UTC()).
+		Where("created_at >= DATE(?, ?)", now.UTC(), "-13 days").
+		Order("style_id, created_at").
+		Scan(&rows).
+		Error
+	if err != nil {
+		return err
+	}
+
+	scores := make(map[uint]float64)
+	for i := 0; i < len(rows); {
+		id := rows[i].StyleID
+		daily := make([]int64, trendingDays)
+		var total int64
+		for ; i < len(rows) && rows[i].StyleID == id; i++ {
+			if age := rows[i].Age; age >= 0 && age < trendingDays {
+				daily[age] += rows[i].DailyInstalls
+			}
+			total = rows[i].TotalInstalls
+		}
+		if s := TrendingScore(daily, total); s > 0 {
+			scores[id] = s
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&StyleSummary{}).
+			Where("trending != 0").
+			Update("trending", 0).
+			Error
+		if err != nil {
+			return err
+		}
+
+		list := make([]StyleSummary, 0, trendingChunkSize)
+		for id, s := range scores {
+			list = append(list, StyleSummary{StyleID: id, Trending: s})
+			if len(list) == trendingChunkSize {
+				if err = saveTrendingScores(tx, list); err != nil {
+					return err
+				}
+				list = list[:0]
+			}
+		}
+
+		return saveTrendingScores(tx, list)
+	})
+}
+
+func saveTrendingScores(tx *gorm.DB, list []StyleSummary) error {
+	if len(list) == 0 {
+		return nil
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "style_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"trending"}),
+	}).Create(&list).Error
+}
@@ -0,0 +1,38 @@
+package models
+
+import "testing"
+
+func TestTrendingScore(t *testing.T) {
+	t.Parallel()
+
+	days := func(n ...int64) []int64 {
+		d := make([]int64, trendingDays)
+		copy(d, n)
+		return d
+	}
+
+	if s := TrendingScore(days(1, 1, 1), 3); s != 0 {
+		t.Errorf("few installs: got %f, expected 0", s)
+	}
+
+	// Recent installs count more than older ones.
+	recent := TrendingScore(days(20, 0, 0, 0, 0, 0, 0, 0, 0, 0), 100)
+	old := TrendingScore(days(0, 0, 0, 0, 0, 0, 0, 0, 0, 20), 100)
+	if recent <= old {
+		t.Errorf("got %f for recent and %f for old installs", recent, old)
+	}
+
+	// A small style that's growing quickly ranks above a big one that isn't.
+	small := TrendingScore(days(15, 12, 10, 8, 5), 60)
+	big := TrendingScore(days(20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20), 100000)
+	if small <= big {
+		t.Errorf("got %f for small and %f for big style", small, big)
+	}
+
+	// A burst on a single day is limited.
+	burst := TrendingScore(days(5000), 5000)
+	steady := TrendingScore(days(60, 60, 60, 60, 60, 60, 60), 420)
+	if burst >= steady {
+		t.Errorf("got %f for burst and %f for steady installs", burst, steady)
+	}
+}
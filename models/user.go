@@ -22,6 +22,36 @@ type SocialMedia struct {
 	Codeberg string
 }
 
+// Set sets a profile link for an OAuth provider, and reports whether the
+// provider has one.
+func (s *SocialMedia) Set(provider, username string) bool {
+	switch provider {
+	case "github":
+		s.Github = username
+	case "gitlab":
+		s.Gitlab = username
+	case "codeberg":
+		s.Codeberg = username
+	default:
+		return false
+	}
+	return true
+}
+
+// Get returns a profile link for an OAuth provider.
+func (s SocialMedia) Get(provider string) string {
+	switch provider {
+	case "github":
+		return s.Github
+	case "gitlab":
+		return s.Gitlab
+	case "codeberg":
+		return s.Codeberg
+	default:
+		return ""
+	}
+}
+
 type User struct {
 	gorm.Model        `json:"-"`
 	Username          string    `gorm:"type:text COLLATE NOCASE;unique;not null" validate:"required,username,min=3,max=32"`
@@ -37,6 +67,14 @@ type User struct {
 	AuthorizedOAuth StringList `gorm:"type:text(255)"`
 	// The values within SocialMedia struct
 	Socials SocialMedia `gorm:"embedded"`
+	// TOTPSecret is encrypted, and it's empty if 2FA isn't enabled.
+	TOTPSecret string `json:"-"`
+	// TOTPStep is the last used time step, which prevents replay attacks.
+	TOTPStep int64 `json:"-" gorm:"default:0"`
+	// FailedLogins counts failed sign-in attempts since the last sign-in.
+	FailedLogins int `json:"-" gorm:"default:0"`
+	// LockedUntil delays sign-in attempts after repeated failures.
+	LockedUntil time.Time `json:"-" gorm:"default:null"`
 }
 
 type APIUser struct {
@@ -47,6 +85,7 @@ type APIUser struct {
 	ID          uint
 	Role        Role
 	Scopes      StringList
+	TwoFactor   bool
 }
 
 // HasSocials checks if user set any social media.
@@ -56,6 +95,11 @@ func (u User) HasSocials() bool {
 		u.Socials.Github != ""
 }
 
+// HasTOTP checks if user enabled two-factor authentication.
+func (u User) HasTOTP() bool {
+	return u.TOTPSecret != ""
+}
+
 // Name Return display name if it is set.
 func (u User) Name() string {
 	if u.DisplayName != "" {
@@ -185,3 +229,97 @@ func (u *User) UpdateLastPasswordRequest() error {
 	return db().Model(&u).Where("id", u.ID).
 		UpdateColumn("last_password_reset", time.Now()).Error
 }
+
+const (
+	// loginDelayAfter is how many failed attempts are allowed without delay.
+	loginDelayAfter = 3
+
+	// loginLockAfter is how many failed attempts lock an account.
+	loginLockAfter = 10
+
+	// loginLockout is how long a locked account can't be signed in to.
+	loginLockout = time.Hour
+)
+
+// loginDelay returns how long sign-in attempts are delayed after n failures.
+// Delays double with each failure until the account is locked.
+func loginDelay(n int) time.Duration {
+	switch {
+	case n < loginDelayAfter:
+		return 0
+	case n >= loginLockAfter:
+		return loginLockout
+	}
+
+	return 15 * time.Second << (n - loginDelayAfter)
+}
+
+// LockedFor returns how long sign-in attempts are delayed.
+func (u *User) LockedFor() time.Duration {
+	if d := time.Until(u.LockedUntil); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// FailLogin records a failed sign-in attempt, and delays further attempts.
+// The delay is derived from the stored count, so that concurrent attempts
+// can't lower each other's.
+func (u *User) FailLogin() error {
+	return db().Transaction(func(tx *gorm.DB) error {
+		q := "UPDATE users SET failed_logins = failed_logins + 1 WHERE id = ? RETURNING failed_logins"
+		if err := tx.Raw(q, u.ID).Scan(&u.FailedLogins).Error; err != nil {
+			return err
+		}
+
+		d := loginDelay(u.FailedLogins)
+		if d == 0 {
+			return nil
+		}
+		u.LockedUntil = time.Now().Add(d)
+
+		return tx.Model(modelUser).Where("id", u.ID).
+			UpdateColumn("locked_until", u.LockedUntil).Error
+	})
+}
+
+// ResetFailedLogins clears failed sign-in attempts.
+func (u *User) ResetFailedLogins() error {
+	if u.FailedLogins == 0 && u.LockedUntil.IsZero() {
+		return nil
+	}
+	u.FailedLogins, u.LockedUntil = 0, time.Time{}
+
+	return db().Model(modelUser).Where("id", u.ID).
+		UpdateColumns(map[string]any{
+			"failed_logins": 0,
+			"locked_until":  nil,
+		}).Error
+}
+
+// EnableTOTP stores user's encrypted TOTP secret and the time step that was
+// used to confirm it.
+func (u *User) EnableTOTP(db *gorm.DB, secret string, step int64) error {
+	return db.Model(modelUser).Where("id", u.ID).
+		Updates(map[string]any{"totp_secret": secret, "totp_step": step}).Error
+}
+
+// DisableTOTP removes user's TOTP secret and recovery codes.
+func (u *User) DisableTOTP(db *gorm.DB) error {
+	err := db.Model(modelUser).Where("id", u.ID).
+		Updates(map[string]any{"totp_secret": "", "totp_step": 0}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("user_id = ?", u.ID).Delete(&RecoveryCode{}).Error
+}
+
+// UseTOTPStep marks a time step as used, and reports whether it was unused.
+func (u *User) UseTOTPStep(step int64) bool {
+	tx := db().Model(modelUser).
+		Where("id = ? AND totp_step < ?", u.ID, step).
+		UpdateColumn("totp_step", step)
+
+	return tx.Error == nil && tx.RowsAffected == 1
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSocialMedia_Set(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		provider string
+		exp      bool
+	}{
+		{"github", "github", true},
+		{"gitlab", "gitlab", true},
+		{"codeberg", "codeberg", true},
+		{"openid connect", "keycloak", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var s SocialMedia
+			got := s.Set(c.provider, "user")
+			if got != c.exp {
+				t.Errorf("got: %v", got)
+				t.Errorf("exp: %v", c.exp)
+			}
+
+			link := s.Get(c.provider)
+			if c.exp && link != "user" {
+				t.Errorf("got: %q", link)
+				t.Errorf("exp: %q", "user")
+			}
+			if !c.exp && s != (SocialMedia{}) {
+				t.Errorf("unexpected link: %+v", s)
+			}
+		})
+	}
+}
+
+func TestLoginDelay(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		failures int
+		exp      time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, 15 * time.Second},
+		{4, 30 * time.Second},
+		{9, 16 * time.Minute},
+		{10, time.Hour},
+		{50, time.Hour},
+	}
+
+	for _, c := range cases {
+		if got := loginDelay(c.failures); got != c.exp {
+			t.Errorf("%d failures: got %s, expected %s", c.failures, got, c.exp)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package botfilter keeps bots and crawlers out of view and install stats.
+package botfilter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/ratelimit"
+	"userstyles.world/modules/util"
+)
+
+// Kinds of stats that requests are counted in.
+const (
+	View    = "view"
+	Install = "install"
+)
+
+// maxAgents limits how many filtered user agents are tracked.
+const maxAgents = 1000
+
+// maxAgentLength limits how much of a user agent is tracked.
+const maxAgentLength = 256
+
+var filtered = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "usw_stats_filtered_total",
+	Help: "Total amount of requests kept out of stats, by kind and rule.",
+}, []string{"kind", "rule"})
+
+func init() {
+	prometheus.MustRegister(filtered)
+}
+
+// Request describes a request that could be counted in stats.
+type Request struct {
+	Kind      string
+	Method    string
+	IP        string
+	UserAgent string
+	Accept    string
+
+	// Known is set when the caller recognized the client, like curl fetching
+	// a style, so that the agent rule lets it through.  Other rules still apply.
+	Known bool
+}
+
+// Rule checks if a request was made by a bot.
+type Rule struct {
+	Name  string
+	Match func(r Request, now time.Time) bool
+}
+
+var (
+	mu    sync.RWMutex
+	rules = []Rule{
+		{Name: "agent", Match: matchAgent},
+		{Name: "method", Match: matchMethod},
+		{Name: "accept", Match: matchAccept},
+		{Name: "burst", Match: matchBurst},
+	}
+)
+
+// extraAgents are user agent tokens added with BOT_AGENTS.
+var extraAgents = parseAgents(config.BotAgents)
+
+func parseAgents(s string) []string {
+	var list []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+// matchAgent filters known bots and clients without a user agent.
+func matchAgent(r Request, _ time.Time) bool {
+	if r.Known {
+		return false
+	}
+	if util.IsCrawler(r.UserAgent) {
+		return true
+	}
+	for _, s := range extraAgents {
+		if strings.Contains(r.UserAgent, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchMethod filters HEAD requests, which are made by link checkers and
+// uptime monitors, but never when viewing or installing a style.
+func matchMethod(r Request, _ time.Time) bool {
+	return r.Method != fiber.MethodGet
+}
+
+// matchAccept filters clients that don't send an Accept header, as browsers
+// and style managers always do.
+func matchAccept(r Request, _ time.Time) bool {
+	return r.Accept == ""
+}
+
+// matchBurst filters requests from IP addresses that go over views or
+// installs rate limit policies.  Requests aren't blocked, only not counted.
+func matchBurst(r Request, now time.Time) bool {
+	l := ratelimit.Get(r.Kind + "s")
+	if l == nil {
+		return false
+	}
+	return !l.Allow(r.IP, now).Allowed
+}
+
+// Register adds a rule to the end of the pipeline.
+func Register(r Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	rules = append(rules, r)
+}
+
+// Filter runs a request through rules in order, and returns the name of the
+// first rule that matched it, or an empty string if it should be counted.
+func Filter(r Request, now time.Time) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, rule := range rules {
+		if rule.Match(r, now) {
+			filtered.WithLabelValues(r.Kind, rule.Name).Inc()
+			agents.add(r.UserAgent, rule.Name, now)
+			return rule.Name
+		}
+	}
+
+	return ""
+}
+
+// NewRequest describes a request that could be counted in stats of a kind.
+func NewRequest(c *fiber.Ctx, kind string) Request {
+	return Request{
+		Kind:      kind,
+		Method:    c.Method(),
+		IP:        c.IP(),
+		UserAgent: string(c.Context().UserAgent()),
+		Accept:    c.Get(fiber.HeaderAccept),
+	}
+}
+
+// Allow checks if a request should be counted in stats of a kind.
+func Allow(c *fiber.Ctx, kind string) bool {
+	return Filter(NewRequest(c, kind), time.Now()) == ""
+}
+
+// Agent is a user agent that was kept out of stats by a rule.
+type Agent struct {
+	UserAgent string
+	Rule      string
+	Count     int64
+	LastSeen  time.Time
+}
+
+type agentKey struct{ ua, rule string }
+
+type agentList struct {
+	sync.Mutex
+	m map[agentKey]*Agent
+}
+
+var agents = agentList{m: make(map[agentKey]*Agent)}
+
+// add counts a filtered user agent.  The least recently seen agent makes room
+// for a new one if the list is full.
+func (l *agentList) add(ua, rule string, now time.Time) {
+	if len(ua) > maxAgentLength {
+		ua = ua[:maxAgentLength]
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	k := agentKey{ua, rule}
+	a, ok := l.m[k]
+	if !ok {
+		if len(l.m) >= maxAgents {
+			l.evict()
+		}
+		a = &Agent{UserAgent: strings.Clone(ua), Rule: rule}
+		l.m[k] = a
+	}
+	a.Count++
+	a.LastSeen = now
+}
+
+func (l *agentList) evict() {
+	var oldest agentKey
+	var t time.Time
+	for k, a := range l.m {
+		if t.IsZero() || a.LastSeen.Before(t) {
+			oldest, t = k, a.LastSeen
+		}
+	}
+	delete(l.m, oldest)
+}
+
+// TopAgents returns user agents that were filtered the most since startup.
+func TopAgents(n int) []Agent {
+	agents.Lock()
+	list := make([]Agent, 0, len(agents.m))
+	for _, a := range agents.m {
+		list = append(list, *a)
+	}
+	agents.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].LastSeen.After(list[j].LastSeen)
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+
+	return list
+}
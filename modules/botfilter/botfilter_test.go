@@ -0,0 +1,98 @@
+package botfilter
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"userstyles.world/modules/ratelimit"
+)
+
+const firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/112.0"
+
+func TestFilter(t *testing.T) {
+	defer func(m map[agentKey]*Agent) { agents.m = m }(agents.m)
+	agents.m = make(map[agentKey]*Agent)
+
+	ratelimit.Initialize()
+	now := time.Now()
+
+	cases := []struct {
+		desc string
+		r    Request
+		exp  string
+	}{
+		{"browser", Request{View, "GET", "10.0.0.1", firefox, "text/html", false}, ""},
+		{"style manager", Request{Install, "GET", "10.0.0.1", firefox, "*/*", false}, ""},
+		{"crawler", Request{View, "GET", "10.0.0.2", "Googlebot/2.1", "*/*", false}, "agent"},
+		{"no user agent", Request{View, "GET", "10.0.0.2", "", "*/*", false}, "agent"},
+		{"head", Request{Install, "HEAD", "10.0.0.2", firefox, "*/*", false}, "method"},
+		{"no accept", Request{Install, "GET", "10.0.0.2", firefox, "", false}, "accept"},
+		{"curl", Request{Install, "GET", "10.0.0.2", "curl/8.4.0", "*/*", false}, "agent"},
+		{"known curl", Request{Install, "GET", "10.0.0.2", "curl/8.4.0", "*/*", true}, ""},
+		{"known head", Request{Install, "HEAD", "10.0.0.2", "curl/8.4.0", "*/*", true}, "method"},
+	}
+	for _, c := range cases {
+		if got := Filter(c.r, now); got != c.exp {
+			t.Errorf("%s: got %q, expected %q", c.desc, got, c.exp)
+		}
+	}
+
+	// Bursts from one IP address are filtered, but others aren't affected.
+	limit := ratelimit.Get("views").Limit
+	r := Request{View, "GET", "10.0.0.3", firefox, "text/html", false}
+	for i := 0; i < limit; i++ {
+		if got := Filter(r, now); got != "" {
+			t.Fatalf("request %d: got %q, expected it to be counted", i, got)
+		}
+	}
+	if got := Filter(r, now); got != "burst" {
+		t.Errorf("got %q, expected burst", got)
+	}
+	r.IP = "10.0.0.4"
+	if got := Filter(r, now); got != "" {
+		t.Errorf("other IP: got %q, expected it to be counted", got)
+	}
+
+	// Agents are tracked by rule.
+	if list := TopAgents(10); len(list) != 7 {
+		t.Errorf("got %d agents, expected 7: %+v", len(list), list)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func(r []Rule) { rules = r }(rules)
+
+	Register(Rule{Name: "test", Match: func(r Request, _ time.Time) bool {
+		return r.IP == "10.1.0.1"
+	}})
+
+	r := Request{View, "GET", "10.1.0.1", firefox, "text/html", false}
+	if got := Filter(r, time.Now()); got != "test" {
+		t.Errorf("got %q, expected test", got)
+	}
+}
+
+func TestTopAgents(t *testing.T) {
+	defer func(m map[agentKey]*Agent) { agents.m = m }(agents.m)
+	agents.m = make(map[agentKey]*Agent)
+
+	now := time.Now()
+	for i := 0; i < maxAgents+10; i++ {
+		agents.add("agent "+strconv.Itoa(i), "agent", now.Add(time.Duration(i)))
+	}
+	agents.add("agent 500", "agent", now)
+	agents.add("agent 500", "agent", now)
+
+	if len(agents.m) != maxAgents {
+		t.Errorf("got %d agents, expected %d", len(agents.m), maxAgents)
+	}
+	if _, ok := agents.m[agentKey{"agent 0", "agent"}]; ok {
+		t.Error("least recently seen agent wasn't removed")
+	}
+
+	top := TopAgents(1)
+	if len(top) != 1 || top[0].UserAgent != "agent 500" || top[0].Count != 3 {
+		t.Errorf("unexpected top agents: %+v", top)
+	}
+}
@@ -24,6 +24,7 @@ func init() {
 		config.ProxyDir,
 		config.PublicDir,
 		config.StyleDir,
+		config.ChartDir,
 	}
 
 	// Create dir if it doesn't exist.
@@ -39,10 +40,6 @@ func init() {
 	for _, dir := range dirs {
 		createIfNotExist(dir)
 	}
-
-	// Run install/view stats.
-	InstallStats.Run()
-	ViewStats.Run()
 }
 
 func Initialize() {
@@ -50,6 +47,10 @@ func Initialize() {
 		log.Warn.Println("Failed to read cache:", err)
 	}
 	log.Info.Println("Loaded cache from disk.")
+
+	// Run install/view stats.
+	InstallStats.Run()
+	ViewStats.Run()
 }
 
 // SaveStore saves the state of in-memory cache to disk.
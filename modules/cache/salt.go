@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+// saltDays is how many days salts are kept for.  Visitors who return within
+// that time are recognized, so that they aren't counted twice.
+const saltDays = 7
+
+// saltRetry is how long to wait before loading salts again after it failed.
+const saltRetry = time.Minute
+
+// salts holds keys derived from salts of the last few days, from newest to
+// oldest.  A nil key stands for the legacy stats key.
+type salts struct {
+	sync.Mutex
+	date string
+	keys [][]byte
+
+	// retry and err are set when salts can't be loaded.
+	retry time.Time
+	err   error
+}
+
+var statsSalts salts
+
+// load removes expired salts and adds a salt for a new day.
+func (s *salts) load(now time.Time) error {
+	now = now.UTC()
+	cutoff := now.AddDate(0, 0, -saltDays)
+	if err := models.DeleteStatsSalts(database.Conn, cutoff); err != nil {
+		return err
+	}
+
+	rows, err := models.GetStatsSalts(database.Conn, cutoff)
+	if err != nil {
+		return err
+	}
+
+	// Today's salt goes first, and the legacy salt is never used for it.
+	date := now.Format("2006-01-02")
+	today := -1
+	for i, row := range rows {
+		if !row.Legacy() && row.CreatedAt.UTC().Format("2006-01-02") == date {
+			today = i
+			break
+		}
+	}
+	if today < 0 {
+		row, err := models.CreateStatsSalt(database.Conn, util.RandomBytes(32), now)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, *row)
+		today = len(rows) - 1
+	}
+	rows[0], rows[today] = rows[today], rows[0]
+
+	keys := make([][]byte, 0, len(rows))
+	for _, row := range rows {
+		if row.Legacy() {
+			keys = append(keys, nil)
+		} else {
+			keys = append(keys, util.SaltKey(row.Salt))
+		}
+	}
+
+	s.date, s.keys = date, keys
+	return nil
+}
+
+// hashes returns a hash of a record with today's salt, and hashes with salts
+// of previous days.
+func (s *salts) hashes(record string, now time.Time) (string, []string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.date != now.UTC().Format("2006-01-02") && !now.Before(s.retry) {
+		if s.err = s.load(now); s.err != nil {
+			// Keep using yesterday's salts until a new one can be saved.
+			s.retry = now.Add(saltRetry)
+			log.Database.Printf("Failed to load stats salts: %s\n", s.err)
+		}
+	}
+	if len(s.keys) == 0 {
+		return "", nil, s.err
+	}
+
+	res := make([]string, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key == nil {
+			h, err := util.HashIP(record)
+			if err != nil {
+				return "", nil, err
+			}
+			res = append(res, strings.Clone(h))
+		} else {
+			res = append(res, util.HashWithKey(key, record))
+		}
+	}
+
+	return res[0], res[1:], nil
+}
@@ -1,35 +1,60 @@
 package cache
 
 import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
 
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
 	"userstyles.world/modules/database"
 	"userstyles.world/modules/log"
-	"userstyles.world/modules/util"
 )
 
 // InstallStats stores stats for installs.
-var InstallStats = newStats("install")
+var InstallStats = newStats("install", true)
 
 // ViewStats stores stats for views.
-var ViewStats = newStats("view")
+var ViewStats = newStats("view", false)
 
-// stats stores moving parts of a stats cache.
+// chunkSize limits how many stats are upserted in one query, so that large
+// caches don't hold the database's write lock for long.
+const chunkSize = 500
+
+// hit is the first time a visitor was seen since the last upsert.  Stats of
+// returning visitors are found by hashes with salts of previous days.
+type hit struct {
+	styleID int
+	time    int64
+	prev    []string
+	source  models.InstallSource
+}
+
+// stats stores moving parts of a stats cache.  Hits are appended to a journal
+// on disk, so that they can be upserted after a crash.  Hits are keyed by
+// hashes with today's salt.
 type stats struct {
 	sync.Mutex
-	name  string
-	done  chan bool
-	m     map[string]string
-	timer *time.Ticker
-	gauge prometheus.Gauge
+	name    string
+	sources bool
+	dir     string
+	done    chan bool
+	m       map[string]hit
+	journal *os.File
+	timer   *time.Ticker
+	gauge   prometheus.Gauge
 }
 
 // newStats initializes a specific stats cache.
-func newStats(name string) *stats {
+func newStats(name string, sources bool) *stats {
 	counter := prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "usw_cache_stats_" + name,
 		Help: "Total amount of stats that was cached in " + name + " cache.",
@@ -37,16 +62,22 @@ func newStats(name string) *stats {
 	prometheus.MustRegister(counter)
 
 	return &stats{
-		name:  name,
-		done:  make(chan bool),
-		m:     make(map[string]string),
-		timer: time.NewTicker(time.Minute),
-		gauge: counter,
+		name:    name,
+		sources: sources,
+		dir:     config.CacheDir,
+		done:    make(chan bool),
+		m:       make(map[string]hit),
+		timer:   time.NewTicker(time.Minute),
+		gauge:   counter,
 	}
 }
 
-// Run starts a store in a separate goroutine.
+// Run replays journals of a store and starts it in a separate goroutine.
 func (s *stats) Run() {
+	if err := s.replay(); err != nil {
+		log.Warn.Printf("Failed to replay %q journals: %s\n", s.name, err)
+	}
+
 	go func() {
 		for {
 			select {
@@ -65,62 +96,279 @@ func (s *stats) Close() {
 	s.done <- true
 	s.timer.Stop()
 	s.UpsertAndEvict()
+
+	s.Lock()
+	defer s.Unlock()
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+}
+
+// journals returns journals of a store from oldest to newest.
+func (s *stats) journals() ([]string, error) {
+	return filepath.Glob(filepath.Join(s.dir, s.name+"-*.journal"))
+}
+
+// replay loads hits from journals that weren't upserted before shutting down.
+func (s *stats) replay() error {
+	files, err := s.journals()
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			// Skip lines that were cut off by a crash.
+			fields := strings.Fields(sc.Text())
+			if len(fields) < 3 || len(fields) > 5 {
+				continue
+			}
+			t, err := strconv.ParseInt(fields[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			id, err := strconv.Atoi(fields[1])
+			if err != nil {
+				continue
+			}
+
+			// Older journals don't have sources, and only some have
+			// previous hashes.
+			h := hit{styleID: id, time: t}
+			if len(fields) > 3 && fields[3] != "-" {
+				h.prev = strings.Split(fields[3], ",")
+			}
+			if len(fields) > 4 && fields[4] != "-" {
+				h.source = models.InstallSource(fields[4])
+			}
+
+			// Later lines win, as they can have a more specific source.
+			if old, found := s.m[fields[2]]; !found || t >= old.time {
+				s.m[fields[2]] = h
+			}
+		}
+		f.Close()
+
+		if err = sc.Err(); err != nil {
+			return err
+		}
+	}
+
+	if len(s.m) > 0 {
+		log.Info.Printf("Replayed %d stats from %q journals.\n", len(s.m), s.name)
+	}
+
+	return nil
+}
+
+// write appends a hit to the current journal.
+func (s *stats) write(hash string, h hit) error {
+	if s.journal == nil {
+		name := fmt.Sprintf("%s-%020d.journal", s.name, time.Now().UnixNano())
+		f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		s.journal = f
+	}
+
+	prev, source := strings.Join(h.prev, ","), string(h.source)
+	if prev == "" {
+		prev = "-"
+	}
+	if source == "" {
+		source = "-"
+	}
+
+	_, err := fmt.Fprintf(s.journal, "%d %d %s %s %s\n", h.time, h.styleID, hash, prev, source)
+	return err
 }
 
-// Upsert generates database query, executes it, and returns its status.
-func (s *stats) Upsert(count int) error {
+// upsert inserts or updates stats in chunks, and returns stats that weren't
+// upserted if it fails.  Timestamps of existing stats only move forward, so
+// that replaying a journal twice is harmless.
+func (s *stats) upsert(m map[string]hit) (map[string]hit, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	for start := 0; start < len(keys); start += chunkSize {
+		end := start + chunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		err := database.Conn.Transaction(func(tx *gorm.DB) error {
+			if err := rehash(tx, keys[start:end], m); err != nil {
+				return err
+			}
+			return s.insert(tx, keys[start:end], m)
+		})
+		if err != nil {
+			rest := make(map[string]hit, len(keys)-start)
+			for _, k := range keys[start:] {
+				rest[k] = m[k]
+			}
+			return rest, err
+		}
+	}
+
+	return nil, nil
+}
+
+// rehash moves stats of returning visitors to hashes with today's salt, so
+// that they keep when they were first seen.
+func rehash(tx *gorm.DB, keys []string, m map[string]hit) error {
+	var b strings.Builder
+	var when, in []any
+	for _, k := range keys {
+		for _, p := range m[k].prev {
+			b.WriteString(" WHEN ? THEN ?")
+			when = append(when, p, k)
+			in = append(in, p)
+		}
+	}
+	if len(in) == 0 {
+		return nil
+	}
+
+	q := "UPDATE OR IGNORE stats SET hash = CASE hash" + b.String() + " END "
+	q += "WHERE hash IN (?" + strings.Repeat(", ?", len(in)-1) + ")"
+	return tx.Exec(q, append(when, in...)...).Error
+}
+
+// insert upserts stats of visitors.
+func (s *stats) insert(tx *gorm.DB, keys []string, m map[string]hit) error {
+	cols, row := "", "(?, ?, ?, ?, ?)"
+	if s.sources {
+		cols, row = ", source", "(?, ?, ?, ?, ?, ?)"
+	}
+
 	var b strings.Builder
 	b.WriteString("INSERT INTO stats(created_at, updated_at, ")
-	b.WriteString(s.name + ", hash, style_id) VALUES ")
+	b.WriteString(s.name + ", hash, style_id" + cols + ") VALUES ")
 
-	ending := ", "
-	for k, v := range s.m {
-		count--
-		if count == 0 {
-			ending = " "
+	args := make([]any, 0, 6*len(keys))
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
 		}
+		b.WriteString(row)
 
-		// HACK: Might want to rethink that string split.
-		b.WriteString("(DATETIME('now'), DATETIME('now'), DATETIME('now'), '")
-		b.WriteString(v + "', " + strings.Split(k, " ")[1] + ")" + ending)
+		t := time.Unix(m[k].time, 0).UTC().Format("2006-01-02 15:04:05")
+		args = append(args, t, t, t, k, m[k].styleID)
+		if s.sources {
+			args = append(args, m[k].source)
+		}
 	}
 
-	b.WriteString("ON CONFLICT(hash) DO UPDATE SET ")
-	b.WriteString("updated_at = excluded.updated_at, ")
-	b.WriteString(s.name + " = excluded." + s.name)
+	b.WriteString(" ON CONFLICT(hash) DO UPDATE SET ")
+	b.WriteString("updated_at = MAX(updated_at, excluded.updated_at), ")
+	if s.sources {
+		// A more specific source replaces the saved one, like in add.
+		b.WriteString("source = CASE WHEN " + models.SourceRank("excluded.source"))
+		b.WriteString(" > " + models.SourceRank("source"))
+		b.WriteString(" THEN excluded.source ELSE source END, ")
+	}
+	b.WriteString(s.name + " = CASE WHEN " + s.name + " IS NULL OR ")
+	b.WriteString(s.name + " < excluded." + s.name + " THEN excluded." + s.name)
+	b.WriteString(" ELSE " + s.name + " END")
 
-	return database.Conn.Exec(b.String()).Error
+	return tx.Exec(b.String(), args...).Error
 }
 
-// UpsertAndEvict runs a database query and resets the map if it isn't empty.
+// UpsertAndEvict upserts cached stats and removes journals if it succeeds.
+// Stats that fail to upsert are kept for the next try.
 func (s *stats) UpsertAndEvict() {
 	s.Lock()
-	defer s.Unlock()
+	m := s.m
+	if len(m) == 0 {
+		s.Unlock()
+		return
+	}
+	s.m = make(map[string]hit)
+
+	// Start a new journal for hits that come in while upserting.
+	if s.journal != nil {
+		s.journal.Close()
+		s.journal = nil
+	}
+	files, err := s.journals()
+	s.Unlock()
+	if err != nil {
+		log.Warn.Printf("Failed to find %q journals: %s\n", s.name, err)
+	}
+
+	rest, err := s.upsert(m)
+	if err != nil {
+		log.Database.Printf("Failed to upsert %q: %s\n", s.name, err)
+
+		s.Lock()
+		for k, v := range rest {
+			if _, found := s.m[k]; !found {
+				s.m[k] = v
+			}
+		}
+		s.Unlock()
+		return
+	}
+	s.gauge.Set(float64(len(m)))
 
-	i := len(s.m)
-	if i > 0 {
-		if err := s.Upsert(i); err != nil {
-			log.Database.Printf("Failed to upsert %q: %s\n", s.name, err)
-		} else {
-			s.m = make(map[string]string)
-			s.gauge.Set(float64(i))
+	for _, name := range files {
+		if err = os.Remove(name); err != nil {
+			log.Warn.Printf("Failed to remove %q journal: %s\n", s.name, err)
 		}
 	}
 }
 
-// Add inserts a key in the map as well as its hashed value.
-func (s *stats) Add(key string) {
+// Add saves the first time a visitor was seen since the last upsert.
+func (s *stats) Add(ip, id string) {
+	s.add(ip, id, "", time.Now())
+}
+
+// AddFrom is like Add, but it also saves where an install came from.  A more
+// specific source replaces the one that was seen first.
+func (s *stats) AddFrom(ip, id string, source models.InstallSource) {
+	s.add(ip, id, source, time.Now())
+}
+
+func (s *stats) add(ip, id string, source models.InstallSource, now time.Time) {
+	styleID, err := strconv.Atoi(id)
+	if err != nil {
+		return
+	}
+
+	hash, prev, err := statsSalts.hashes(ip+" "+id, now)
+	if err != nil {
+		log.Info.Printf("Failed to create hash for %q: %s\n", ip, err)
+		return
+	}
+
 	s.Lock()
 	defer s.Unlock()
 
-	_, found := s.m[key]
-	if !found {
-		val, err := util.HashIP(key)
-		if err != nil {
-			log.Info.Printf("Failed to create hash for %q: %s\n", key, err)
+	h, found := s.m[hash]
+	if found {
+		if !source.MoreSpecific(h.source) {
 			return
 		}
-
-		s.m[key] = strings.Clone(val)
+		h.source = source
+	} else {
+		h = hit{styleID: styleID, time: now.Unix(), prev: prev, source: source}
+	}
+	s.m[hash] = h
+	if err = s.write(hash, h); err != nil {
+		log.Warn.Printf("Failed to write %q journal: %s\n", s.name, err)
 	}
 }
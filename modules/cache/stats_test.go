@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+func newTestStats(dir string) *stats {
+	return &stats{
+		name:    "install",
+		sources: true,
+		dir:     dir,
+		m:       make(map[string]hit),
+		gauge:   prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"}),
+	}
+}
+
+func countStats(t *testing.T) (i int64) {
+	t.Helper()
+	if err := database.Conn.Model(&models.Stats{}).Count(&i).Error; err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+func countJournals(t *testing.T, s *stats) int {
+	t.Helper()
+	files, err := s.journals()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func TestStatsJournal(t *testing.T) {
+	l := stdlog.New(io.Discard, "", 0)
+	log.Info, log.Warn, log.Database = l, l, l
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.New(l, logger.Config{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&models.StatsSalt{}); err != nil {
+		t.Fatal(err)
+	}
+	database.Conn = db
+	statsSalts = salts{}
+
+	dir := t.TempDir()
+	s := newTestStats(dir)
+	s.Add("127.0.0.1", "1")
+	s.Add("127.0.0.1", "1")
+	s.Add("127.0.0.1", "2")
+	s.Add("127.0.0.1", "x")
+	if len(s.m) != 2 {
+		t.Fatalf("got %d stats, expected 2", len(s.m))
+	}
+
+	// Upserts fail without a table, so stats and journals are kept.
+	s.UpsertAndEvict()
+	if len(s.m) != 2 || countJournals(t, s) != 1 {
+		t.Fatalf("got %d stats and %d journals, expected 2 and 1", len(s.m), countJournals(t, s))
+	}
+	s.Add("127.0.0.2", "1")
+	s.journal.Close()
+
+	// Replay journals after a crash.
+	if err = db.AutoMigrate(&models.Stats{}); err != nil {
+		t.Fatal(err)
+	}
+	s = newTestStats(dir)
+	if err = s.replay(); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.m) != 3 {
+		t.Fatalf("got %d replayed stats, expected 3", len(s.m))
+	}
+
+	s.UpsertAndEvict()
+	if i := countStats(t); i != 3 {
+		t.Errorf("got %d stats in database, expected 3", i)
+	}
+	if n := countJournals(t, s); n != 0 {
+		t.Errorf("got %d journals, expected 0", n)
+	}
+
+	// Replaying an old hit doesn't move timestamps back.
+	var before models.Stats
+	db.First(&before)
+	line := "1 " + strconv.Itoa(before.StyleID) + " " + before.Hash + "\n"
+	err = os.WriteFile(filepath.Join(dir, "install-1.journal"), []byte(line+"cut off"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.replay(); err != nil {
+		t.Fatal(err)
+	}
+	s.UpsertAndEvict()
+
+	var after models.Stats
+	db.First(&after, before.ID)
+	if !after.Install.Equal(before.Install) || !after.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("got %s, expected %s", after.Install, before.Install)
+	}
+}
+
+func TestStatsChunks(t *testing.T) {
+	l := stdlog.New(io.Discard, "", 0)
+	log.Info, log.Warn, log.Database = l, l, l
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.New(l, logger.Config{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&models.Stats{}, &models.StatsSalt{}); err != nil {
+		t.Fatal(err)
+	}
+	database.Conn = db
+	statsSalts = salts{}
+
+	s := newTestStats(t.TempDir())
+	n := 2*chunkSize + 10
+	for i := 0; i < n; i++ {
+		s.Add("10.0.0."+strconv.Itoa(i%250), strconv.Itoa(i/250+1))
+	}
+	s.UpsertAndEvict()
+
+	if i := countStats(t); i != int64(n) {
+		t.Errorf("got %d stats in database, expected %d", i, n)
+	}
+}
+
+func TestStatsSalts(t *testing.T) {
+	l := stdlog.New(io.Discard, "", 0)
+	log.Info, log.Warn, log.Database = l, l, l
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.New(l, logger.Config{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&models.Stats{}, &models.StatsSalt{}); err != nil {
+		t.Fatal(err)
+	}
+	database.Conn = db
+	statsSalts = salts{}
+
+	// Stats hashed before salts are carried over by the legacy salt.
+	s := newTestStats(t.TempDir())
+	legacy, err := util.HashIP("127.0.0.1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy = strings.Clone(legacy)
+	s.m[legacy] = hit{styleID: 1, time: time.Now().AddDate(0, 0, -2).Unix()}
+
+	// Stats of a visitor who doesn't return keep their legacy hash for now.
+	gone, err := util.HashIP("127.0.0.2 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone = strings.Clone(gone)
+	s.m[gone] = hit{styleID: 1, time: time.Now().AddDate(0, 0, -2).Unix()}
+	s.UpsertAndEvict()
+	if err = models.InitStatsSalts(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var first models.Stats
+	db.First(&first, "hash = ?", legacy)
+
+	// A returning visitor keeps the stats from when they were first seen.
+	for i := 0; i < 3; i++ {
+		s.add("127.0.0.1", "1", "", time.Now().AddDate(0, 0, i))
+		s.UpsertAndEvict()
+	}
+
+	var all []models.Stats
+	db.Find(&all, "id = ?", first.ID)
+	if len(statsSalts.keys) != 4 || len(all) != 1 {
+		t.Fatalf("got %d keys and %d stats, expected 4 and 1", len(statsSalts.keys), len(all))
+	}
+	if all[0].Hash == first.Hash || !all[0].CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("got %q created at %s, expected a new hash created at %s",
+			all[0].Hash, all[0].CreatedAt, first.CreatedAt)
+	}
+
+	// Salts are removed after a few days.
+	statsSalts.Lock()
+	err = statsSalts.load(time.Now().AddDate(0, 0, saltDays+3))
+	statsSalts.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	db.Model(&models.StatsSalt{}).Count(&n)
+	if n != 1 || len(statsSalts.keys) != 1 {
+		t.Errorf("got %d salts and %d keys, expected 1", n, len(statsSalts.keys))
+	}
+
+	// Stats hashed with the legacy key are erased with the legacy salt.
+	db.Model(&models.Stats{}).Where("hash = ?", gone).Count(&n)
+	if n != 0 {
+		t.Error("expected stats of a visitor who didn't return to be erased")
+	}
+	db.Model(&models.Stats{}).Where("hash = ?", all[0].Hash).Count(&n)
+	if n != 1 {
+		t.Error("expected stats of a returning visitor to be kept")
+	}
+	db.Model(&models.Stats{}).Count(&n)
+	if n != 2 {
+		t.Errorf("got %d stats, expected 2", n)
+	}
+
+	// Yesterday's salts are used until a new one can be saved.
+	if err = db.Migrator().DropTable(&models.StatsSalt{}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().AddDate(0, 0, saltDays+4)
+	h, _, err := statsSalts.hashes("127.0.0.1 1", now)
+	if err != nil || h == "" || statsSalts.retry.Before(now) {
+		t.Fatalf("got %q, %v and retry at %s, expected a hash and a later retry", h, err, statsSalts.retry)
+	}
+	if err = db.AutoMigrate(&models.StatsSalt{}); err != nil {
+		t.Fatal(err)
+	}
+	yesterday := statsSalts.date
+	if _, _, err = statsSalts.hashes("127.0.0.1 1", now); err != nil || statsSalts.date != yesterday {
+		t.Errorf("got %s and %v, expected salts not to be loaded before a retry", statsSalts.date, err)
+	}
+	if _, _, err = statsSalts.hashes("127.0.0.1 1", now.Add(saltRetry)); err != nil || statsSalts.date == yesterday {
+		t.Errorf("got %s and %v, expected salts to be loaded after a retry", statsSalts.date, err)
+	}
+}
+
+func TestStatsSources(t *testing.T) {
+	l := stdlog.New(io.Discard, "", 0)
+	log.Info, log.Warn, log.Database = l, l, l
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.New(l, logger.Config{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&models.Stats{}, &models.StatsSalt{}); err != nil {
+		t.Fatal(err)
+	}
+	database.Conn = db
+	statsSalts = salts{}
+
+	// A style manager fetching code after the browser opened it wins.
+	dir := t.TempDir()
+	s := newTestStats(dir)
+	s.AddFrom("127.0.0.1", "1", models.SourceBrowser)
+	s.AddFrom("127.0.0.1", "1", models.SourceStylus)
+	s.AddFrom("127.0.0.1", "1", models.SourceBrowser)
+	s.AddFrom("127.0.0.2", "1", models.SourceCurl)
+	s.AddFrom("127.0.0.2", "2", models.SourceExtension)
+	s.journal.Close()
+
+	// Sources are kept in journals.
+	s = newTestStats(dir)
+	if err = s.replay(); err != nil {
+		t.Fatal(err)
+	}
+	s.UpsertAndEvict()
+
+	// A less specific source doesn't replace a saved one.
+	s.AddFrom("127.0.0.1", "1", models.SourceBrowser)
+	s.UpsertAndEvict()
+
+	// Installs from before sources were saved are unknown.
+	if err = db.Exec("INSERT INTO stats(hash, style_id, install) VALUES('old', 1, DATETIME('now'))").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Now().AddDate(0, 0, -1)
+	got, err := models.GetInstallSources(db, 1, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []models.SourceCount{
+		{Source: models.SourceCurl, Installs: 1},
+		{Source: models.SourceStylus, Installs: 1},
+		{Source: models.SourceUnknown, Installs: 1},
+	}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("got %+v, expected %+v", got, exp)
+	}
+
+	if got, err = models.GetInstallSources(db, 0, since); err != nil || len(got) != 4 {
+		t.Errorf("got %+v, %v, expected 4 sources for all styles", got, err)
+	}
+}
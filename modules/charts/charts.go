@@ -11,6 +11,21 @@ import (
 )
 
 func GetStatsHistory(history []models.History) (dailyStats, totalStats string, err error) {
+	daily, err := renderStatsHistory(history, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	total, err := renderStatsHistory(history, true)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(daily), string(total), nil
+}
+
+// renderStatsHistory renders an SVG chart of daily or total stats.
+func renderStatsHistory(history []models.History, total bool) ([]byte, error) {
 	historyLen := len(history)
 	dates := make([]time.Time, 0, historyLen)
 	dailyViews := make([]float64, 0, historyLen)
@@ -29,8 +44,9 @@ func GetStatsHistory(history []models.History) (dailyStats, totalStats string, e
 	}
 
 	// Visualize daily stats.
-	dailyGraph := chart.Chart{
-		Width:      1248,
+	graph := chart.Chart{
+		Width:      chartWidth,
+		Height:     chartHeight,
 		Canvas:     chart.Style{ClassName: "bg inner"},
 		Background: chart.Style{ClassName: "bg outer"},
 		XAxis:      chart.XAxis{Name: "Date"},
@@ -53,23 +69,11 @@ func GetStatsHistory(history []models.History) (dailyStats, totalStats string, e
 			},
 		},
 	}
-	dailyGraph.Elements = []chart.Renderable{chart.Legend(&dailyGraph)}
-
-	daily := bytebufferpool.Get()
-	defer bytebufferpool.Put(daily)
-	dailyFailed := daily.Len() != 220
-	if err := dailyGraph.Render(chart.SVG, daily); err != nil && dailyFailed {
-		return "", "", err
-	}
 
 	// Visualize total stats.
-	totalGraph := chart.Chart{
-		Width:      1248,
-		Canvas:     chart.Style{ClassName: "bg inner"},
-		Background: chart.Style{ClassName: "bg outer"},
-		XAxis:      chart.XAxis{Name: "Date"},
-		YAxis:      chart.YAxis{Name: "Total count"},
-		Series: []chart.Series{
+	if total {
+		graph.YAxis = chart.YAxis{Name: "Total count"}
+		graph.Series = []chart.Series{
 			chart.TimeSeries{
 				Name:    "Total installs",
 				XValues: dates,
@@ -80,18 +84,18 @@ func GetStatsHistory(history []models.History) (dailyStats, totalStats string, e
 				XValues: dates,
 				YValues: totalViews,
 			},
-		},
+		}
 	}
-	totalGraph.Elements = []chart.Renderable{chart.Legend(&totalGraph)}
+	graph.Elements = []chart.Renderable{chart.Legend(&graph)}
 
-	total := bytebufferpool.Get()
-	defer bytebufferpool.Put(total)
-	totalFailed := total.Len() != 220
-	if err := totalGraph.Render(chart.SVG, total); err != nil && totalFailed {
-		return "", "", err
+	b := bytebufferpool.Get()
+	defer bytebufferpool.Put(b)
+	failed := b.Len() != 220
+	if err := graph.Render(chart.SVG, b); err != nil && failed {
+		return nil, err
 	}
 
-	return daily.String(), total.String(), nil
+	return append([]byte(nil), b.B...), nil
 }
 
 func GetModelHistory(vals []models.DashStats, t time.Time, title string) (string, error) {
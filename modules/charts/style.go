@@ -0,0 +1,152 @@
+package charts
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+const (
+	chartWidth  = 1248
+	chartHeight = 400
+
+	// minHistory is how many snapshots a style needs for its charts.
+	minHistory = 3
+)
+
+// Kinds of style charts.
+const (
+	KindDaily = "daily"
+	KindTotal = "total"
+)
+
+// themes hold colors of charts, which match the website's color schemes.
+// Charts are shown as images, so they can't use the website's CSS.
+var themes = map[string]string{
+	"dark":  `.bg{fill:#242424}path[style*='fill:rgba(255']{fill:#242424!important}path[style*='stroke:rgba(51']{stroke:#4d4d4d!important}text{fill:#a0a0a0!important}`,
+	"light": `.bg{fill:#dadada}path[style*='fill:rgba(255']{fill:#dadada!important}path[style*='stroke:rgba(51']{stroke:#9c9c9c!important}text{fill:#393939!important}`,
+}
+
+const lineStyle = `path:nth-last-child(-n+10){stroke-linecap:round;stroke-linejoin:round;stroke-width:2!important}`
+
+// ValidKind checks if a style chart exists for a kind.
+func ValidKind(kind string) bool {
+	return kind == KindDaily || kind == KindTotal
+}
+
+// ValidTheme checks if charts can be themed with a color scheme.
+func ValidTheme(theme string) bool {
+	_, ok := themes[theme]
+	return ok
+}
+
+func chartPath(id uint, kind string) string {
+	return filepath.Join(config.ChartDir, strconv.FormatUint(uint64(id), 10)+"-"+kind+".svg")
+}
+
+// HasStyleCharts checks if charts of a style have been rendered.
+func HasStyleCharts(id uint) bool {
+	_, err := os.Stat(chartPath(id, KindDaily))
+	return err == nil
+}
+
+// ReadStyleChart returns a rendered chart of a style.
+func ReadStyleChart(id uint, kind string) ([]byte, error) {
+	return os.ReadFile(chartPath(id, kind))
+}
+
+// Theme adds colors of a color scheme to a chart.
+func Theme(svg []byte, theme string) []byte {
+	i := bytes.IndexByte(svg, '>')
+	if i < 0 {
+		return svg
+	}
+	i++
+
+	style := "<style>" + themes[theme] + lineStyle + "</style>"
+	b := make([]byte, 0, len(svg)+len(style))
+	b = append(b, svg[:i]...)
+	b = append(b, style...)
+	return append(b, svg[i:]...)
+}
+
+// writeFile replaces a file, so that readers never see partial charts.
+func writeFile(name string, b []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// renderStyleCharts renders daily and total charts of a style.
+func renderStyleCharts(id uint, history []models.History) error {
+	for _, kind := range []string{KindDaily, KindTotal} {
+		b, err := renderStatsHistory(history, kind == KindTotal)
+		if err != nil {
+			return err
+		}
+		if err = writeFile(chartPath(id, kind), b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RenderStyleCharts renders charts of all styles, which should be done after
+// the nightly snapshot.  Charts of removed styles are deleted.
+func RenderStyleCharts() {
+	log.Info.Println("Rendering style charts.")
+	t := time.Now()
+
+	ids, err := models.GetStyleIDsWithHistory(database.Conn, minHistory)
+	if err != nil {
+		log.Database.Printf("Failed to get styles with history: %s\n", err)
+		return
+	}
+
+	// Old charts are kept if new ones fail to render.
+	keep := make(map[string]bool, 2*len(ids))
+	var n int
+	for _, id := range ids {
+		keep[filepath.Base(chartPath(id, KindDaily))] = true
+		keep[filepath.Base(chartPath(id, KindTotal))] = true
+
+		h, err := models.GetStyleHistory(strconv.FormatUint(uint64(id), 10))
+		if err != nil {
+			log.Database.Printf("Failed to get history of style %d: %s\n", id, err)
+			continue
+		}
+
+		if err = renderStyleCharts(id, h); err != nil {
+			log.Warn.Printf("Failed to render charts of style %d: %s\n", id, err)
+			continue
+		}
+		n++
+	}
+
+	files, err := os.ReadDir(config.ChartDir)
+	if err != nil {
+		log.Warn.Printf("Failed to read charts: %s\n", err)
+		return
+	}
+	for _, f := range files {
+		if !keep[f.Name()] && strings.HasSuffix(f.Name(), ".svg") {
+			if err = os.Remove(filepath.Join(config.ChartDir, f.Name())); err != nil {
+				log.Warn.Printf("Failed to remove chart %s: %s\n", f.Name(), err)
+			}
+		}
+	}
+
+	log.Info.Printf("Rendered charts of %d styles in %s.\n", n,
+		time.Since(t).Round(time.Millisecond))
+}
@@ -0,0 +1,58 @@
+package charts
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+)
+
+func TestRenderStyleCharts(t *testing.T) {
+	config.ChartDir = t.TempDir()
+
+	var h []models.History
+	for i := 0; i < 5; i++ {
+		s := models.History{DailyInstalls: int64(i), TotalInstalls: int64(10 * i)}
+		s.CreatedAt = time.Date(2026, 10, 1+i, 23, 59, 0, 0, time.UTC)
+		h = append(h, s)
+	}
+
+	if HasStyleCharts(1) {
+		t.Fatal("style has charts before they're rendered")
+	}
+	if err := renderStyleCharts(1, h); err != nil {
+		t.Fatal(err)
+	}
+	if !HasStyleCharts(1) {
+		t.Fatal("style doesn't have charts after they're rendered")
+	}
+
+	for _, kind := range []string{KindDaily, KindTotal} {
+		b, err := ReadStyleChart(1, kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(b, []byte("<svg")) {
+			t.Fatalf("%s: unexpected chart: %.100s", kind, b)
+		}
+
+		for theme := range themes {
+			got := Theme(b, theme)
+			i := bytes.IndexByte(b, '>') + 1
+			if !bytes.HasPrefix(got[i:], []byte("<style>"+themes[theme])) {
+				t.Errorf("%s: %s theme isn't after the svg tag: %.200s", kind, theme, got)
+			}
+		}
+	}
+
+	files, _ := os.ReadDir(config.ChartDir)
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".svg" {
+			t.Errorf("unexpected file %s", f.Name())
+		}
+	}
+}
@@ -26,6 +26,8 @@ var (
 	DBRandomData         = getEnvBool("DB_RANDOM_DATA", false)
 	DBRandomDataAmount   = getEnvInt("DB_RANDOM_DATA_AMOUNT", 100)
 	DBMaxOpenConns       = getEnvInt("DB_MAX_OPEN_CONNS", 10)
+	StatsRetentionDays   = getEnvInt("STATS_RETENTION_DAYS", 0)
+	StatsRetentionDryRun = getEnvBool("STATS_RETENTION_DRY_RUN", false)
 	Salt                 = getEnvInt("SALT", 10)
 	JWTSigningKey        = getEnv("JWT_SIGNING_KEY", "ABigSecretPassword")
 	VerifyJWTSigningKey  = getEnv("VERIFY_JWT_SIGNING_KEY", "OhNoWeCantUseTheSameAsJWTBeCaUseSeCuRiTy1337")
@@ -42,8 +44,12 @@ var (
 	GitlabClientSecret   = getEnv("GITLAB_CLIENT_SECRET", "www.youtube.com/watch?v=dQw4w9WgXcQ")
 	CodebergClientID     = getEnv("CODEBERG_CLIENT_ID", "SOmeOneGiVeMeIdEaSwHaTtOpUtHeRe")
 	CodebergClientSecret = getEnv("CODEBERG_CLIENT_SECRET", "IMgettinggboredd")
+	OIDCProviders        = getEnv("OIDC_PROVIDERS", "")
 	PerformanceMonitor   = getEnvBool("PERFORMANCE_MONITOR", false)
 	IMAPServer           = getEnv("IMAP_SERVER", "mail.userstyles.world:587")
+	DKIMDomain           = getEnv("DKIM_DOMAIN", "")
+	DKIMSelector         = getEnv("DKIM_SELECTOR", "")
+	DKIMKeyPath          = getEnv("DKIM_KEY_PATH", "")
 	ProxyMonitor         = getEnv("PROXY_MONITOR", "unset")
 	SearchReindex        = getEnvBool("SEARCH_REINDEX", false)
 
@@ -59,6 +65,7 @@ var (
 	CacheDir  = path.Join(DataDir, "cache")
 	ImageDir  = path.Join(DataDir, "images")
 	StyleDir  = path.Join(DataDir, "styles")
+	ChartDir  = path.Join(DataDir, "charts")
 	ProxyDir  = path.Join(DataDir, "proxy")
 	PublicDir = path.Join(DataDir, "public")
 
@@ -80,6 +87,8 @@ var (
 
 	CachedCodeItems = getEnvInt("CACHED_CODE_ITEMS", 250)
 	ProxyRealIP     = getEnv("PROXY_REAL_IP", "")
+	RateLimits      = getEnv("RATE_LIMITS", "")
+	BotAgents       = getEnv("BOT_AGENTS", "")
 )
 
 // OAuthURL returns the proper callback URL depending on the environment.
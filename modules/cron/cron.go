@@ -1,15 +1,20 @@
 package cron
 
 import (
+	"os"
 	"time"
 
 	"github.com/go-co-op/gocron"
 
-	// "userstyles.world/models"
 	// "userstyles.world/modules/cache"
+	"userstyles.world/models"
 	"userstyles.world/modules/cache"
+	"userstyles.world/modules/charts"
+	"userstyles.world/modules/config"
 	"userstyles.world/modules/database"
+	"userstyles.world/modules/database/retention"
 	"userstyles.world/modules/database/snapshot"
+	"userstyles.world/modules/email"
 	"userstyles.world/modules/log"
 	"userstyles.world/modules/mirror"
 	"userstyles.world/modules/sitemap"
@@ -21,11 +26,20 @@ func Initialize() {
 	s.WaitForScheduleAll()
 	s.StartAsync()
 
-	_, err := s.Cron("59 23 * * *").Do(func() { snapshot.StyleStatistics() })
+	_, err := s.Cron("59 23 * * *").Do(func() {
+		snapshot.StyleStatistics()
+		charts.RenderStyleCharts()
+	})
 	if err != nil {
 		log.Warn.Println("Failed to snapshot style statistics:", err.Error())
 	}
 
+	// Charts are only rendered after nightly snapshots, so render them now if
+	// there aren't any yet, like after an upgrade or on a new instance.
+	if files, err := os.ReadDir(config.ChartDir); err != nil || len(files) == 0 {
+		go charts.RenderStyleCharts()
+	}
+
 	/*
 		_, err = s.Every("1h").Do(func() {
 			cache.Store.Add("siteStatistics", models.GetHomepageStatistics(), 5*time.Minute)
@@ -55,6 +69,66 @@ func Initialize() {
 		log.Warn.Println("Failed to update sitemap:", err.Error())
 	}
 
+	_, err = s.Cron("15 3 * * *").Do(func() {
+		n, err := models.DeleteExpiredSessions()
+		if err != nil {
+			log.Database.Printf("Failed to delete expired sessions: %s\n", err)
+			return
+		}
+		log.Info.Printf("Deleted %d expired sessions.\n", n)
+	})
+	if err != nil {
+		log.Warn.Println("Failed to set expired sessions job:", err)
+	}
+
+	_, err = s.Cron("25 3 * * *").Do(func() {
+		n, err := models.DeleteOldLogins()
+		if err != nil {
+			log.Database.Printf("Failed to delete old logins: %s\n", err)
+			return
+		}
+		log.Info.Printf("Deleted %d old logins.\n", n)
+	})
+	if err != nil {
+		log.Warn.Println("Failed to set old logins job:", err)
+	}
+
+	_, err = s.Cron("35 3 * * *").Do(func() {
+		n, err := models.DeleteSentEmails(database.Conn, time.Now().AddDate(0, 0, -30))
+		if err != nil {
+			log.Database.Printf("Failed to delete sent emails: %s\n", err)
+			return
+		}
+		log.Info.Printf("Deleted %d sent emails.\n", n)
+	})
+	if err != nil {
+		log.Warn.Println("Failed to set sent emails job:", err)
+	}
+
+	_, err = s.Cron("20 3 * * *").Do(func() {
+		n, err := models.DeleteExpiredOAuthGrants()
+		if err != nil {
+			log.Database.Printf("Failed to delete expired OAuth grants: %s\n", err)
+			return
+		}
+		log.Info.Printf("Deleted %d expired OAuth grants.\n", n)
+	})
+	if err != nil {
+		log.Warn.Println("Failed to set expired OAuth grants job:", err)
+	}
+
+	if retention.Enabled() {
+		_, err = s.Cron("45 3 * * *").Do(func() { retention.Stats() })
+		if err != nil {
+			log.Warn.Println("Failed to set stats retention job:", err)
+		}
+	}
+
+	_, err = s.Cron("0 9 * * 1").Do(func() { email.SendDigests(time.Now()) })
+	if err != nil {
+		log.Warn.Println("Failed to set weekly digests job:", err)
+	}
+
 	_, err = s.Every("15m").Do(func() {
 		index, err := storage.GetStyleCompactIndex(database.Conn)
 		if err != nil {
@@ -30,6 +30,20 @@ var tables = []struct {
 	{"reviews", &models.Review{}},
 	{"notifications", &models.Notification{}},
 	{"external_users", &models.ExternalUser{}},
+	{"appeals", &models.Appeal{}},
+	{"recovery_codes", &models.RecoveryCode{}},
+	{"passkeys", &models.Passkey{}},
+	{"sessions", &models.Session{}},
+	{"logins", &models.Login{}},
+	{"outgoing_emails", &models.OutgoingEmail{}},
+	{"notification_settings", &models.NotificationSetting{}},
+	{"access_tokens", &models.AccessToken{}},
+	{"oauth_grants", &models.OAuthGrant{}},
+	{"oauth_consents", &models.OAuthConsent{}},
+	{"signing_keys", &models.SigningKey{}},
+	{"style_summaries", &models.StyleSummary{}},
+	{"daily_stats", &models.DailyStats{}},
+	{"stats_salts", &models.StatsSalt{}},
 }
 
 func connect() (*gorm.DB, error) {
@@ -89,7 +103,7 @@ func Initialize() {
 		shouldSeed = true
 	}
 
-	// Run one-time migrations.
+	// Run one-off migrations by hand.  Regular ones run with DB_MIGRATE.
 	if _, ok := os.LookupEnv("MAGIC"); ok {
 		runMigration(conn)
 		os.Exit(0)
@@ -108,6 +122,9 @@ func Initialize() {
 		if err := models.InitStyleSearch(); err != nil {
 			log.Database.Fatalf("Failed to init fts_styles: %s\n", err)
 		}
+		if err := models.HashClientSecrets(database.Conn); err != nil {
+			log.Database.Fatalf("Failed to hash client secrets: %s\n", err)
+		}
 	}
 
 	if shouldSeed {
@@ -116,6 +133,13 @@ func Initialize() {
 
 	// TODO: Simplify the entire process, including dropping and seeding data.
 	if config.DBMigrate {
+		if err := models.InitStyleSummaries(database.Conn); err != nil {
+			log.Database.Fatalf("Failed to init style_summaries: %s\n", err)
+		}
+		if err := models.InitStatsSalts(database.Conn); err != nil {
+			log.Database.Fatalf("Failed to init stats_salts: %s\n", err)
+		}
+
 		log.Info.Println("Database migration complete.")
 		os.Exit(0)
 	}
@@ -258,7 +282,7 @@ func seed() {
 			Description:  "Just some integration",
 			Scopes:       []string{"user", "style"},
 			ClientID:     "publicccc_client",
-			ClientSecret: "secreettUwU",
+			ClientSecret: util.HashToken("secreettUwU"),
 			RedirectURI:  "https://gusted.xyz/callback_helper",
 		},
 	}
@@ -17,9 +17,16 @@ func runMigration(db *gorm.DB) {
 	db.Config.Logger = db.Config.Logger.LogMode(logger.Info)
 
 	// Wrap in a transaction to allow rollbacks.
-	db.Transaction(func(tx *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := models.HashClientSecrets(tx); err != nil {
+			return err
+		}
 		return models.InitStyleSearch()
 	})
+	if err != nil {
+		log.Database.Printf("Migration failed: %s\n", err)
+		return
+	}
 
 	log.Database.Printf("Done in %s.\n", time.Since(t).Round(time.Microsecond))
 }
@@ -0,0 +1,103 @@
+// Package retention removes raw stats after a retention period.
+package retention
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+const (
+	// MinDays keeps raw stats for longer than daily and weekly counts of
+	// snapshots need them.
+	MinDays = 30
+
+	// chunkSize is how many raw stats are removed in one transaction, so
+	// that stats of visitors aren't blocked for long.
+	chunkSize = 5000
+	pause     = 100 * time.Millisecond
+)
+
+var (
+	removed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "usw_stats_retention_removed_total",
+		Help: "Total amount of raw stats that were added to daily stats and removed.",
+	})
+	expired = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "usw_stats_retention_expired",
+		Help: "Amount of raw stats past the retention period on the last run.",
+	})
+	duration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "usw_stats_retention_duration_seconds",
+		Help: "How long the last run took.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(removed, expired, duration)
+}
+
+// Enabled checks if raw stats should be removed.
+func Enabled() bool {
+	return config.StatsRetentionDays > 0
+}
+
+// Stats adds raw stats that are past the retention period to daily stats and
+// removes them.
+func Stats() {
+	days := config.StatsRetentionDays
+	if days < MinDays {
+		log.Warn.Printf("Stats retention has to be at least %d days, not %d.\n", MinDays, days)
+		return
+	}
+
+	log.Info.Println("Removing expired stats.")
+	t := time.Now()
+	defer func() { duration.Set(time.Since(t).Seconds()) }()
+
+	n, err := run(database.Conn, t.AddDate(0, 0, -days), config.StatsRetentionDryRun)
+	if err != nil {
+		log.Database.Printf("Failed to remove expired stats: %s\n", err)
+	}
+
+	if config.StatsRetentionDryRun {
+		log.Info.Printf("Dry run: %d stats would be removed.\n", n)
+		return
+	}
+	log.Info.Printf("Removed %d stats in %s.\n", n, time.Since(t).Round(time.Millisecond))
+}
+
+// run removes raw stats that weren't updated since a time, and returns how
+// many were removed.  Dry runs return how many would be removed.
+func run(db *gorm.DB, before time.Time, dry bool) (int64, error) {
+	n, err := models.CountExpiredStats(db, before)
+	if err != nil {
+		return 0, err
+	}
+	expired.Set(float64(n))
+
+	if dry {
+		return n, nil
+	}
+
+	var total int64
+	for {
+		i, err := models.CompactStats(db, before, chunkSize)
+		if err != nil {
+			return total, err
+		}
+		removed.Add(float64(i))
+
+		total += i
+		if i < chunkSize {
+			return total, nil
+		}
+		time.Sleep(pause)
+	}
+}
@@ -0,0 +1,123 @@
+package retention
+
+import (
+	"io"
+	stdlog "log"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"userstyles.world/models"
+)
+
+type totals struct {
+	Views, Installs, Updates int64
+}
+
+func getTotals(t *testing.T, db *gorm.DB, id int) totals {
+	t.Helper()
+
+	q := `SELECT
+	(SELECT COUNT(*) FROM stats WHERE style_id = @id AND deleted_at IS NULL AND view > 0) +
+	(SELECT COALESCE(SUM(views), 0) FROM daily_stats WHERE style_id = @id) views,
+	(SELECT COUNT(*) FROM stats WHERE style_id = @id AND deleted_at IS NULL AND install > 0) +
+	(SELECT COALESCE(SUM(installs), 0) FROM daily_stats WHERE style_id = @id) installs,
+	(SELECT COUNT(*) FROM stats WHERE style_id = @id AND deleted_at IS NULL AND install != created_at) +
+	(SELECT COALESCE(SUM(updates), 0) FROM daily_stats WHERE style_id = @id) updates`
+
+	var res totals
+	if err := db.Raw(q, map[string]any{"id": id}).Scan(&res).Error; err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestRun(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.New(stdlog.New(io.Discard, "", 0), logger.Config{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&models.Stats{}, &models.DailyStats{}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []struct {
+		id                          int
+		created, updated, view, ins string
+		deleted                     bool
+	}{
+		// Expired views, installs and an update on the same day.
+		{1, "-60 days", "-60 days", "-60 days", "", false},
+		{1, "-60 days", "-60 days", "", "-60 days", false},
+		{1, "-60 days", "-50 days", "-60 days", "-50 days", false},
+		// Expired stats on another day.
+		{1, "-45 days", "-45 days", "-45 days", "-45 days", false},
+		// Old stats that were updated recently are kept.
+		{1, "-90 days", "-2 days", "-90 days", "-2 days", false},
+		{1, "-1 days", "-1 days", "-1 days", "", false},
+		// Removed stats aren't counted.
+		{2, "-60 days", "-60 days", "-60 days", "-60 days", true},
+		{3, "-60 days", "-60 days", "", "-60 days", false},
+	}
+	for i, r := range rows {
+		q := `INSERT INTO stats(hash, style_id, created_at, updated_at, view, install, deleted_at)
+VALUES(?, ?, DATETIME('now', ?), DATETIME('now', ?), DATETIME('now', NULLIF(?, '')), DATETIME('now', NULLIF(?, '')), ?)`
+		var deleted any
+		if r.deleted {
+			deleted = time.Now()
+		}
+		err = db.Exec(q, i, r.id, r.created, r.updated, r.view, r.ins, deleted).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exp := map[int]totals{1: getTotals(t, db, 1), 2: {}, 3: getTotals(t, db, 3)}
+	before := time.Now().AddDate(0, 0, -MinDays)
+
+	n, err := run(db, before, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Errorf("dry run: got %d expired stats, expected 6", n)
+	}
+	var i int64
+	db.Model(&models.Stats{}).Unscoped().Count(&i)
+	if i != int64(len(rows)) {
+		t.Fatalf("dry run removed stats: got %d, expected %d", i, len(rows))
+	}
+
+	// Remove them in chunks.
+	if n, err = models.CompactStats(db, before, 4); err != nil || n != 4 {
+		t.Fatalf("got %d, %v, expected 4 removed stats", n, err)
+	}
+	if n, err = run(db, before, false); err != nil || n != 2 {
+		t.Fatalf("got %d, %v, expected 2 removed stats", n, err)
+	}
+
+	db.Model(&models.Stats{}).Unscoped().Count(&i)
+	if i != 2 {
+		t.Errorf("got %d stats, expected 2", i)
+	}
+
+	var days []models.DailyStats
+	db.Order("style_id, date").Find(&days)
+	if len(days) != 3 {
+		t.Fatalf("got %d daily stats, expected 3: %+v", len(days), days)
+	}
+	if d := days[0]; d.Views != 2 || d.Installs != 2 || d.Updates != 1 {
+		t.Errorf("got %+v, expected 2 views, 2 installs and 1 update", d)
+	}
+
+	for id, e := range exp {
+		if got := getTotals(t, db, id); got != e {
+			t.Errorf("style %d: got %+v, expected %+v", id, got, e)
+		}
+	}
+}
@@ -3,10 +3,13 @@ package snapshot
 import (
 	"time"
 
+	"userstyles.world/models"
 	"userstyles.world/modules/database"
 	"userstyles.world/modules/log"
 )
 
+// Stats of returning visitors are moved to hashes with new salts, so created_at
+// is when a visitor was first seen and later installs are counted as updates.
 const q = `
 INSERT INTO histories(
 	style_id, created_at, updated_at,
@@ -22,10 +25,15 @@ SELECT
 	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND view > DATE('now', '-7 days') AND created_at > DATE('now', '-7 days')) AS weekly_views,
 	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND install > DATE('now', '-7 days') AND created_at > DATE('now', '-7 days')) AS weekly_installs,
 	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND install > DATE('now', '-7 days') AND created_at != install) AS weekly_updates,
-	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND view > 0) AS total_views,
-	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND install > 0) AS total_installs,
-	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND install != created_at) AS total_updates
+	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND view > 0) + COALESCE(d.views, 0) AS total_views,
+	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND install > 0) + COALESCE(d.installs, 0) AS total_installs,
+	(SELECT COUNT(*) FROM stats WHERE style_id = s.id AND install != created_at) + COALESCE(d.updates, 0) AS total_updates
 FROM styles s
+LEFT JOIN (
+	SELECT style_id, SUM(views) views, SUM(installs) installs, SUM(updates) updates
+	FROM daily_stats
+	GROUP BY style_id
+) d ON d.style_id = s.id
 WHERE deleted_at IS NULL
 `
 
@@ -45,5 +53,12 @@ func StyleStatistics() {
 		time.Sleep(500 * time.Millisecond)
 	}
 
+	if err := models.UpdateStyleTotals(database.Conn); err != nil {
+		log.Database.Printf("Failed to update style summaries: %s\n", err)
+	}
+	if err := models.UpdateTrendingScores(database.Conn, time.Now()); err != nil {
+		log.Database.Printf("Failed to update trending scores: %s\n", err)
+	}
+
 	log.Info.Printf("Done in %s.\n", time.Since(t).Round(time.Microsecond))
 }
@@ -1,8 +1,10 @@
 package email
 
 import (
+	"bytes"
 	"strings"
 
+	"github.com/emersion/go-msgauth/dkim"
 	"github.com/emersion/go-sasl"
 	"github.com/emersion/go-smtp"
 
@@ -17,11 +19,12 @@ var (
 )
 
 type EmailBuilder struct {
-	to       string
-	from     string
-	subject  string
-	boundary string
-	parts    []MimePart
+	to          string
+	from        string
+	subject     string
+	unsubscribe string
+	boundary    string
+	parts       []MimePart
 }
 
 type MimePart struct {
@@ -49,6 +52,13 @@ func (eb *EmailBuilder) SetSubject(subject string) *EmailBuilder {
 	return eb
 }
 
+// SetUnsubscribe adds RFC 8058 headers, which let mail clients unsubscribe
+// users from non-transactional emails with one click.
+func (eb *EmailBuilder) SetUnsubscribe(link string) *EmailBuilder {
+	eb.unsubscribe = link
+	return eb
+}
+
 func NewPart() *MimePart {
 	return &MimePart{}
 }
@@ -128,11 +138,15 @@ func (eb *EmailBuilder) parseMultiPart() (string, error) {
 	return output, nil
 }
 
+// correctLineBreak converts line breaks to CRLF, which is what mail servers
+// send and DKIM signatures are computed over.
 func correctLineBreak(message string) string {
-	return strings.ReplaceAll(message, "\\n", clrf)
+	message = strings.ReplaceAll(message, clrf, "\n")
+	return strings.ReplaceAll(message, "\n", clrf)
 }
 
-func (eb *EmailBuilder) SendEmail(imapServer string) error {
+// build assembles a message, and signs it if opts isn't nil.
+func (eb *EmailBuilder) build(opts *dkim.SignOptions) ([]byte, error) {
 	eb.boundary = util.RandomString(30)
 
 	if eb.from == "" {
@@ -140,23 +154,46 @@ func (eb *EmailBuilder) SendEmail(imapServer string) error {
 	}
 
 	if eb.to == "" {
-		return errors.ErrNoToParameter
+		return nil, errors.ErrNoToParameter
 	}
 
 	if eb.subject == "" {
-		return errors.ErrNoSubject
+		return nil, errors.ErrNoSubject
 	}
 
 	bodyMessage, err := eb.parseMultiPart()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	r := strings.NewReader(correctLineBreak("From: " + eb.from + "\n" +
+	var headers string
+	if eb.unsubscribe != "" {
+		if strings.ContainsAny(eb.unsubscribe, "\r\n<>") {
+			return nil, errors.ErrInvalidHeader
+		}
+		headers = "List-Unsubscribe: <" + eb.unsubscribe + ">\n" +
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click\n"
+	}
+
+	msg := []byte(correctLineBreak("From: " + eb.from + "\n" +
 		"To: " + eb.to + "\n" +
 		"Subject: " + eb.subject + "\n" +
+		headers +
 		"MIME-Version: 1.0\n" +
 		bodyMessage))
 
-	return smtp.SendMail(imapServer, auth, eb.from, []string{eb.to}, r)
+	if opts == nil {
+		return msg, nil
+	}
+
+	return signDKIM(msg, opts)
+}
+
+func (eb *EmailBuilder) SendEmail(imapServer string) error {
+	msg, err := eb.build(dkimOptions)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(imapServer, auth, eb.from, []string{eb.to}, bytes.NewReader(msg))
 }
@@ -0,0 +1,93 @@
+package email
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+// digest is a weekly summary of an author's styles.
+type digest struct {
+	User   models.User
+	Styles []models.DigestStyle
+	Total  models.DigestStyle
+}
+
+// collectDigests returns digests for users who want them.  Styles without
+// any activity in the last two weeks are left out, and so are users who have
+// nothing to report.
+func collectDigests(db *gorm.DB, now time.Time) ([]digest, error) {
+	users, err := models.GetDigestRecipients(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []digest
+	for _, u := range users {
+		styles, err := models.GetDigestStyles(db, u.ID, now)
+		if err != nil {
+			return nil, err
+		}
+
+		d := digest{User: u}
+		for _, s := range styles {
+			if !s.Active() {
+				continue
+			}
+
+			d.Styles = append(d.Styles, s)
+			d.Total.Installs.This += s.Installs.This
+			d.Total.Installs.Last += s.Installs.Last
+			d.Total.Updates.This += s.Updates.This
+			d.Total.Updates.Last += s.Updates.Last
+			d.Total.Views.This += s.Views.This
+			d.Total.Views.Last += s.Views.Last
+			d.Total.Reviews += s.Reviews
+			d.Total.Ratings += s.Ratings
+		}
+		if len(d.Styles) > 0 {
+			list = append(list, d)
+		}
+	}
+
+	return list, nil
+}
+
+// SendDigests queues weekly digests with stats of authors' styles.
+func SendDigests(now time.Time) {
+	list, err := collectDigests(database.Conn, now)
+	if err != nil {
+		log.Database.Printf("Failed to collect weekly digests: %s\n", err)
+		return
+	}
+
+	var n int
+	from := now.AddDate(0, 0, -7)
+	for _, d := range list {
+		args := fiber.Map{
+			"User":    d.User,
+			"Styles":  d.Styles,
+			"Total":   d.Total,
+			"From":    from.Format("January 2"),
+			"To":      now.Format("January 2, 2006"),
+			"BaseURL": config.BaseURL,
+		}
+
+		title := "Your weekly stats on UserStyles.world"
+		err = sendNotification(database.Conn, d.User.ID, models.KindWeeklyDigest,
+			"user/digest", d.User.Email, title, args)
+		if err != nil {
+			log.Warn.Printf("Failed to send weekly digest to %d: %s\n", d.User.ID, err)
+			continue
+		}
+		n++
+	}
+
+	log.Info.Printf("Queued %d weekly digests.\n", n)
+}
@@ -0,0 +1,103 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"userstyles.world/models"
+)
+
+func initDigests(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.AutoMigrate(&models.User{}, &models.Style{}, &models.History{},
+		&models.Review{}, &models.NotificationSetting{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestCollectDigests(t *testing.T) {
+	db := initDigests(t)
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+
+	create := func(v any) {
+		t.Helper()
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, name := range []string{"author", "quiet", "other"} {
+		create(&models.User{Username: name, Email: name + "@example.com"})
+		create(&models.Style{UserID: uint(i + 1), Name: name + " style", Category: "example.com"})
+	}
+	create(&models.Style{UserID: 1, Name: "inactive style", Category: "example.com"})
+
+	// Other users don't want digests, so their styles are left out.
+	for _, uid := range []uint{1, 2} {
+		err := models.SetNotificationChannel(db, uid, models.KindWeeklyDigest, models.ChannelEmail)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, h := range []models.History{
+		{StyleID: 1, DailyInstalls: 5, DailyUpdates: 2, DailyViews: 20},
+		{StyleID: 1, DailyInstalls: 10, DailyUpdates: 2, DailyViews: 20},
+		{StyleID: 1, DailyInstalls: 4, DailyUpdates: 4, DailyViews: 50},
+		{StyleID: 1, DailyInstalls: 8, DailyUpdates: 1, DailyViews: 10},
+		{StyleID: 1, DailyInstalls: 100, DailyUpdates: 100, DailyViews: 100},
+		{StyleID: 3, DailyInstalls: 7, DailyUpdates: 7, DailyViews: 7},
+	} {
+		create(&h)
+	}
+	// Snapshots from this week, last week, and the one before it.
+	for id, d := range map[uint]int{1: 1, 2: 6, 3: 9, 4: 13, 5: 20, 6: 1} {
+		db.Model(&models.History{}).Where("id = ?", id).Update("created_at", day(d))
+	}
+
+	for _, r := range []models.Review{
+		{StyleID: 1, UserID: 3, Rating: 5, Comment: "Nice!"},
+		{StyleID: 1, UserID: 2, Rating: 4},
+		{StyleID: 1, UserID: 3, Comment: "Old review"},
+	} {
+		create(&r)
+	}
+	db.Model(&models.Review{}).Where("id IN ?", []uint{1, 2}).Update("created_at", day(3))
+	db.Model(&models.Review{}).Where("id = ?", 3).Update("created_at", day(10))
+
+	list, err := collectDigests(db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].User.ID != 1 || len(list[0].Styles) != 1 {
+		t.Fatalf("unexpected digests: %+v", list)
+	}
+
+	exp := models.DigestStyle{
+		ID:       1,
+		Name:     "author style",
+		Installs: models.WeeklyCount{This: 15, Last: 12},
+		Updates:  models.WeeklyCount{This: 4, Last: 5},
+		Views:    models.WeeklyCount{This: 40, Last: 60},
+		Reviews:  1,
+		Ratings:  2,
+	}
+	if got := list[0].Styles[0]; got != exp {
+		t.Errorf("got %+v, expected %+v", got, exp)
+	}
+	exp.ID, exp.Name = 0, ""
+	if got := list[0].Total; got != exp {
+		t.Errorf("got total %+v, expected %+v", got, exp)
+	}
+}
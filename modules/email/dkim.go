@@ -0,0 +1,106 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+
+	"github.com/emersion/go-msgauth/dkim"
+
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/errors"
+	"userstyles.world/modules/log"
+)
+
+// dkimOptions signs outgoing emails, or is nil if DKIM isn't configured.
+var dkimOptions *dkim.SignOptions
+
+// dkimHeaders lists header fields covered by signatures.  RFC 8058 requires
+// List-Unsubscribe headers to be signed for one-click unsubscribe to work.
+var dkimHeaders = []string{
+	"From",
+	"To",
+	"Subject",
+	"MIME-Version",
+	"Content-Type",
+	"Content-Transfer-Encoding",
+	"List-Unsubscribe",
+	"List-Unsubscribe-Post",
+}
+
+// InitDKIM loads a private key used to sign outgoing emails.  Signing is
+// disabled unless domain, selector and key path are all set.
+func InitDKIM() {
+	if config.DKIMDomain == "" || config.DKIMSelector == "" || config.DKIMKeyPath == "" {
+		return
+	}
+
+	b, err := os.ReadFile(config.DKIMKeyPath)
+	if err != nil {
+		log.Warn.Fatalf("Failed to read DKIM key: %s\n", err)
+	}
+
+	key, err := parseDKIMKey(b)
+	if err != nil {
+		log.Warn.Fatalf("Failed to parse DKIM key: %s\n", err)
+	}
+
+	dkimOptions = newDKIMOptions(config.DKIMDomain, config.DKIMSelector, key)
+	log.Info.Printf("Enabled DKIM signing for %s with selector %s.\n",
+		config.DKIMDomain, config.DKIMSelector)
+}
+
+// newDKIMOptions returns options that sign emails with relaxed
+// canonicalization, which survives minor changes made by mail servers.
+func newDKIMOptions(domain, selector string, key crypto.Signer) *dkim.SignOptions {
+	return &dkim.SignOptions{
+		Domain:                 domain,
+		Selector:               selector,
+		Signer:                 key,
+		Hash:                   crypto.SHA256,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             dkimHeaders,
+	}
+}
+
+// parseDKIMKey reads an RSA or Ed25519 private key in PKCS #8 format, or an
+// RSA private key in PKCS #1 format.
+func parseDKIMKey(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.ErrInvalidDKIMKey
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	default:
+		return nil, errors.ErrInvalidDKIMKey
+	}
+}
+
+// signDKIM prepends a DKIM-Signature header to a message.
+func signDKIM(msg []byte, opts *dkim.SignOptions) ([]byte, error) {
+	var b bytes.Buffer
+	if err := dkim.Sign(&b, bytes.NewReader(msg), opts); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
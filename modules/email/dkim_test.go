@@ -0,0 +1,151 @@
+package email
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+func dkimKeys(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkcs8 := func(key any) []byte {
+		b, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b})
+	}
+
+	return map[string][]byte{
+		"rsa-pkcs1": pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+		}),
+		"rsa-pkcs8": pkcs8(rsaKey),
+		"ed25519":   pkcs8(edKey),
+	}
+}
+
+// dkimRecord returns a DNS TXT record with the public key of a signer.
+func dkimRecord(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		b, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(b)
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)
+	default:
+		t.Fatalf("unexpected key %T", pub)
+		return ""
+	}
+}
+
+func verifyDKIM(t *testing.T, msg []byte, record string) error {
+	t.Helper()
+
+	list, err := dkim.VerifyWithOptions(bytes.NewReader(msg), &dkim.VerifyOptions{
+		LookupTXT: func(domain string) ([]string, error) {
+			if domain != "mail._domainkey.example.com" {
+				t.Errorf("unexpected lookup of %q", domain)
+			}
+			return []string{record}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("got %d signatures, expected 1", len(list))
+	}
+
+	return list[0].Err
+}
+
+func TestDKIM(t *testing.T) {
+	t.Parallel()
+
+	for name, b := range dkimKeys(t) {
+		key, err := parseDKIMKey(b)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		opts := newDKIMOptions("example.com", "mail", key)
+		record := dkimRecord(t, key)
+
+		msg, err := NewEmail().
+			SetFrom("test@example.com").
+			SetTo("other@example.com").
+			SetSubject("DKIM test").
+			SetUnsubscribe("https://example.com/unsubscribe/key").
+			AddPart(*NewPart().SetBody("Hello,\nthis message is signed.\n")).
+			AddPart(*NewPart().SetBody("<p>Hello,<br>this message is signed.</p>").HTML()).
+			build(opts)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !bytes.HasPrefix(msg, []byte("DKIM-Signature: ")) {
+			t.Fatalf("%s: message isn't signed:\n%s", name, msg)
+		}
+		if err = verifyDKIM(t, msg, record); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+
+		// Signatures cover unsubscribe links and bodies.
+		for _, s := range [][2]string{
+			{"unsubscribe/key", "unsubscribe/other"},
+			{"this message is signed", "this message is forged"},
+		} {
+			forged := bytes.Replace(msg, []byte(s[0]), []byte(s[1]), 1)
+			if err = verifyDKIM(t, forged, record); err == nil {
+				t.Errorf("%s: forged message passed verification", name)
+			}
+		}
+	}
+}
+
+func TestParseDKIMKey(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, b := range map[string][]byte{
+		"ecdsa":  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}),
+		"no pem": []byte("not a key"),
+	} {
+		if _, err := parseDKIMKey(b); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
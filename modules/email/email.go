@@ -6,7 +6,8 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
-	"userstyles.world/modules/config"
+	"userstyles.world/models"
+	"userstyles.world/modules/database"
 )
 
 var views fiber.Views
@@ -16,18 +17,18 @@ func SetRenderer(app *fiber.App) {
 	views = app.Config().Views
 }
 
-// Send renders templates with provided data, prepares an email, and sends it.
-func Send(tmpl, address, title string, args any) error {
+// render renders text and HTML templates of an email.
+func render(tmpl string, args any) (string, string, error) {
 	var text bytes.Buffer
 	err := views.Render(&text, "email/"+tmpl+".text", args)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	var html bytes.Buffer
 	err = views.Render(&html, "email/"+tmpl+".html", args)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	// if !config.Production {
@@ -35,10 +36,22 @@ func Send(tmpl, address, title string, args any) error {
 	// 	log.Info.Printf("\n%s%s\n\n%s", text.String(), divider, html.String())
 	// }
 
-	return NewEmail().
-		SetTo(address).
-		SetSubject(title).
-		AddPart(*NewPart().SetBody(text.String())).
-		AddPart(*NewPart().SetBody(html.String()).HTML()).
-		SendEmail(config.IMAPServer)
+	return text.String(), html.String(), nil
+}
+
+// Send renders templates with provided data, and queues an email in the
+// outbox.  Emails are sent in the background, and retried if sending fails.
+func Send(tmpl, address, title string, args any) error {
+	text, html, err := render(tmpl, args)
+	if err != nil {
+		return err
+	}
+
+	return enqueue(database.Conn, &models.OutgoingEmail{
+		Template:  tmpl,
+		Recipient: address,
+		Subject:   title,
+		Text:      text,
+		HTML:      html,
+	})
 }
@@ -0,0 +1,103 @@
+package email
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt"
+	"gorm.io/gorm"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/util"
+)
+
+// unsubscribeKind marks tokens in unsubscribe links.
+const unsubscribeKind = "unsubscribe"
+
+// SendNotification queues a non-transactional email about a kind of
+// notification, unless the user doesn't want emails for it.  Emails carry a
+// link that unsubscribes the user, which is passed to templates as
+// Unsubscribe.
+func SendNotification(uid uint, kind models.Kind, tmpl, address, title string, args fiber.Map) error {
+	return sendNotification(database.Conn, uid, kind, tmpl, address, title, args)
+}
+
+func sendNotification(db *gorm.DB, uid uint, kind models.Kind, tmpl, address, title string, args fiber.Map) error {
+	c, err := models.NotificationChannel(db, uid, kind)
+	if err != nil {
+		return err
+	}
+	if c != models.ChannelEmail {
+		return nil
+	}
+
+	link, err := UnsubscribeLink(uid, kind)
+	if err != nil {
+		return err
+	}
+	args["Unsubscribe"] = link
+
+	text, html, err := render(tmpl, args)
+	if err != nil {
+		return err
+	}
+
+	return enqueue(db, &models.OutgoingEmail{
+		Template:    tmpl,
+		Recipient:   address,
+		Subject:     title,
+		Text:        text,
+		HTML:        html,
+		Unsubscribe: link,
+	})
+}
+
+// UnsubscribeLink returns a link that stops emails about a kind of
+// notification.  Links don't expire, as they are kept in users' inboxes.
+func UnsubscribeLink(uid uint, kind models.Kind) (string, error) {
+	t, err := util.NewJWT().
+		SetClaim("kind", unsubscribeKind).
+		SetClaim("id", uid).
+		SetClaim("n", int(kind)).
+		GetSignedString(util.VerifySigningKey)
+	if err != nil {
+		return "", err
+	}
+
+	return config.BaseURL + "/unsubscribe/" + util.EncryptText(t, util.AEADCrypto, config.ScrambleConfig), nil
+}
+
+// ParseUnsubscribeKey returns user ID and kind of notification from a link
+// that unsubscribes users.
+func ParseUnsubscribeKey(key string) (uint, models.Kind, bool) {
+	text, err := util.DecryptText(key, util.AEADCrypto, config.ScrambleConfig)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	token, err := jwt.Parse(text, util.VerifyJwtKeyFunction)
+	if err != nil || !token.Valid {
+		return 0, 0, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["kind"] != unsubscribeKind {
+		return 0, 0, false
+	}
+
+	id, ok := claims["id"].(float64)
+	if !ok {
+		return 0, 0, false
+	}
+	n, ok := claims["n"].(float64)
+	if !ok {
+		return 0, 0, false
+	}
+
+	kind := models.Kind(n)
+	if _, ok = models.FindNotificationKind(kind); !ok {
+		return 0, 0, false
+	}
+
+	return uint(id), kind, true
+}
@@ -0,0 +1,168 @@
+package email
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"gorm.io/gorm"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/database"
+	"userstyles.world/modules/log"
+)
+
+const (
+	// outboxInterval is how often the outbox is checked for due emails.
+	outboxInterval = 30 * time.Second
+
+	// outboxBatch limits how many emails are loaded at once.
+	outboxBatch = 20
+
+	// maxAttempts is how many times an email is tried before it's moved to
+	// failed emails.
+	maxAttempts = 8
+
+	// retryBase is the delay after the first failed attempt, which doubles
+	// with each attempt up to retryMax.
+	retryBase = time.Minute
+	retryMax  = 6 * time.Hour
+)
+
+var outbox = struct {
+	once sync.Once
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}{
+	wake: make(chan struct{}, 1),
+	done: make(chan struct{}),
+}
+
+// enqueue stores a rendered email in the outbox, and wakes up the sender.
+func enqueue(db *gorm.DB, e *models.OutgoingEmail) error {
+	e.Status = models.EmailPending
+	e.NextAttemptAt = time.Now()
+	if err := models.CreateOutgoingEmail(db, e); err != nil {
+		return err
+	}
+
+	select {
+	case outbox.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// Run starts sending emails from the outbox in a separate goroutine.
+func Run() {
+	outbox.wg.Add(1)
+	go func() {
+		defer outbox.wg.Done()
+
+		t := time.NewTicker(outboxInterval)
+		defer t.Stop()
+
+		for {
+			processOutbox(database.Conn, config.IMAPServer, time.Now())
+
+			select {
+			case <-t.C:
+			case <-outbox.wake:
+			case <-outbox.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the sender after it finishes the current email.
+func Close() {
+	outbox.once.Do(func() { close(outbox.done) })
+	outbox.wg.Wait()
+}
+
+// processOutbox sends due emails, and schedules retries for failed ones.
+func processOutbox(db *gorm.DB, server string, now time.Time) {
+	for {
+		list, err := models.GetDueEmails(db, now, outboxBatch)
+		if err != nil {
+			log.Database.Printf("Failed to get due emails: %s\n", err)
+			return
+		}
+
+		for i := range list {
+			select {
+			case <-outbox.done:
+				return
+			default:
+			}
+
+			sendOutgoing(db, server, &list[i], now)
+		}
+
+		if len(list) < outboxBatch {
+			return
+		}
+	}
+}
+
+// sendOutgoing tries to deliver an email, and records the outcome.
+func sendOutgoing(db *gorm.DB, server string, e *models.OutgoingEmail, now time.Time) {
+	err := NewEmail().
+		SetTo(e.Recipient).
+		SetSubject(e.Subject).
+		SetUnsubscribe(e.Unsubscribe).
+		AddPart(*NewPart().SetBody(e.Text)).
+		AddPart(*NewPart().SetBody(e.HTML).HTML()).
+		SendEmail(server)
+	if err == nil {
+		if err = models.MarkEmailSent(db, e.ID, now); err != nil {
+			log.Database.Printf("Failed to mark email %d as sent: %s\n", e.ID, err)
+		}
+		return
+	}
+
+	e.Attempts++
+	e.LastError = err.Error()
+	if permanent(err) || e.Attempts >= maxAttempts {
+		e.Status = models.EmailFailed
+		log.Warn.Printf("kind=email-failed id=%d template=%s attempts=%d err=%q\n",
+			e.ID, e.Template, e.Attempts, e.LastError)
+	} else {
+		e.NextAttemptAt = now.Add(retryDelay(e.Attempts))
+		log.Warn.Printf("kind=email-retry id=%d template=%s attempts=%d err=%q\n",
+			e.ID, e.Template, e.Attempts, e.LastError)
+	}
+
+	if err = models.MarkEmailFailed(db, e); err != nil {
+		log.Database.Printf("Failed to update email %d: %s\n", e.ID, err)
+	}
+}
+
+// retryDelay returns how long to wait after n failed attempts.
+func retryDelay(n int) time.Duration {
+	if n < 1 {
+		return retryBase
+	}
+
+	d := retryBase << (n - 1)
+	if d <= 0 || d > retryMax {
+		return retryMax
+	}
+
+	return d
+}
+
+// permanent checks if an error won't go away by retrying, e.g. when a mail
+// server rejects an address.
+func permanent(err error) bool {
+	var e *smtp.SMTPError
+	if errors.As(err, &e) {
+		return e.Code >= 500
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package email
+
+import (
+	"errors"
+	"io"
+	stdlog "log"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"userstyles.world/models"
+	usererrors "userstyles.world/modules/errors"
+	"userstyles.world/modules/log"
+)
+
+func init() {
+	l := stdlog.New(io.Discard, "", 0)
+	log.Info, log.Warn, log.Database = l, l, l
+}
+
+// smtpStandIn is a local SMTP server that accepts or rejects recipients.
+type smtpStandIn struct {
+	l     net.Listener
+	reply string
+
+	mu   sync.Mutex
+	msgs []string
+}
+
+func newSMTPStandIn(t *testing.T, reply string) *smtpStandIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &smtpStandIn{l: l, reply: reply}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	tc := textproto.NewConn(conn)
+	_ = tc.PrintfLine("220 localhost")
+
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO":
+			_ = tc.PrintfLine("502 EH?")
+		case "RCPT":
+			_ = tc.PrintfLine(s.reply)
+		case "DATA":
+			_ = tc.PrintfLine("354 Go ahead")
+			b, err := tc.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.msgs = append(s.msgs, string(b))
+			s.mu.Unlock()
+			_ = tc.PrintfLine("250 Data ok")
+		case "QUIT":
+			_ = tc.PrintfLine("221 Goodbye")
+			return
+		default:
+			_ = tc.PrintfLine("250 Ok")
+		}
+	}
+}
+
+func (s *smtpStandIn) received() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.msgs)
+}
+
+func initOutbox(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&models.OutgoingEmail{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func queue(t *testing.T, db *gorm.DB) *models.OutgoingEmail {
+	e := &models.OutgoingEmail{
+		Template:  "test",
+		Recipient: "user@example.com",
+		Subject:   "Outbox test",
+		Text:      "Hello",
+		HTML:      "<p>Hello</p>",
+	}
+	if err := enqueue(db, e); err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func find(t *testing.T, db *gorm.DB, id uint) models.OutgoingEmail {
+	var e models.OutgoingEmail
+	if err := db.First(&e, id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestOutboxSent(t *testing.T) {
+	db := initOutbox(t)
+	srv := newSMTPStandIn(t, "250 Receiver ok")
+	e := queue(t, db)
+
+	processOutbox(db, srv.l.Addr().String(), time.Now())
+
+	got := find(t, db, e.ID)
+	if got.Status != models.EmailSent || got.Attempts != 1 || got.SentAt.IsZero() {
+		t.Errorf("unexpected email: %+v", got)
+	}
+	if got.Text != "" || got.HTML != "" {
+		t.Errorf("expected bodies of sent email to be cleared: %+v", got)
+	}
+	if n := srv.received(); n != 1 {
+		t.Errorf("got %d messages, expected 1", n)
+	}
+}
+
+func TestOutboxRetry(t *testing.T) {
+	db := initOutbox(t)
+	srv := newSMTPStandIn(t, "451 Try again later")
+	addr := srv.l.Addr().String()
+	e := queue(t, db)
+
+	now := time.Now()
+	processOutbox(db, addr, now)
+
+	got := find(t, db, e.ID)
+	if got.Status != models.EmailPending || got.Attempts != 1 || got.LastError == "" {
+		t.Fatalf("unexpected email: %+v", got)
+	}
+	if d := got.NextAttemptAt.Sub(now); d != retryBase {
+		t.Errorf("got %s delay, expected %s", d, retryBase)
+	}
+
+	// Emails aren't retried before their next attempt.
+	processOutbox(db, addr, now.Add(time.Second))
+	if got = find(t, db, e.ID); got.Attempts != 1 {
+		t.Errorf("got %d attempts, expected 1", got.Attempts)
+	}
+
+	for i := 1; i < maxAttempts; i++ {
+		now = now.Add(retryMax)
+		processOutbox(db, addr, now)
+	}
+
+	got = find(t, db, e.ID)
+	if got.Status != models.EmailFailed || got.Attempts != maxAttempts {
+		t.Errorf("unexpected email: %+v", got)
+	}
+
+	if err := models.RetryOutgoingEmail(db, e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got = find(t, db, e.ID); got.Status != models.EmailPending || got.Attempts != 0 {
+		t.Errorf("unexpected email: %+v", got)
+	}
+}
+
+func TestOutboxRejected(t *testing.T) {
+	db := initOutbox(t)
+	srv := newSMTPStandIn(t, "550 No such user")
+	e := queue(t, db)
+
+	processOutbox(db, srv.l.Addr().String(), time.Now())
+
+	got := find(t, db, e.ID)
+	if got.Status != models.EmailFailed || got.Attempts != 1 {
+		t.Errorf("unexpected email: %+v", got)
+	}
+}
+
+func TestOutboxUnsubscribe(t *testing.T) {
+	db := initOutbox(t)
+	srv := newSMTPStandIn(t, "250 Receiver ok")
+	e := queue(t, db)
+	db.Model(e).Update("unsubscribe", "https://example.com/unsubscribe/key")
+
+	processOutbox(db, srv.l.Addr().String(), time.Now())
+
+	if n := srv.received(); n != 1 {
+		t.Fatalf("got %d messages, expected 1", n)
+	}
+	for _, h := range []string{
+		"List-Unsubscribe: <https://example.com/unsubscribe/key>\n",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click\n",
+	} {
+		if !strings.Contains(srv.msgs[0], h) {
+			t.Errorf("message is missing %q", h)
+		}
+	}
+}
+
+func TestUnsubscribeInjection(t *testing.T) {
+	t.Parallel()
+
+	err := NewEmail().
+		SetTo("user@example.com").
+		SetSubject("Unsubscribe test").
+		SetUnsubscribe("https://example.com>\r\nBcc: other@example.com").
+		AddPart(*NewPart().SetBody("Hello")).
+		SendEmail("127.0.0.1:0")
+	if !errors.Is(err, usererrors.ErrInvalidHeader) {
+		t.Errorf("got %v, expected %v", err, usererrors.ErrInvalidHeader)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		attempts int
+		exp      time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{5, 16 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, retryMax},
+		{100, retryMax},
+	}
+
+	for _, c := range cases {
+		if got := retryDelay(c.attempts); got != c.exp {
+			t.Errorf("%d attempts: got %s, expected %s", c.attempts, got, c.exp)
+		}
+	}
+}
@@ -18,6 +18,17 @@ var (
 	// ErrNoServiceDetected errors that the the paramater service was not set.
 	ErrNoServiceDetected = errors.New("no service detected")
 
+	// ErrInvalidOAuthState errors that the sign-in attempt wasn't started by
+	// the user, or that it has expired.
+	ErrInvalidOAuthState = errors.New("sign-in attempt has expired, please try again")
+
+	// ErrOAuthEmailExists errors that an account with the same email has to be
+	// linked by its owner.
+	ErrOAuthEmailExists = errors.New("an account with this email already exists, sign in and link it from your account page")
+
+	// ErrInvalidIDToken errors that an OpenID Connect ID token couldn't be verified.
+	ErrInvalidIDToken = errors.New("invalid ID token")
+
 	// ErrNoSubject errors that the email builder didn't set the subject.
 	ErrNoSubject = errors.New("subject parameter is missing")
 
@@ -30,6 +41,12 @@ var (
 	// ErrNoPartBody errors that the email builder didn't specify the part's body.
 	ErrNoPartBody = errors.New("part doesn't contain body")
 
+	// ErrInvalidHeader errors that an email header contains invalid characters.
+	ErrInvalidHeader = errors.New("header contains invalid characters")
+
+	// ErrInvalidDKIMKey errors that a DKIM key isn't an RSA or Ed25519 key.
+	ErrInvalidDKIMKey = errors.New("DKIM key must be an RSA or Ed25519 private key")
+
 	// ErrMessageSmall errors the given string is too small.
 	ErrMessageSmall = errors.New("message too small")
 
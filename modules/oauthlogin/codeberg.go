@@ -27,14 +27,6 @@ func (codeberg) oauthMakeURL() string {
 	return oauthURL
 }
 
-func (codeberg) enableState() bool {
-	return false
-}
-
-func (codeberg) appendToRedirect(any) string {
-	return codebergStr + "/"
-}
-
 func (codeberg) getAuthTokenURL(any) string {
 	return "https://codeberg.org/login/oauth/access_token"
 }
@@ -49,7 +41,7 @@ func (codeberg) getAuthTokenPostBody(data any) authURLPostBody {
 		ClientSecret: config.CodebergClientSecret,
 		Code:         data.(string),
 		GrantType:    "authorization_code",
-		RedirectURI:  config.OAuthURL() + "codeberg/",
+		RedirectURI:  redirectURI(codebergStr),
 	}
 }
 
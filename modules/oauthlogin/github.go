@@ -23,16 +23,11 @@ func (github) oauthMakeURL() string {
 	return oauthURL
 }
 
-func (github) enableState() bool {
-	return true
-}
-
-func (github) appendToRedirect(state any) string {
-	// Trying to follow our stateless design we encrypt the
-	// Nonsense state so we later can re-use by decrypting it.
-	// And than have the actual value. Also we use this to specify
-	// From which site the callback was from.
-	return util.EncryptText(state.(string), util.AEADOAuth, config.ScrambleConfig) + "/"
+// redirectURI keeps GitHub's callback URL from before services were named in
+// callback URLs, where the encrypted state is used instead, so that settings
+// of the OAuth app don't have to change.
+func (github) redirectURI(state string) string {
+	return config.OAuthURL() + util.EncryptText(state, util.AEADOAuth, config.ScrambleConfig) + "/"
 }
 
 func (github) getAuthTokenURL(state any) string {
@@ -24,14 +24,6 @@ func (gitlab) oauthMakeURL() string {
 	return oauthURL
 }
 
-func (gitlab) enableState() bool {
-	return false
-}
-
-func (gitlab) appendToRedirect(any) string {
-	return gitlabStr + "/"
-}
-
 func (gitlab) getAuthTokenURL(any) string {
 	authURL := "https://gitlab.com/oauth/token"
 	authURL += "?client_id=" + config.GitlabClientID
@@ -39,7 +31,7 @@ func (gitlab) getAuthTokenURL(any) string {
 	// Define we log in trough the temp code.
 	authURL += "&grant_type=authorization_code"
 	// Specify the the redirect uri, because it is required
-	authURL += "&redirect_uri=" + url.PathEscape(config.OAuthURL()+gitlabStr+"/")
+	authURL += "&redirect_uri=" + url.QueryEscape(redirectURI(gitlabStr))
 
 	return authURL
 }
@@ -3,10 +3,12 @@ package oauthlogin
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"userstyles.world/modules/config"
 	"userstyles.world/modules/errors"
+	"userstyles.world/modules/log"
 	"userstyles.world/modules/util"
 )
 
@@ -43,6 +45,12 @@ type OAuthResponse struct {
 	Email       string
 	Username    string
 	RawData     string
+
+	// LinkUserID is set when a signed-in user links an account.
+	LinkUserID uint
+
+	// profileURL is set by providers that include it in their claims.
+	profileURL string
 }
 
 func (o *OAuthResponse) normalize(username string) {
@@ -60,7 +68,7 @@ func (o *OAuthResponse) ProfileURL() string {
 	case CodebergService:
 		return "https://codeberg.org/" + o.Username
 	default:
-		return ""
+		return o.profileURL
 	}
 }
 
@@ -87,12 +95,6 @@ type ProviderFunctions interface {
 	// Get the provider specific URL to be logged in with.
 	oauthMakeURL() string
 
-	// Let's the implementation append to the redirect URL.
-	appendToRedirect(data any) string
-
-	// See if the current implementation allows state.
-	enableState() bool
-
 	// Get the providers specic URL to get the auth token.
 	getAuthTokenURL(data any) string
 
@@ -133,27 +135,111 @@ func GetInterfaceForService(service string) (ProviderFunctions, error) {
 	return nil, errors.ErrNoServiceDetected
 }
 
-func OauthMakeURL(serviceType string) string {
-	service, err := GetInterfaceForService(serviceType)
-	if err != nil {
-		return ""
+// Provider is a service that users can sign in with.
+type Provider struct {
+	Name  string
+	Title string
+}
+
+var builtinProviders = []Provider{
+	{Name: string(GithubService), Title: "GitHub"},
+	{Name: string(GitlabService), Title: "GitLab"},
+	{Name: string(CodebergService), Title: "Codeberg"},
+}
+
+// Providers returns all services that users can sign in with.
+func Providers() []Provider {
+	list := append([]Provider{}, builtinProviders...)
+	for _, p := range oidcProviders {
+		list = append(list, Provider{Name: p.Name, Title: p.Title})
 	}
+	return list
+}
+
+// Title returns the display name of a service.
+func Title(serviceType string) string {
+	for _, p := range Providers() {
+		if p.Name == serviceType {
+			return p.Title
+		}
+	}
+	return serviceType
+}
 
-	oauthURL := service.oauthMakeURL()
-	var state string
-	if service.enableState() {
-		state = util.RandomString(16)
+// redirectURI returns the callback URL of a service.
+func redirectURI(serviceType string) string {
+	return config.OAuthURL() + serviceType + "/"
+}
+
+// OauthMakeURL returns the URL to sign in with a service, along with the state
+// that has to be matched in the callback.  Accounts are linked to the user with
+// userID if it isn't zero.
+func OauthMakeURL(serviceType string, userID uint) (string, string) {
+	state, nonce := util.RandomString(32), util.RandomString(32)
+
+	var oauthURL string
+	if p := findOIDCProvider(serviceType); p != nil {
+		u, err := p.authURL(state, nonce)
+		if err != nil {
+			log.Warn.Printf("Failed to discover %s: %s\n", p.Name, err)
+			return "", ""
+		}
+		oauthURL = u
+	} else {
+		service, err := GetInterfaceForService(serviceType)
+		if err != nil {
+			return "", ""
+		}
+		uri := redirectURI(serviceType)
+		if Service(serviceType) == GithubService {
+			uri = githubFunc.redirectURI(state)
+		}
+
+		oauthURL = service.oauthMakeURL()
 		oauthURL += "&state=" + state
+		oauthURL += "&redirect_uri=" + url.QueryEscape(uri)
 	}
-	if oauthURL == "" {
-		return ""
+
+	saveState(state, loginState{Service: serviceType, Nonce: nonce, UserID: userID})
+
+	return oauthURL, state
+}
+
+// CallbackService returns the service that a callback URL segment belongs to.
+// GitHub's segment is the encrypted state, as described in its redirectURI.
+func CallbackService(segment, state string) string {
+	if _, err := GetInterfaceForService(segment); err == nil || findOIDCProvider(segment) != nil {
+		return segment
+	}
+
+	s, err := util.DecryptText(segment, util.AEADOAuth, config.ScrambleConfig)
+	if err == nil && state != "" && s == state {
+		return string(GithubService)
 	}
 
-	oauthURL += "&redirect_uri=" + config.OAuthURL() + service.appendToRedirect(state)
-	return oauthURL
+	return segment
 }
 
 func CallbackOAuth(tempCode, state, serviceType string) (OAuthResponse, error) {
+	s, ok := takeState(state, serviceType)
+	if !ok {
+		return OAuthResponse{}, errors.ErrInvalidOAuthState
+	}
+
+	res, err := callback(tempCode, state, serviceType, s.Nonce)
+	if err != nil {
+		return OAuthResponse{}, err
+	}
+	res.LinkUserID = s.UserID
+
+	return res, nil
+}
+
+func callback(tempCode, state, serviceType, nonce string) (OAuthResponse, error) {
+	if p := findOIDCProvider(serviceType); p != nil {
+		return p.callback(tempCode, nonce)
+	}
+
 	service, err := GetInterfaceForService(serviceType)
 	if err != nil {
 		return OAuthResponse{}, err
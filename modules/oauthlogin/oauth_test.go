@@ -0,0 +1,36 @@
+package oauthlogin
+
+import (
+	"strings"
+	"testing"
+
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/util"
+)
+
+func TestCallbackService(t *testing.T) {
+	util.InitCrypto()
+
+	segment := strings.TrimSuffix(strings.TrimPrefix(githubFunc.redirectURI("state"), config.OAuthURL()), "/")
+
+	cases := []struct {
+		name     string
+		segment  string
+		state    string
+		expected string
+	}{
+		{"builtin", "gitlab", "state", "gitlab"},
+		{"github", segment, "state", "github"},
+		{"github wrong state", segment, "other", segment},
+		{"github no state", segment, "", segment},
+		{"unknown", "unknown", "state", "unknown"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := CallbackService(c.segment, c.state); got != c.expected {
+				t.Errorf("got: %q, expected: %q", got, c.expected)
+			}
+		})
+	}
+}
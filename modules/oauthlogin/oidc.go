@@ -0,0 +1,491 @@
+package oauthlogin
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/errors"
+	"userstyles.world/modules/log"
+	"userstyles.world/modules/util"
+)
+
+const (
+	// discoveryTTL limits how long discovery documents and keys are cached.
+	discoveryTTL = 24 * time.Hour
+
+	// keysRefetch limits how often keys are fetched for unknown key IDs.
+	keysRefetch = time.Minute
+
+	// clockSkew is tolerated when checking expiration of ID tokens.
+	clockSkew = time.Minute
+)
+
+var (
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+
+	providerNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+	// oidcProviders holds OpenID Connect providers in order of configuration.
+	oidcProviders []*oidcProvider
+)
+
+// oidcProvider is an OpenID Connect provider configured by the instance, like
+// self-hosted Gitea, Forgejo or Keycloak.
+type oidcProvider struct {
+	Name         string   `json:"name"`
+	Title        string   `json:"title"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+
+	mu          sync.Mutex
+	meta        *oidcMetadata
+	metaFetched time.Time
+	keys        map[string]any
+	keysFetched time.Time
+}
+
+// oidcMetadata holds parts of a discovery document that are used to sign in.
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type oidcMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+}
+
+// oidcClaims holds standard claims about users.
+// https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+type oidcClaims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     any    `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Nickname          string `json:"nickname"`
+	Profile           string `json:"profile"`
+	Nonce             string `json:"nonce"`
+}
+
+// emailVerified reports whether the provider verified the email address.
+// Some providers send the claim as a string.
+func (c oidcClaims) emailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+// username returns the most suitable username of a user.
+func (c oidcClaims) username() string {
+	switch {
+	case c.PreferredUsername != "":
+		return c.PreferredUsername
+	case c.Nickname != "":
+		return c.Nickname
+	default:
+		name, _, _ := strings.Cut(c.Email, "@")
+		return name
+	}
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Initialize loads OpenID Connect providers from configuration.
+func Initialize() {
+	list, err := parseProviders(config.OIDCProviders)
+	if err != nil {
+		log.Warn.Fatalf("Failed to parse OIDC_PROVIDERS: %s\n", err)
+	}
+	oidcProviders = list
+
+	for _, p := range list {
+		log.Info.Printf("Enabled sign in with %s (%s).\n", p.Title, p.Issuer)
+	}
+}
+
+// parseProviders reads a JSON list of OpenID Connect providers.
+func parseProviders(s string) ([]*oidcProvider, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var list []*oidcProvider
+	if err := json.Unmarshal([]byte(s), &list); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range list {
+		switch {
+		case !providerNameRe.MatchString(p.Name):
+			return nil, fmt.Errorf("invalid name %q", p.Name)
+		case seen[p.Name]:
+			return nil, fmt.Errorf("duplicate name %q", p.Name)
+		case p.Issuer == "" || p.ClientID == "":
+			return nil, fmt.Errorf("%s: issuer and client_id are required", p.Name)
+		}
+		if _, err := GetInterfaceForService(p.Name); err == nil {
+			return nil, fmt.Errorf("%s: name is used by a built-in provider", p.Name)
+		}
+		seen[p.Name] = true
+
+		if p.Title == "" {
+			p.Title = p.Name
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		} else if !util.ContainsString(p.Scopes, "openid") {
+			p.Scopes = append([]string{"openid"}, p.Scopes...)
+		}
+	}
+
+	return list, nil
+}
+
+func findOIDCProvider(name string) *oidcProvider {
+	for _, p := range oidcProviders {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// getJSON sends a request and decodes its JSON response.
+func getJSON(req *http.Request, v any) error {
+	req.Header.Set("Accept", "application/json")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return errors.ErrNot200Ok
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// metadata returns the discovery document of a provider.
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfig
+func (p *oidcProvider) metadata() (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil && time.Since(p.metaFetched) < discoveryTTL {
+		return p.meta, nil
+	}
+
+	issuer := strings.TrimSuffix(p.Issuer, "/")
+	req, err := http.NewRequest("GET", issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var m oidcMetadata
+	if err = getJSON(req, &m); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(m.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("issuer %q doesn't match %q", m.Issuer, p.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, fmt.Errorf("incomplete discovery document of %s", issuer)
+	}
+
+	p.meta, p.metaFetched = &m, time.Now()
+
+	return p.meta, nil
+}
+
+// key returns a public key that is used to verify ID tokens.  Keys are fetched
+// again when the provider starts to use a new key.
+func (p *oidcProvider) key(m *oidcMetadata, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.keys[kid]; ok && time.Since(p.keysFetched) < discoveryTTL {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < keysRefetch {
+		return nil, fmt.Errorf("%w: unknown key %q", errors.ErrInvalidIDToken, kid)
+	}
+
+	req, err := http.NewRequest("GET", m.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = getJSON(req, &set); err != nil {
+		return nil, err
+	}
+
+	p.keys = make(map[string]any, len(set.Keys))
+	for _, v := range set.Keys {
+		if v.Use != "" && v.Use != "sig" {
+			continue
+		}
+		k, err := parseJWK(v)
+		if err != nil {
+			log.Warn.Printf("Failed to parse key %q of %s: %s\n", v.Kid, p.Name, err)
+			continue
+		}
+		p.keys[v.Kid] = k
+	}
+	p.keysFetched = time.Now()
+
+	k, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", errors.ErrInvalidIDToken, kid)
+	}
+
+	return k, nil
+}
+
+// parseJWK returns a public key described in RFC 7518.
+// https://www.rfc-editor.org/rfc/rfc7518#section-6
+func parseJWK(k jsonWebKey) (any, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// authURL returns the URL of an authentication request.
+// https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (p *oidcProvider) authURL(state, nonce string) (string, error) {
+	m, err := p.metadata()
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", redirectURI(p.Name))
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+
+	sep := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return m.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// callback exchanges a code for tokens, and returns the user that signed in.
+// https://openid.net/specs/openid-connect-core-1_0.html#TokenRequest
+func (p *oidcProvider) callback(code, nonce string) (OAuthResponse, error) {
+	m, err := p.metadata()
+	if err != nil {
+		return OAuthResponse{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI(p.Name))
+
+	// Basic authentication is the default, but some providers only allow
+	// credentials in the body.
+	basic := len(m.TokenAuthMethods) == 0 ||
+		util.ContainsString(m.TokenAuthMethods, "client_secret_basic")
+	if !basic {
+		form.Set("client_id", p.ClientID)
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequest("POST", m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OAuthResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	var t oidcTokenResponse
+	if err = getJSON(req, &t); err != nil {
+		return OAuthResponse{}, err
+	}
+
+	claims, err := p.verifyIDToken(m, t.IDToken, nonce)
+	if err != nil {
+		return OAuthResponse{}, err
+	}
+
+	// ID tokens don't have to include claims that were requested with scopes.
+	if claims.Email == "" && m.UserinfoEndpoint != "" {
+		claims, err = p.userinfo(m, t, claims)
+		if err != nil {
+			return OAuthResponse{}, err
+		}
+	}
+
+	if claims.Email == "" || !claims.emailVerified() {
+		return OAuthResponse{}, errors.ErrPrimaryEmailNotVerified
+	}
+
+	return OAuthResponse{
+		Provider:    Service(p.Name),
+		ExternalID:  claims.Subject,
+		AccessToken: t.AccessToken,
+		Email:       claims.Email,
+		Username:    claims.username(),
+		profileURL:  claims.Profile,
+	}, nil
+}
+
+// verifyIDToken checks signature and claims of an ID token.
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (p *oidcProvider) verifyIDToken(m *oidcMetadata, s, nonce string) (oidcClaims, error) {
+	if s == "" {
+		return oidcClaims{}, fmt.Errorf("%w: missing id_token", errors.ErrInvalidIDToken)
+	}
+
+	parser := jwt.Parser{
+		ValidMethods:         []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"},
+		SkipClaimsValidation: true,
+	}
+	token, err := parser.Parse(s, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(m, kid)
+	})
+	if err != nil || !token.Valid {
+		return oidcClaims{}, fmt.Errorf("%w: %v", errors.ErrInvalidIDToken, err)
+	}
+
+	mc := token.Claims.(jwt.MapClaims)
+	switch {
+	case !mc.VerifyIssuer(m.Issuer, true):
+		return oidcClaims{}, fmt.Errorf("%w: wrong issuer", errors.ErrInvalidIDToken)
+	case !mc.VerifyAudience(p.ClientID, true):
+		return oidcClaims{}, fmt.Errorf("%w: wrong audience", errors.ErrInvalidIDToken)
+	case !mc.VerifyExpiresAt(time.Now().Add(-clockSkew).Unix(), true):
+		return oidcClaims{}, fmt.Errorf("%w: expired", errors.ErrInvalidIDToken)
+	}
+
+	b, err := json.Marshal(mc)
+	if err != nil {
+		return oidcClaims{}, err
+	}
+	var c oidcClaims
+	if err = json.Unmarshal(b, &c); err != nil {
+		return oidcClaims{}, err
+	}
+
+	if c.Subject == "" {
+		return oidcClaims{}, fmt.Errorf("%w: missing subject", errors.ErrInvalidIDToken)
+	}
+	if c.Nonce != nonce {
+		return oidcClaims{}, fmt.Errorf("%w: nonce doesn't match", errors.ErrInvalidIDToken)
+	}
+
+	return c, nil
+}
+
+// userinfo returns claims about a user from the UserInfo endpoint.
+// https://openid.net/specs/openid-connect-core-1_0.html#UserInfo
+func (p *oidcProvider) userinfo(m *oidcMetadata, t oidcTokenResponse, idClaims oidcClaims) (oidcClaims, error) {
+	req, err := http.NewRequest("GET", m.UserinfoEndpoint, nil)
+	if err != nil {
+		return oidcClaims{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+t.AccessToken)
+
+	var c oidcClaims
+	if err = getJSON(req, &c); err != nil {
+		return oidcClaims{}, err
+	}
+
+	// Responses may belong to another user if the token was substituted.
+	if c.Subject != idClaims.Subject {
+		return oidcClaims{}, fmt.Errorf("%w: userinfo subject doesn't match", errors.ErrInvalidIDToken)
+	}
+	c.Nonce = idClaims.Nonce
+
+	return c, nil
+}
@@ -0,0 +1,168 @@
+package oauthlogin
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	usw "userstyles.world/modules/errors"
+)
+
+func TestParseProviders(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		desc  string
+		input string
+		fails bool
+	}{
+		{"empty", "", false},
+		{"valid", `[{"name":"sso","issuer":"https://sso.example","client_id":"a"}]`, false},
+		{"invalid name", `[{"name":"S S O","issuer":"https://sso.example","client_id":"a"}]`, true},
+		{"built-in name", `[{"name":"github","issuer":"https://sso.example","client_id":"a"}]`, true},
+		{"missing issuer", `[{"name":"sso","client_id":"a"}]`, true},
+		{"duplicate", `[{"name":"sso","issuer":"https://a","client_id":"a"},{"name":"sso","issuer":"https://b","client_id":"b"}]`, true},
+	}
+
+	for _, c := range cases {
+		_, err := parseProviders(c.input)
+		if (err != nil) != c.fails {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+	}
+
+	list, _ := parseProviders(`[{"name":"sso","issuer":"https://sso.example","client_id":"a","scopes":["email"]}]`)
+	if list[0].Title != "sso" {
+		t.Errorf("got: %q, expected: %q", list[0].Title, "sso")
+	}
+	if len(list[0].Scopes) != 2 || list[0].Scopes[0] != "openid" {
+		t.Errorf("expected openid scope, got: %v", list[0].Scopes)
+	}
+}
+
+// fakeProvider serves discovery, token and key endpoints of a provider that
+// issues ID tokens with given claims.
+func fakeProvider(t *testing.T, claims func(issuer string) jwt.MapClaims) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oidcMetadata{
+			Issuer:                srv.URL,
+			AuthorizationEndpoint: srv.URL + "/authorize",
+			TokenEndpoint:         srv.URL + "/token",
+			JWKSURI:               srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []jsonWebKey{{
+			Kty: "RSA",
+			Kid: "k1",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("code") != "code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims(srv.URL))
+		token.Header["kid"] = "k1"
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		_ = json.NewEncoder(w).Encode(oidcTokenResponse{
+			AccessToken: "access",
+			TokenType:   "Bearer",
+			IDToken:     s,
+		})
+	})
+
+	return srv
+}
+
+func TestOIDCCallback(t *testing.T) {
+	t.Parallel()
+
+	base := func(issuer string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                issuer,
+			"aud":                []string{"client"},
+			"sub":                "42",
+			"exp":                time.Now().Add(time.Minute).Unix(),
+			"nonce":              "nonce",
+			"email":              "user@example.com",
+			"email_verified":     true,
+			"preferred_username": "user",
+		}
+	}
+
+	cases := []struct {
+		desc   string
+		modify func(c jwt.MapClaims)
+		err    error
+	}{
+		{"valid", func(jwt.MapClaims) {}, nil},
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "other" }, usw.ErrInvalidIDToken},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "other" }, usw.ErrInvalidIDToken},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, usw.ErrInvalidIDToken},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, usw.ErrInvalidIDToken},
+		{"unverified email", func(c jwt.MapClaims) { c["email_verified"] = false }, usw.ErrPrimaryEmailNotVerified},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.desc, func(t *testing.T) {
+			t.Parallel()
+
+			srv := fakeProvider(t, func(issuer string) jwt.MapClaims {
+				claims := base(issuer)
+				c.modify(claims)
+				return claims
+			})
+			p := &oidcProvider{
+				Name:         "sso",
+				Issuer:       srv.URL + "/",
+				ClientID:     "client",
+				ClientSecret: "secret",
+			}
+
+			res, err := p.callback("code", "nonce")
+			if !errors.Is(err, c.err) {
+				t.Fatalf("got: %v, expected: %v", err, c.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if res.ExternalID != "42" || res.Username != "user" || res.Email != "user@example.com" {
+				t.Errorf("unexpected response: %+v", res)
+			}
+			if res.Provider != "sso" {
+				t.Errorf("got: %q, expected: %q", res.Provider, "sso")
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package oauthlogin
+
+import (
+	"strings"
+	"time"
+
+	"userstyles.world/modules/cache"
+)
+
+// StateCookie binds sign-in attempts to browsers that started them.
+const StateCookie = "oauth_state"
+
+// StateTimeout limits how long users have to sign in with a service.
+const StateTimeout = 10 * time.Minute
+
+type loginState struct {
+	Service string
+	Nonce   string
+
+	// UserID is set when a signed-in user links an account.
+	UserID uint
+}
+
+func saveState(state string, s loginState) {
+	// Service can point to memory of a request, which is reused afterwards.
+	s.Service = strings.Clone(s.Service)
+	cache.Store.Set("oauth-state "+state, s, StateTimeout)
+}
+
+// takeState returns a sign-in attempt, which can be used only once.
+func takeState(state, service string) (loginState, bool) {
+	if state == "" {
+		return loginState{}, false
+	}
+
+	k := "oauth-state " + state
+	v, ok := cache.Store.Get(k)
+	if !ok {
+		return loginState{}, false
+	}
+	cache.Store.Delete(k)
+
+	s, ok := v.(loginState)
+	if !ok || s.Service != service {
+		return loginState{}, false
+	}
+
+	return s, true
+}
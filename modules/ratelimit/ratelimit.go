@@ -0,0 +1,205 @@
+// Package ratelimit limits how often clients can make requests.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"userstyles.world/modules/config"
+	"userstyles.world/modules/log"
+)
+
+// defaults is a list of policies used unless overridden by RATE_LIMITS.
+const defaults = "login=10/15m,register=5/1h,recover=5/1h,token=60/1m,review=10/1h,code=300/1m," +
+	"views=60/1m,installs=300/1h"
+
+var requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "usw_ratelimit_requests_total",
+	Help: "Total amount of rate-limited requests, by policy and result.",
+}, []string{"policy", "result"})
+
+func init() {
+	prometheus.MustRegister(requests)
+}
+
+var (
+	mu       sync.RWMutex
+	limiters = make(map[string]*Limiter)
+)
+
+// Policy describes how many requests can be made in a period of time.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+// String returns a policy in a format used by RateLimit-Policy header.
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(p.Period.Seconds()))
+}
+
+// Result describes the outcome of a request.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Reset is time until the bucket is full again.
+	Reset time.Duration
+
+	// RetryAfter is time until the next request is allowed.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is a set of token buckets that share a policy.
+type Limiter struct {
+	Policy
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewLimiter returns a limiter for a policy.
+func NewLimiter(p Policy) *Limiter {
+	return &Limiter{Policy: p, buckets: make(map[string]*bucket)}
+}
+
+// rate returns how many tokens are added to a bucket per second.
+func (l *Limiter) rate() float64 {
+	return float64(l.Limit) / l.Period.Seconds()
+}
+
+// Allow takes a token from the bucket identified by key.
+func (l *Limiter) Allow(key string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	rate := l.rate()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Limit), last: now}
+		l.buckets[key] = b
+	}
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(l.Limit), b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	r := Result{Limit: l.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	r.Remaining = int(b.tokens)
+	r.Reset = seconds((float64(l.Limit) - b.tokens) / rate)
+
+	if r.Allowed {
+		requests.WithLabelValues(l.Name, "allowed").Inc()
+	} else {
+		requests.WithLabelValues(l.Name, "limited").Inc()
+	}
+
+	return r
+}
+
+// sweep removes buckets that have been refilled, as they are
+// indistinguishable from new ones.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.Period {
+		return
+	}
+	l.swept = now
+
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= l.Period {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+func seconds(f float64) time.Duration {
+	return time.Duration(math.Ceil(f)) * time.Second
+}
+
+// Get returns a limiter for a policy, or nil if the policy is disabled.
+func Get(name string) *Limiter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return limiters[name]
+}
+
+// Initialize sets up limiters from default policies and RATE_LIMITS.
+func Initialize() {
+	list, err := parsePolicies(defaults + "," + config.RateLimits)
+	if err != nil {
+		log.Warn.Fatalf("Failed to parse RATE_LIMITS: %s\n", err)
+	}
+
+	m := make(map[string]*Limiter, len(list))
+	for _, p := range list {
+		if p.Limit == 0 {
+			delete(m, p.Name)
+			continue
+		}
+		m[p.Name] = NewLimiter(p)
+	}
+
+	mu.Lock()
+	limiters = m
+	mu.Unlock()
+}
+
+// parsePolicies reads a comma-separated list of policies in name=limit/period
+// format.  Later policies override earlier ones with the same name, and limit
+// of zero disables a policy.
+func parsePolicies(s string) ([]Policy, error) {
+	var list []Policy
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		name, rule, ok := strings.Cut(f, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid policy %q", f)
+		}
+
+		limit, period, ok := strings.Cut(rule, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid policy %q", f)
+		}
+
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit in %q", f)
+		}
+
+		d, err := time.ParseDuration(period)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid period in %q", f)
+		}
+
+		list = append(list, Policy{Name: name, Limit: n, Period: d})
+	}
+
+	return list, nil
+}
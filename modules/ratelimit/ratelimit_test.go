@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	t.Parallel()
+
+	l := NewLimiter(Policy{Name: "test", Limit: 3, Period: 30 * time.Second})
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		r := l.Allow("a", now)
+		if !r.Allowed {
+			t.Fatalf("request %d was limited", i)
+		}
+		if r.Remaining != 2-i {
+			t.Errorf("got: %d, expected: %d", r.Remaining, 2-i)
+		}
+	}
+
+	r := l.Allow("a", now)
+	if r.Allowed {
+		t.Fatal("expected request to be limited")
+	}
+	if r.RetryAfter != 10*time.Second {
+		t.Errorf("got: %s, expected: %s", r.RetryAfter, 10*time.Second)
+	}
+	if r.Reset != 30*time.Second {
+		t.Errorf("got: %s, expected: %s", r.Reset, 30*time.Second)
+	}
+
+	if r := l.Allow("b", now); !r.Allowed {
+		t.Error("expected other keys to be allowed")
+	}
+
+	if r := l.Allow("a", now.Add(10*time.Second)); !r.Allowed {
+		t.Error("expected a token to be refilled")
+	}
+	if r := l.Allow("a", now.Add(10*time.Second)); r.Allowed {
+		t.Error("expected only one token to be refilled")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	t.Parallel()
+
+	l := NewLimiter(Policy{Name: "test", Limit: 1, Period: time.Minute})
+	now := time.Now()
+	l.Allow("a", now)
+	l.Allow("b", now.Add(time.Minute))
+
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("expected refilled bucket to be removed")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("expected active bucket to be kept")
+	}
+}
+
+func TestParsePolicies(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		desc  string
+		input string
+		fails bool
+	}{
+		{"empty", "", false},
+		{"valid", "login=10/15m, token=60/1m", false},
+		{"disabled", "login=0/1m", false},
+		{"missing period", "login=10", true},
+		{"missing name", "=10/1m", true},
+		{"negative limit", "login=-1/1m", true},
+		{"short period", "login=10/1ms", true},
+	}
+
+	for _, c := range cases {
+		_, err := parsePolicies(c.input)
+		if (err != nil) != c.fails {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+	}
+
+	list, _ := parsePolicies(defaults)
+	if len(list) != 8 || list[0].Name != "login" || list[0].Period != 15*time.Minute {
+		t.Errorf("unexpected defaults: %+v", list)
+	}
+}
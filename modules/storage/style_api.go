@@ -11,9 +11,9 @@ import (
 )
 
 const (
-	selectTotalInstalls  = "(SELECT total_installs FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS TotalInstalls"
-	selectWeeklyInstalls = "(SELECT weekly_installs FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS WeeklyInstalls"
-	selectUSoRatings     = "(SELECT ROUND(AVG(rating)*0.6, 1) FROM reviews r WHERE r.style_id = styles.id AND rating > 0 AND r.deleted_at IS NULL) AS Rating"
+	selectTotalInstalls  = "ss.total_installs AS TotalInstalls"
+	selectWeeklyInstalls = "ss.weekly_installs AS WeeklyInstalls"
+	selectUSoRatings     = "ROUND(ss.rating*0.6, 1) AS Rating"
 )
 
 var (
@@ -54,6 +54,7 @@ func GetStyleCompactIndex(db *gorm.DB) ([]byte, error) {
 	styles := make([]StyleCompact, size)
 	err = db.
 		Select(selectCompactIndex).
+		Joins(joinSummary).
 		Where(notDeleted).
 		Find(&styles).Error
 	if err != nil {
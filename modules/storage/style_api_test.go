@@ -18,7 +18,7 @@ func initDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	t := []any{models.Style{}, models.Stats{}, models.User{}, models.Review{}, models.History{}}
+	t := []any{models.Style{}, models.Stats{}, models.User{}, models.Review{}, models.History{}, models.Notification{}, models.StyleSummary{}}
 	if err = db.AutoMigrate(t...); err != nil {
 		return nil, err
 	}
@@ -70,6 +70,48 @@ func TestGetStyleCompactIndex(t *testing.T) {
 	}
 }
 
+// seedStyles adds styles with a month of history and a few reviews each.
+func seedStyles(db *gorm.DB, size int) error {
+	var s []models.Style
+	for i := 1; i <= size; i++ {
+		id := strconv.Itoa(i)
+		s = append(s, models.Style{
+			Model: gorm.Model{
+				UpdatedAt: time.Date(1970, 1, 1, 1, 0, 0, 0, time.UTC),
+			},
+			Name:    "test " + id,
+			Preview: config.BaseURL + "/preview/" + id + "/0.webp",
+		})
+	}
+	if err := db.CreateInBatches(s, 100).Error; err != nil {
+		return err
+	}
+
+	var h []models.History
+	var r []models.Review
+	for i := 1; i <= size; i++ {
+		for day := int64(1); day <= 30; day++ {
+			h = append(h, models.History{
+				StyleID:        uint(i),
+				WeeklyInstalls: day,
+				TotalInstalls:  day * 10,
+				TotalViews:     day * 100,
+			})
+		}
+		for j := 1; j <= 3; j++ {
+			r = append(r, models.Review{StyleID: uint(i), UserID: uint(j), Rating: j + 2})
+		}
+	}
+	if err := db.CreateInBatches(h, 500).Error; err != nil {
+		return err
+	}
+	if err := db.CreateInBatches(r, 500).Error; err != nil {
+		return err
+	}
+
+	return models.InitStyleSummaries(db)
+}
+
 func BenchmarkGetStyleCompactIndex(b *testing.B) {
 	cases := []struct {
 		name string
@@ -88,21 +130,10 @@ func BenchmarkGetStyleCompactIndex(b *testing.B) {
 				b.Fatal(err)
 			}
 
-			var s []models.Style
-			for i := 1; i <= c.size; i++ {
-				id := strconv.Itoa(i)
-				s = append(s, models.Style{
-					Model: gorm.Model{
-						UpdatedAt: time.Date(1970, 1, 1, 1, 0, 0, 0, time.UTC),
-					},
-					Name:    "test " + id,
-					Preview: config.BaseURL + "/preview/" + id + "/0.webp",
-				})
-			}
-
-			if err = db.CreateInBatches(s, 100).Error; err != nil {
+			if err = seedStyles(db, c.size); err != nil {
 				b.Fatal(err)
 			}
+			b.ResetTimer()
 
 			for i := 0; i < b.N; i++ {
 				GetStyleCompactIndex(db)
@@ -12,20 +12,21 @@ import (
 
 const (
 	selectAuthor      = "(SELECT username FROM users WHERE user_id = users.id AND deleted_at IS NULL) AS Username"
-	selectInstalls    = "(SELECT total_installs FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS installs"
-	selectViews       = "(SELECT total_views FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS views"
-	selectRatings     = "(SELECT ROUND(AVG(rating), 1) FROM reviews r WHERE r.style_id = styles.id AND r.rating > 0 AND r.deleted_at IS NULL) AS Rating"
-	selectReviewCount = "(SELECT COUNT(rating) FROM reviews r WHERE r.style_id = styles.id AND r.rating > 0 AND r.deleted_at IS NULL) AS ReviewCount"
+	selectInstalls    = "ss.total_installs AS installs"
+	selectViews       = "ss.total_views AS views"
+	selectRatings     = "ROUND(ss.rating, 1) AS Rating"
+	selectReviewCount = "ss.review_count AS ReviewCount"
+	joinSummary       = "LEFT JOIN style_summaries ss ON ss.style_id = styles.id"
 	notDeleted        = "deleted_at IS NULL"
 )
 
 var (
 	selectCards = strings.Join([]string{
-		"id", "updated_at", "name", "preview",
+		"styles.id", "styles.updated_at", "name", "preview",
 		selectAuthor, selectInstalls, selectViews, selectRatings, selectReviewCount,
 	}, ", ")
 	selectSearchCards = strings.Join([]string{
-		"id", "created_at", "updated_at", "name", "preview",
+		"styles.id", "styles.created_at", "styles.updated_at", "name", "preview",
 		selectAuthor, selectInstalls, selectViews, selectRatings, selectReviewCount,
 	}, ", ")
 )
@@ -61,7 +62,7 @@ func (x StyleCard) StyleURL() string {
 func FindStyleCardsForSearch(items []int, kind string, size int) ([]StyleCard, error) {
 	var b strings.Builder
 	b.WriteString("SELECT " + selectSearchCards + " ")
-	b.WriteString("FROM styles WHERE id in (")
+	b.WriteString("FROM styles " + joinSummary + " WHERE id in (")
 	for i, item := range items {
 		if i == 0 {
 			b.WriteString(strconv.Itoa(item))
@@ -124,7 +125,7 @@ func FindStyleCardsForUsername(username string) ([]StyleCard, error) {
 	var res []StyleCard
 
 	err := database.Conn.
-		Select(selectCards).
+		Select(selectCards).Joins(joinSummary).
 		Find(&res, "deleted_at IS NULL AND username = ?", username).Error
 	if err != nil {
 		return nil, err
@@ -138,7 +139,7 @@ func FindStyleCardsFeatured() ([]StyleCard, error) {
 	var res []StyleCard
 
 	err := database.Conn.
-		Select(selectCards).
+		Select(selectCards).Joins(joinSummary).
 		Find(&res, "deleted_at IS NULL AND featured = 1").Error
 	if err != nil {
 		return nil, err
@@ -147,38 +148,29 @@ func FindStyleCardsFeatured() ([]StyleCard, error) {
 	return res, nil
 }
 
-// FindStyleCardsPaginated returns style cards for paginated pages.
-func FindStyleCardsPaginated(page, size int, order string) ([]StyleCard, error) {
+// FindStyleCardsTrending returns style cards of styles that are trending.
+func FindStyleCardsTrending(size int) ([]StyleCard, error) {
 	var res []StyleCard
-	offset := (page - 1) * size
 
-	var stmt string
-	switch {
-	case strings.HasPrefix(order, "styles"):
-		stmt = "id"
-	case strings.HasPrefix(order, "views"):
-		stmt = "id, (SELECT total_views FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS views"
-	case strings.HasPrefix(order, "installs"):
-		stmt = "id, (SELECT total_installs FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS installs"
-	case strings.HasPrefix(order, "rating"):
-		stmt = "id, (SELECT ROUND(AVG(rating), 1) FROM reviews r WHERE r.style_id = styles.id AND r.deleted_at IS NULL) AS rating"
-	}
-
-	var nums []struct{ ID int }
 	err := database.Conn.
-		Select(stmt).Table("styles").
-		Order(order).Offset(offset).Limit(size).Find(&nums, notDeleted).Error
+		Select(selectCards).Joins(joinSummary).
+		Order("ss.trending DESC").Limit(size).
+		Find(&res, notDeleted+" AND ss.trending > 0").Error
 	if err != nil {
 		return nil, err
 	}
 
-	items := make([]int, 0, len(nums))
-	for _, num := range nums {
-		items = append(items, num.ID)
-	}
+	return res, nil
+}
+
+// FindStyleCardsPaginated returns style cards for paginated pages.
+func FindStyleCardsPaginated(page, size int, order string) ([]StyleCard, error) {
+	var res []StyleCard
+	offset := (page - 1) * size
 
-	err = database.Conn.
-		Select(selectCards).Order(order).Find(&res, items).Error
+	err := database.Conn.
+		Select(selectCards).Joins(joinSummary).
+		Order(order).Offset(offset).Limit(size).Find(&res, notDeleted).Error
 	if err != nil {
 		return nil, err
 	}
@@ -188,37 +180,13 @@ func FindStyleCardsPaginated(page, size int, order string) ([]StyleCard, error)
 
 // FindStyleCardsPaginatedForUserID returns user's style cards for paginated pages.
 func FindStyleCardsPaginatedForUserID(page, size int, order string, id uint) ([]StyleCard, error) {
-	var stmt string
-	switch {
-	case strings.HasPrefix(order, "styles"):
-		stmt = "id"
-	case strings.HasPrefix(order, "views"):
-		stmt = "id, (SELECT total_views FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS views"
-	case strings.HasPrefix(order, "installs"):
-		stmt = "id, (SELECT total_installs FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS installs"
-	case strings.HasPrefix(order, "rating"):
-		stmt = "id, (SELECT ROUND(AVG(rating), 1) FROM reviews r WHERE r.style_id = styles.id AND r.deleted_at IS NULL) AS rating"
-	}
-
+	var res []StyleCard
 	offset := (page - 1) * size
-	var nums []struct{ ID int }
+
 	err := database.Conn.
-		Select(stmt).Table("styles").
+		Select(selectCards).Joins(joinSummary).
 		Order(order).Offset(offset).Limit(size).
-		Find(&nums, notDeleted+" AND user_id = ?", id).Error
-	if err != nil {
-		return nil, err
-	}
-
-	items := make([]int, 0, len(nums))
-	for _, num := range nums {
-		items = append(items, num.ID)
-	}
-
-	var res []StyleCard
-	err = database.Conn.
-		Select(selectCards).Order(order).
-		Find(&res, "id in ?", items).Error
+		Find(&res, notDeleted+" AND user_id = ?", id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -232,7 +200,7 @@ func FindStyleCardsCreatedOn(date time.Time) ([]StyleCard, error) {
 
 	cond := notDeleted + " AND DATE(?) == DATE(created_at)"
 	err := database.Conn.
-		Select(selectCards).
+		Select(selectCards).Joins(joinSummary).
 		Find(&res, cond, date).Error
 	if err != nil {
 		return nil, err
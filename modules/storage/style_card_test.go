@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"userstyles.world/models"
+	"userstyles.world/modules/database"
+)
+
+func TestStyleSummaries(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Conn = db
+
+	if err = seedStyles(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	// A newer snapshot of the first style.
+	h := models.History{StyleID: 1, TotalInstalls: 500, TotalViews: 900}
+	if err = db.Create(&h).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err = models.UpdateStyleTotals(db); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, exp []StyleCard) {
+		t.Helper()
+		got, err := FindStyleCardsPaginated(1, 10, "styles.id ASC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(exp) {
+			t.Fatalf("%s: got %d cards, expected %d", name, len(got), len(exp))
+		}
+		for i, c := range got {
+			e := exp[i]
+			if c.Installs != e.Installs || c.Views != e.Views ||
+				c.Rating != e.Rating || c.ReviewCount != e.ReviewCount {
+				t.Errorf("%s: style %d: got %d/%d/%.1f/%d, expected %d/%d/%.1f/%d",
+					name, c.ID, c.Installs, c.Views, c.Rating, c.ReviewCount,
+					e.Installs, e.Views, e.Rating, e.ReviewCount)
+			}
+		}
+	}
+
+	check("init", []StyleCard{
+		{Installs: 500, Views: 900, Rating: 4, ReviewCount: 3},
+		{Installs: 300, Views: 3000, Rating: 4, ReviewCount: 3},
+	})
+
+	r := models.NewReview(4, 2, "1", "")
+	if err = r.CreateForStyle(); err != nil {
+		t.Fatal(err)
+	}
+	check("create", []StyleCard{
+		{Installs: 500, Views: 900, Rating: 4, ReviewCount: 3},
+		{Installs: 300, Views: 3000, Rating: 3.3, ReviewCount: 4},
+	})
+
+	r = models.NewReviewUpdate(4, 2, r.ID, "5", "")
+	if err = r.UpdateFromUser(); err != nil {
+		t.Fatal(err)
+	}
+	check("update", []StyleCard{
+		{Installs: 500, Views: 900, Rating: 4, ReviewCount: 3},
+		{Installs: 300, Views: 3000, Rating: 4.3, ReviewCount: 4},
+	})
+
+	for id := 1; id <= 3; id++ {
+		if err = models.DeleteReviewFromUser(id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("delete", []StyleCard{
+		{Installs: 500, Views: 900},
+		{Installs: 300, Views: 3000, Rating: 4.3, ReviewCount: 4},
+	})
+}
+
+// Correlated subqueries that style cards used before style summaries, kept as
+// a baseline for benchmarks.
+const (
+	correlatedInstalls    = "(SELECT total_installs FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS installs"
+	correlatedViews       = "(SELECT total_views FROM histories h WHERE h.style_id = styles.id ORDER BY id DESC LIMIT 1) AS views"
+	correlatedRatings     = "(SELECT ROUND(AVG(rating), 1) FROM reviews r WHERE r.style_id = styles.id AND r.rating > 0 AND r.deleted_at IS NULL) AS Rating"
+	correlatedReviewCount = "(SELECT COUNT(rating) FROM reviews r WHERE r.style_id = styles.id AND r.rating > 0 AND r.deleted_at IS NULL) AS ReviewCount"
+)
+
+var correlatedCards = strings.Join([]string{
+	"id", "updated_at", "name", "preview", selectAuthor,
+	correlatedInstalls, correlatedViews, correlatedRatings, correlatedReviewCount,
+}, ", ")
+
+// findStyleCardsCorrelated is how FindStyleCardsPaginated worked before style
+// summaries.
+func findStyleCardsCorrelated(page, size int, order string) ([]StyleCard, error) {
+	var res []StyleCard
+	offset := (page - 1) * size
+
+	var stmt string
+	switch {
+	case strings.HasPrefix(order, "styles"):
+		stmt = "id"
+	case strings.HasPrefix(order, "views"):
+		stmt = "id, " + correlatedViews
+	case strings.HasPrefix(order, "installs"):
+		stmt = "id, " + correlatedInstalls
+	case strings.HasPrefix(order, "rating"):
+		stmt = "id, (SELECT ROUND(AVG(rating), 1) FROM reviews r WHERE r.style_id = styles.id AND r.deleted_at IS NULL) AS rating"
+	}
+
+	var nums []struct{ ID int }
+	err := database.Conn.
+		Select(stmt).Table("styles").
+		Order(order).Offset(offset).Limit(size).Find(&nums, notDeleted).Error
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]int, 0, len(nums))
+	for _, num := range nums {
+		items = append(items, num.ID)
+	}
+
+	err = database.Conn.
+		Select(correlatedCards).Order(order).Find(&res, items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func benchmarkStyleCards(b *testing.B, find func(page, size int, order string) ([]StyleCard, error)) {
+	cases := []struct {
+		name  string
+		order string
+	}{
+		{"newest", "styles.created_at DESC"},
+		{"installs", "installs DESC"},
+		{"views", "views DESC"},
+		{"rating", "rating DESC"},
+	}
+
+	db, err := initDB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err = seedStyles(db, 5000); err != nil {
+		b.Fatal(err)
+	}
+	database.Conn = db
+
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := find(2, 36, c.order); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFindStyleCardsPaginated(b *testing.B) {
+	benchmarkStyleCards(b, FindStyleCardsPaginated)
+}
+
+func BenchmarkFindStyleCardsCorrelated(b *testing.B) {
+	benchmarkStyleCards(b, findStyleCardsCorrelated)
+}
+
+func TestTrendingStyles(t *testing.T) {
+	db, err := initDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.Conn = db
+
+	if err = seedStyles(db, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err = models.UpdateStyleTotals(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots of the last few days, where the third style is growing.
+	now := time.Now()
+	for day := 0; day < 5; day++ {
+		for id, n := range map[uint]int64{2: 2, 3: 30} {
+			h := models.History{StyleID: id, DailyInstalls: n, TotalInstalls: 300 + n*int64(5-day)}
+			h.CreatedAt = now.AddDate(0, 0, -day)
+			if err = db.Create(&h).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Installs from a month ago don't count.
+	h := models.History{StyleID: 1, DailyInstalls: 1000, TotalInstalls: 1300}
+	h.CreatedAt = now.AddDate(0, -1, 0)
+	if err = db.Create(&h).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err = models.UpdateTrendingScores(db, now); err != nil {
+		t.Fatal(err)
+	}
+
+	p := models.Pagination{Sort: "trending"}
+	got, err := FindStyleCardsPaginated(1, 10, p.SortStyles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].ID != 3 || got[1].ID != 2 {
+		t.Errorf("got %+v, expected styles 3, 2 and 1", got)
+	}
+
+	trending, err := FindStyleCardsTrending(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trending) != 2 || trending[0].ID != 3 {
+		t.Errorf("got %+v, expected styles 3 and 2", trending)
+	}
+
+	// Scores are reset when styles stop getting installs.
+	if err = models.UpdateTrendingScores(db, now.AddDate(0, 0, 30)); err != nil {
+		t.Fatal(err)
+	}
+	if trending, err = FindStyleCardsTrending(10); err != nil || len(trending) != 0 {
+		t.Errorf("got %+v, %v, expected no trending styles", trending, err)
+	}
+}
@@ -23,7 +23,7 @@ type StyleSearch struct {
 func TotalSearchStyles(query, sort string) (int, error) {
 	q := "SELECT COUNT(*) FROM fts_styles WHERE fts_styles MATCH ?"
 	if strings.HasPrefix(sort, "rating") {
-		q += " AND (SELECT COUNT(*) FROM reviews WHERE style_id = fts_styles.id AND rating > 0)"
+		q += " AND (SELECT review_count FROM style_summaries WHERE style_id = fts_styles.id) > 0"
 	}
 
 	var total int
@@ -42,12 +42,13 @@ func FindSearchStyles(query, sort string, page int) ([]*StyleCard, error) {
 	var b strings.Builder
 	b.WriteString(`SELECT styles.id, styles.name, styles.updated_at, styles.preview,
 (SELECT username FROM users WHERE users.id = styles.user_id AND deleted_at IS NULL) AS username,
-(SELECT total_views FROM histories WHERE histories.style_id = fts.id ORDER BY id DESC LIMIT 1) AS views,
-(SELECT total_installs FROM histories WHERE histories.style_id = fts.id ORDER BY id DESC LIMIT 1) AS installs,
-(SELECT ROUND(AVG(rating), 1) FROM reviews r WHERE r.style_id = fts.id AND r.rating > 0 AND r.deleted_at IS NULL) AS rating,
-(SELECT COUNT(rating) FROM reviews r WHERE r.style_id = fts.id AND r.rating > 0 AND r.deleted_at IS NULL) AS ReviewCount
+ss.total_views AS views,
+ss.total_installs AS installs,
+ROUND(ss.rating, 1) AS rating,
+ss.review_count AS ReviewCount
 FROM fts_styles AS fts
 JOIN styles ON styles.id = fts.id
+LEFT JOIN style_summaries ss ON ss.style_id = fts.id
 WHERE fts_styles
 MATCH ?`)
 
@@ -80,3 +81,10 @@ MATCH ?`)
 func DeleteSearchData(db *gorm.DB, id int) error {
 	return db.Exec("DELETE FROM fts_styles WHERE id = ?", id).Error
 }
+
+// RestoreSearchData adds a restored userstyle back to FTS table.
+func RestoreSearchData(db *gorm.DB, id int) error {
+	stmt := `INSERT INTO fts_styles(id, name, description, notes, category)
+SELECT id, name, description, notes, category FROM styles WHERE id = ?`
+	return db.Exec(stmt, id).Error
+}
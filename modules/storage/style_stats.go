@@ -14,12 +14,9 @@ type styleStats struct {
 
 // GetStyleStats returns stats for style view page.
 func GetStyleStats(id string) (*styleStats, error) {
-	q := `SELECT
-(SELECT total_views FROM histories WHERE style_id = s.id ORDER BY id DESC LIMIT 1) total_views,
-(SELECT total_installs FROM histories where style_id = s.id ORDER BY id DESC LIMIT 1) total_installs,
-(SELECT weekly_installs FROM histories WHERE style_id = s.id ORDER BY id DESC LIMIT 1) weekly_installs,
-(SELECT weekly_updates FROM histories WHERE style_id = s.id ORDER BY id DESC LIMIT 1) weekly_updates
+	q := `SELECT ss.total_views, ss.total_installs, ss.weekly_installs, ss.weekly_updates
 FROM styles s
+LEFT JOIN style_summaries ss ON ss.style_id = s.id
 WHERE id = ?`
 
 	var s *styleStats
@@ -52,7 +49,7 @@ func GetWeeklyInstalls(id string) int {
 
 // GetTotalViews returns total views for a userstyle.
 func GetTotalViews(id string) int {
-	q := "SELECT total_views FROM histories WHERE style_id = ? ORDER BY id DESC"
+	q := "SELECT total_views FROM style_summaries WHERE style_id = ?"
 
 	var i int
 	database.Conn.Raw(q, id).Scan(&i)
@@ -62,7 +59,7 @@ func GetTotalViews(id string) int {
 
 // GetTotalInstalls returns total installs for a userstyle.
 func GetTotalInstalls(id string) int {
-	q := "SELECT total_installs FROM histories where style_id = ? ORDER BY id DESC"
+	q := "SELECT total_installs FROM style_summaries WHERE style_id = ?"
 
 	var i int
 	database.Conn.Raw(q, id).Scan(&i)
@@ -14,6 +14,7 @@ import (
 
 	"userstyles.world/modules/config"
 	"userstyles.world/modules/markdown"
+	"userstyles.world/modules/oauthlogin"
 	"userstyles.world/modules/util"
 )
 
@@ -59,6 +60,9 @@ func New(views http.FileSystem) *html.Engine {
 
 	engine.AddFunc("sys", status)
 
+	engine.AddFunc("oauthProviders", oauthlogin.Providers)
+	engine.AddFunc("oauthTitle", oauthlogin.Title)
+
 	engine.AddFunc("comma", humanize.Comma)
 
 	engine.AddFunc("size", humanize.Bytes)
@@ -87,6 +91,8 @@ func New(views http.FileSystem) *html.Engine {
 
 	engine.AddFunc("rel", util.RelTime)
 
+	engine.AddFunc("device", util.DescribeUserAgent)
+
 	engine.AddFunc("iso", func(t time.Time) string {
 		return t.Format(time.RFC3339)
 	})
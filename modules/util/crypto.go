@@ -43,3 +43,18 @@ func HashIP(key string) (string, error) {
 
 	return *(*string)(unsafe.Pointer(&b)), nil
 }
+
+// SaltKey derives a key for hashing stats from a salt and the stats key, so
+// that neither can be used on its own.
+func SaltKey(salt []byte) []byte {
+	h := hmac.New(sha512.New, []byte(config.StatsKey))
+	h.Write(salt)
+	return h.Sum(nil)
+}
+
+// HashWithKey generates a unique hash for stats with a derived key.
+func HashWithKey(key []byte, record string) string {
+	h := hmac.New(sha512.New, key)
+	h.Write([]byte(record))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -28,6 +29,29 @@ func TestHashIP(t *testing.T) {
 	}
 }
 
+func TestHashWithKey(t *testing.T) {
+	t.Parallel()
+
+	a := SaltKey([]byte("monday"))
+	b := SaltKey([]byte("tuesday"))
+	if bytes.Equal(a, b) {
+		t.Fatal("expected different keys for different salts")
+	}
+
+	for _, c := range hashCases {
+		record := c.ip + " " + c.id
+		if HashWithKey(a, record) != HashWithKey(a, record) {
+			t.Errorf("%s: expected the same hash with the same salt", c.name)
+		}
+		if HashWithKey(a, record) == HashWithKey(b, record) {
+			t.Errorf("%s: expected different hashes with different salts", c.name)
+		}
+		if HashWithKey(a, record) == c.expected {
+			t.Errorf("%s: expected a different hash than without a salt", c.name)
+		}
+	}
+}
+
 func BenchmarkHashIP(b *testing.B) {
 	for _, c := range hashCases {
 		b.Run(c.name, func(b *testing.B) {
@@ -28,5 +28,6 @@ func encodeToHex(src []byte) string {
 		_ = dst.WriteByte(hextable[v>>4])
 		_ = dst.WriteByte(hextable[v&0x0f])
 	}
-	return UnsafeString(dst.B)
+	// Copy the bytes, as the buffer is reused once it's put back in the pool.
+	return dst.String()
 }
@@ -31,3 +31,15 @@ func TestRandomString(t *testing.T) {
 		}
 	}
 }
+
+func TestRandomStringIsStable(t *testing.T) {
+	t.Parallel()
+	s := RandomString(20)
+	exp := string([]byte(s))
+	for i := 0; i < 100; i++ {
+		RandomString(20)
+	}
+	if s != exp {
+		t.Errorf("RandomString changed after reuse: got %q, expected %q", s, exp)
+	}
+}
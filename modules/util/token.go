@@ -0,0 +1,16 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// HashToken returns a SHA-256 hash of a high-entropy secret, such as recovery
+// codes or access tokens, so that they don't have to be stored in plain text.
+//
+// Don't use it for passwords; use HashPassword instead.
+func HashToken(s string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(s))))
+	return hex.EncodeToString(sum[:])
+}
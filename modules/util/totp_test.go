@@ -0,0 +1,111 @@
+package util
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	t.Parallel()
+
+	// Test values from RFC 4226, Appendix D.
+	key := []byte("12345678901234567890")
+	exp := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+
+	for i, e := range exp {
+		if got := hotp(key, uint64(i), 6); got != e {
+			t.Errorf("counter %d: got %s, expected %s", i, got, e)
+		}
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	t.Parallel()
+
+	// Test values from RFC 6238, Appendix B, truncated to 6 digits.
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	cases := []struct {
+		unix int64
+		exp  string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, c := range cases {
+		got, err := TOTPCode(secret, time.Unix(c.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.exp {
+			t.Errorf("%d: got %s, expected %s", c.unix, got, c.exp)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	t.Parallel()
+
+	secret := NewTOTPSecret()
+	now := time.Unix(1700000000, 0)
+
+	cases := []struct {
+		name  string
+		at    time.Time
+		valid bool
+	}{
+		{"current step", now, true},
+		{"previous step", now.Add(-30 * time.Second), true},
+		{"next step", now.Add(30 * time.Second), true},
+		{"too old", now.Add(-90 * time.Second), false},
+		{"too new", now.Add(90 * time.Second), false},
+	}
+
+	for _, c := range cases {
+		code, err := TOTPCode(secret, c.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		step, ok := ValidateTOTP(secret, code, now)
+		if ok != c.valid {
+			t.Errorf("%s: got %t, expected %t", c.name, ok, c.valid)
+		}
+		if ok && step != TOTPStep(c.at) {
+			t.Errorf("%s: got step %d, expected %d", c.name, step, TOTPStep(c.at))
+		}
+	}
+
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("accepted a code with invalid length")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	t.Parallel()
+
+	got := TOTPURI("UserStyles.world", "admin", "ABC")
+	exp := "otpauth://totp/UserStyles.world:admin?algorithm=SHA1&digits=6&issuer=UserStyles.world&period=30&secret=ABC"
+	if got != exp {
+		t.Errorf("got: %s\nexp: %s", got, exp)
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes := NewRecoveryCodes(10)
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes", len(codes))
+	}
+	for _, c := range codes {
+		if len(c) != 11 || strings.Count(c, "-") != 1 {
+			t.Errorf("malformed code %q", c)
+		}
+	}
+}
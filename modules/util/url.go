@@ -57,10 +57,35 @@ func ProxyResources(s, t string, id uint) string {
 	return linkRe.ReplaceAllString(s, sub)
 }
 
+// crawlers is a list of tokens found in user agents of crawlers, link preview
+// bots, uptime checkers and scrapers.  Tokens are case-sensitive, so that they
+// don't match unrelated words.
+var crawlers = []string{
+	// Search engines and generic crawlers.
+	"Bot", "bot", "crawl", "Crawl", "spider", "Spider", "Slurp",
+	// Fediverse and chat link previews.
+	"Lemmy", "pict-rs", "Calckey", "Misskey", "Friendica", "Akkoma",
+	"Mastodon", "Pleroma", "facebookexternalhit", "WhatsApp", "Iframely",
+	"Embedly", "SkypeUriPreview",
+	// Uptime checkers.
+	"UptimeRobot", "Pingdom", "StatusCake", "Uptime-Kuma", "Site24x7",
+	"Better Uptime", "check_http",
+	// HTTP libraries and tools.
+	"curl/", "Wget/", "python-requests", "python-urllib", "aiohttp",
+	"Go-http-client", "okhttp", "axios/", "node-fetch", "undici",
+	"Java/", "libwww-perl", "HTTrack", "Scrapy", "HeadlessChrome",
+	"PhantomJS",
+}
+
 // IsCrawler ignores crawlers in places where we collect statistics.
 func IsCrawler(ua string) bool {
-	return strings.Contains(ua, "Bot") || strings.Contains(ua, "bot") ||
-		strings.Contains(ua, "Lemmy") || strings.Contains(ua, "pict-rs") ||
-		strings.Contains(ua, "Calckey") || strings.Contains(ua, "Misskey") ||
-		strings.Contains(ua, "Friendica") || strings.Contains(ua, "Akkoma")
+	if ua == "" {
+		return true
+	}
+	for _, s := range crawlers {
+		if strings.Contains(ua, s) {
+			return true
+		}
+	}
+	return false
 }
@@ -108,6 +108,13 @@ var crawlerCases = []struct {
 	{"misskey", "Misskey/13.12.2 (https://example.com", true},
 	{"friendica", "Friendica 'Giant Rhubarb' 2023.05-1518; https://example.com", true},
 	{"akkoma", "Akkoma 3.9.3-0-deadbeef; https://example.com <user@example.com>", true},
+	{"empty", "", true},
+	{"cubot phone", "Mozilla/5.0 (Linux; Android 10; CUBOT X30) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Mobile Safari/537.36", false},
+	{"facebook", "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+	{"uptime kuma", "Uptime-Kuma/1.23.0", true},
+	{"curl", "curl/8.1.2", true},
+	{"python", "python-requests/2.31.0", true},
+	{"headless", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/112.0.0.0 Safari/537.36", true},
 }
 
 func TestIsCrawler(t *testing.T) {
@@ -1,5 +1,6 @@
 import {checkRedirect} from './page/account';
 import {saveRedirect} from './page/login';
+import {passkeyLogin, passkeyRegister} from './page/passkey';
 import {changeEntriesBehavior} from './page/modlog';
 import {initalizeOrUpdateColorScheme as initalizeOrUpdateColorScheme} from './color-scheme';
 import {broadcastReady} from './third-party';
@@ -42,6 +43,7 @@ function pageSpecificFunctions(settings: UserSettings) {
             break;
         case '/login':
             saveRedirect();
+            passkeyLogin();
             break;
         case '/add':
         case '/import':
@@ -58,6 +60,7 @@ function pageSpecificFunctions(settings: UserSettings) {
     if (location.pathname.startsWith("/account")) {
         checkMaxLength();
         checkRedirect(settings.redirect);
+        passkeyRegister();
     }
 
     if (location.pathname.startsWith('/style/') && styleViewRegex.test(location.pathname)) {
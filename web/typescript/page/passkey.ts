@@ -0,0 +1,122 @@
+// WebAuthn sends binary data as base64url-encoded strings in JSON.
+const decode = (s: string) => {
+    const b64 = s.replace(/-/g, '+').replace(/_/g, '/');
+    return Uint8Array.from(atob(b64), (c) => c.charCodeAt(0)).buffer;
+};
+
+const encode = (b: ArrayBuffer | null) => {
+    if (!b) {
+        return null;
+    }
+    const s = String.fromCharCode(...new Uint8Array(b));
+    return btoa(s).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+};
+
+const isSupported = () => typeof window.PublicKeyCredential !== 'undefined';
+
+function showError(el: HTMLElement, msg: string) {
+    el.textContent = msg;
+    el.hidden = false;
+}
+
+async function post(url: string, body?: unknown) {
+    const res = await fetch(url, {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: {'Content-Type': 'application/json'},
+        body: body ? JSON.stringify(body) : undefined,
+    });
+    const json = await res.json();
+    if (!res.ok) {
+        throw new Error(json.data);
+    }
+    return json;
+}
+
+async function register(name: string) {
+    const {publicKey} = await post('/account/passkeys/begin');
+    publicKey.challenge = decode(publicKey.challenge);
+    publicKey.user.id = decode(publicKey.user.id);
+    for (const c of publicKey.excludeCredentials || []) {
+        c.id = decode(c.id);
+    }
+
+    const cred = await navigator.credentials.create({publicKey}) as PublicKeyCredential;
+    const res = cred.response as AuthenticatorAttestationResponse;
+    const transports = typeof res.getTransports === 'function' ? res.getTransports() : [];
+
+    return post('/account/passkeys/finish?name=' + encodeURIComponent(name), {
+        id: cred.id,
+        rawId: encode(cred.rawId),
+        type: cred.type,
+        response: {
+            clientDataJSON: encode(res.clientDataJSON),
+            attestationObject: encode(res.attestationObject),
+            transports,
+        },
+    });
+}
+
+async function login(remember: boolean) {
+    const {publicKey} = await post('/login/passkey/begin');
+    publicKey.challenge = decode(publicKey.challenge);
+    for (const c of publicKey.allowCredentials || []) {
+        c.id = decode(c.id);
+    }
+
+    const cred = await navigator.credentials.get({publicKey}) as PublicKeyCredential;
+    const res = cred.response as AuthenticatorAssertionResponse;
+
+    return post('/login/passkey/finish' + (remember ? '?remember=on' : ''), {
+        id: cred.id,
+        rawId: encode(cred.rawId),
+        type: cred.type,
+        response: {
+            clientDataJSON: encode(res.clientDataJSON),
+            authenticatorData: encode(res.authenticatorData),
+            signature: encode(res.signature),
+            userHandle: encode(res.userHandle),
+        },
+    });
+}
+
+export function passkeyLogin() {
+    const btn = document.getElementById('passkey-login') as HTMLButtonElement;
+    const err = document.getElementById('passkey-error');
+    if (!btn || !err || !isSupported()) {
+        return;
+    }
+
+    btn.hidden = false;
+    btn.addEventListener('click', () => {
+        const remember = (document.getElementById('remember') as HTMLInputElement)?.checked;
+        login(remember)
+            .then(({data}) => location.assign(data))
+            .catch((e: Error) => showError(err, e.message || 'Failed to sign in with passkey.'));
+    });
+}
+
+export function passkeyRegister() {
+    const form = document.getElementById('passkey-add') as HTMLFormElement;
+    const err = document.getElementById('passkey-error');
+    if (!form || !err) {
+        return;
+    }
+
+    if (!isSupported()) {
+        showError(err, 'Your browser doesn\'t support passkeys.');
+        return;
+    }
+
+    form.hidden = false;
+    form.addEventListener('submit', (e) => {
+        e.preventDefault();
+        const name = (form.elements.namedItem('name') as HTMLInputElement).value;
+        register(name)
+            .then(({data}) => {
+                location.assign(data);
+                location.reload();
+            })
+            .catch((e: Error) => showError(err, e.message || 'Failed to add passkey.'));
+    });
+}